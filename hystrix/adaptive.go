@@ -0,0 +1,130 @@
+package hystrix
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveBackoff is how much the limit shrinks by each time a command times out or runs
+	// much slower than usual.
+	adaptiveBackoff = 0.9
+	// adaptiveSlowdown is how many times longer than the smoothed run duration a run may take
+	// before it counts as latency climbing.
+	adaptiveSlowdown = 2
+	// adaptiveSmoothing is how many runs the smoothed run duration roughly averages over.
+	adaptiveSmoothing = 20
+)
+
+// adaptiveLimiter moves a pool's concurrency limit between 1 and its Max, in the style of AIMD.
+// The limit grows by one for each run which finishes in good time while the pool is at least
+// half used, and shrinks by adaptiveBackoff for each timeout or run which is much slower than
+// usual. Rejections by the pool itself do not shrink the limit, since the limit causes them.
+//
+// The pool's ticket channel always holds Max tickets in total. While the limit is lower, the
+// difference is parked outside the channel, taking tickets as they are returned when none are
+// free.
+type adaptiveLimiter struct {
+	mutex sync.Mutex
+	limit float64
+	// parked is how many tickets are currently kept out of the pool.
+	parked int
+	// owed is how many tickets should be parked as soon as they are returned.
+	owed int
+	// baseline is the smoothed duration of runs which finished in good time.
+	baseline time.Duration
+}
+
+func newAdaptiveLimiter(max int) *adaptiveLimiter {
+	return &adaptiveLimiter{limit: float64(max)}
+}
+
+// limit returns how many executions may currently hold one of the pool's tickets.
+func (p *executorPool) limit() int {
+	if p.adaptive == nil {
+		return p.Max
+	}
+
+	p.adaptive.mutex.Lock()
+	defer p.adaptive.mutex.Unlock()
+
+	return int(p.adaptive.limit)
+}
+
+// observe adjusts an adaptive pool's limit after an execution. dropped is true for timeouts.
+func (p *executorPool) observe(runDuration time.Duration, dropped bool) {
+	a := p.adaptive
+	if a == nil {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !dropped {
+		if a.baseline > 0 && runDuration > adaptiveSlowdown*a.baseline {
+			dropped = true
+		} else if a.baseline == 0 {
+			a.baseline = runDuration
+		} else {
+			a.baseline += (runDuration - a.baseline) / adaptiveSmoothing
+		}
+	}
+
+	if dropped {
+		a.limit *= adaptiveBackoff
+		if a.limit < 1 {
+			a.limit = 1
+		}
+	} else if 2*(p.Max-len(p.Tickets)-a.parked) >= int(a.limit) {
+		a.limit++
+		if a.limit > float64(p.Max) {
+			a.limit = float64(p.Max)
+		}
+	}
+
+	p.resizeLocked(int(a.limit))
+}
+
+// resizeLocked parks or releases tickets until limit tickets are in circulation. The caller must
+// hold the limiter's mutex.
+func (p *executorPool) resizeLocked(limit int) {
+	a := p.adaptive
+	current := p.Max - a.parked - a.owed
+
+	for ; current > limit; current-- {
+		select {
+		case <-p.Tickets:
+			a.parked++
+		default:
+			a.owed++
+		}
+	}
+	for ; current < limit; current++ {
+		if a.owed > 0 {
+			a.owed--
+			continue
+		}
+		a.parked--
+		p.put(&struct{}{})
+	}
+}
+
+// park keeps a returned ticket out of the pool if the limit has shrunk below the tickets in use,
+// reporting whether it did.
+func (p *executorPool) park() bool {
+	a := p.adaptive
+	if a == nil {
+		return false
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.owed == 0 {
+		return false
+	}
+	a.owed--
+	a.parked++
+	return true
+}
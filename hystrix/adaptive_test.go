@@ -0,0 +1,95 @@
+package hystrix
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	Convey("with an adaptive pool of 10 executors, 5 of them in use", t, func() {
+		defer Flush()
+		ConfigureCommand("adaptive", CommandConfig{MaxConcurrentRequests: 10, AdaptiveConcurrency: true})
+		pool := newExecutorPool("adaptive")
+
+		var inUse []*struct{}
+		for i := 0; i < 5; i++ {
+			inUse = append(inUse, <-pool.Tickets)
+		}
+		pool.observe(10*time.Millisecond, false)
+		So(pool.limit(), ShouldEqual, 10)
+
+		Convey("timeouts shrink the limit, parking free executors", func() {
+			pool.observe(0, true)
+			pool.observe(0, true)
+			So(pool.limit(), ShouldEqual, 8)
+			So(len(pool.Tickets), ShouldEqual, 3)
+			So(pool.ActiveCount(), ShouldEqual, 5)
+
+			Convey("and runs in the usual time grow it again while the pool is busy", func() {
+				pool.observe(10*time.Millisecond, false)
+				So(pool.limit(), ShouldEqual, 9)
+				So(len(pool.Tickets), ShouldEqual, 4)
+			})
+		})
+
+		Convey("a run much slower than usual shrinks the limit", func() {
+			pool.observe(100*time.Millisecond, false)
+			So(pool.limit(), ShouldEqual, 9)
+		})
+
+		Convey("shrinking below the executors in use parks them as they are returned", func() {
+			for pool.limit() > 3 {
+				pool.observe(0, true)
+			}
+			So(len(pool.Tickets), ShouldEqual, 0)
+			So(pool.ActiveCount(), ShouldEqual, 5)
+
+			pool.Return(inUse[0])
+			pool.Return(inUse[1])
+			So(len(pool.Tickets), ShouldEqual, 0)
+			So(pool.ActiveCount(), ShouldEqual, 3)
+
+			pool.Return(inUse[2])
+			So(len(pool.Tickets), ShouldEqual, 1)
+			So(pool.ActiveCount(), ShouldEqual, 2)
+		})
+
+		Convey("the limit never drops below one", func() {
+			for i := 0; i < 100; i++ {
+				pool.observe(0, true)
+			}
+			So(pool.limit(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("with a command using adaptive concurrency", t, func() {
+		defer Flush()
+		ConfigureCommand("adaptive", CommandConfig{Timeout: 5, MaxConcurrentRequests: 10, AdaptiveConcurrency: true})
+
+		Convey("MaxConcurrency reports the limit shrinking as commands time out", func() {
+			So(MaxConcurrency("adaptive"), ShouldEqual, 10)
+			for i := 0; i < 5; i++ {
+				Do("adaptive", func() error {
+					time.Sleep(20 * time.Millisecond)
+					return nil
+				}, nil)
+			}
+			time.Sleep(10 * time.Millisecond)
+			So(MaxConcurrency("adaptive"), ShouldEqual, 5)
+		})
+	})
+
+	Convey("with a command not using adaptive concurrency", t, func() {
+		defer Flush()
+		ConfigureCommand("fixed", CommandConfig{MaxConcurrentRequests: 10})
+		pool := newExecutorPool("fixed")
+
+		Convey("its limit stays at its maximum", func() {
+			pool.observe(0, true)
+			So(pool.limit(), ShouldEqual, 10)
+			So(len(pool.Tickets), ShouldEqual, 10)
+		})
+	})
+}
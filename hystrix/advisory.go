@@ -0,0 +1,122 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// advisoryMinRequests is how many executions the rolling window must hold before an advisory
+// check judges the share of them which timed out or were rejected.
+const advisoryMinRequests = 10
+
+// advisoryShare is the percentage of executions timing out or being rejected above which an
+// advisory check warns.
+const advisoryShare = 10
+
+var (
+	advisoryChecks      bool
+	advisoryChecksMutex *sync.RWMutex
+)
+
+func init() {
+	advisoryChecksMutex = &sync.RWMutex{}
+}
+
+// SetAdvisoryChecks turns on warnings about commands which look misconfigured, which are off by
+// default. Once on, each command is checked when its first execution is recorded and then as
+// each bucket of its rolling window starts, and a warning is logged when its timeout is below
+// the 99th percentile of its recent run durations, when at least a tenth of its recent
+// executions timed out, or when at least a tenth were rejected by its concurrency limit. A
+// command is warned about at most once per RollingWindow. The checks only log, and never change
+// how commands run.
+func SetAdvisoryChecks(enabled bool) {
+	advisoryChecksMutex.Lock()
+	defer advisoryChecksMutex.Unlock()
+
+	advisoryChecks = enabled
+}
+
+func advisoryChecksEnabled() bool {
+	advisoryChecksMutex.RLock()
+	defer advisoryChecksMutex.RUnlock()
+
+	return advisoryChecks
+}
+
+// advisoryWatch spaces out the advisory checks of a command, and the warnings they log.
+type advisoryWatch struct {
+	mutex sync.Mutex
+	// checked and warned are when the command was last checked and warned about, or zero if it
+	// has not been.
+	checked time.Time
+	warned  time.Time
+}
+
+// due reports whether a check should run at now, recording it as run if so.
+func (w *advisoryWatch) due(now time.Time, interval time.Duration) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.checked.IsZero() && now.Sub(w.checked) < interval {
+		return false
+	}
+	w.checked = now
+	return true
+}
+
+// warn reports whether a check at now may log its warnings, recording them as logged if so.
+func (w *advisoryWatch) warn(now time.Time, interval time.Duration) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.warned.IsZero() && now.Sub(w.warned) < interval {
+		return false
+	}
+	w.warned = now
+	return true
+}
+
+// advise logs a warning for each way the command looks misconfigured, if advisory checks are on
+// and one is due.
+func (m *metricExchange) advise(now time.Time) {
+	if !advisoryChecksEnabled() {
+		return
+	}
+	settings := getSettings(m.Name)
+	_, bucketDuration := rollingBuckets(settings.RollingWindow, settings.RollingBuckets)
+	if !m.advisory.due(now, bucketDuration) {
+		return
+	}
+
+	metrics := m.Snapshot(now)
+	p99 := m.DefaultCollector().RunDuration().PercentileDuration(99)
+	enough := metrics.Requests >= advisoryMinRequests
+
+	var warnings []string
+	// a dynamic timeout already follows the run durations
+	if timeout := settings.Timeout; timeout > 0 && settings.DynamicTimeout.Multiplier <= 0 {
+		if p99 > timeout {
+			warnings = append(warnings, fmt.Sprintf("hystrix-go: command %v timeout %v < p99 %v, likely to time out",
+				m.Name, timeout, p99))
+		} else if enough && metrics.Timeouts*100 >= advisoryShare*metrics.Requests {
+			warnings = append(warnings, fmt.Sprintf("hystrix-go: command %v timed out %d of %d recent executions with timeout %v, likely too short",
+				m.Name, metrics.Timeouts, metrics.Requests, timeout))
+		}
+	}
+	if enough && metrics.Rejects*100 >= advisoryShare*metrics.Requests {
+		max := settings.MaxConcurrentRequests
+		if settings.Group != "" {
+			max = getSettings(settings.Group).MaxConcurrentRequests
+		}
+		warnings = append(warnings, fmt.Sprintf("hystrix-go: command %v rejected %d of %d recent executions at MaxConcurrentRequests %d, likely too low",
+			m.Name, metrics.Rejects, metrics.Requests, max))
+	}
+
+	if len(warnings) == 0 || !m.advisory.warn(now, settings.RollingWindow) {
+		return
+	}
+	for _, warning := range warnings {
+		log.Warnf("%v", warning)
+	}
+}
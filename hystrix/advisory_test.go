@@ -0,0 +1,84 @@
+package hystrix
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAdvisoryChecks(t *testing.T) {
+	Convey("with advisory checks on", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		logger := &recordingLogger{}
+		SetLeveledLogger(logger)
+		defer SetLeveledLogger(DefaultLogger)
+		SetAdvisoryChecks(true)
+		defer SetAdvisoryChecks(false)
+		lines := func() []string {
+			logger.mu.Lock()
+			defer logger.mu.Unlock()
+			return append([]string(nil), logger.lines...)
+		}
+
+		ConfigureCommand("advised", CommandConfig{Timeout: 50, RequestVolumeThreshold: 1000})
+		report := func(outcome Outcome, duration time.Duration, n int) {
+			for i := 0; i < n; i++ {
+				ReportEvent("advised", outcome, duration)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("a timeout below the p99 latency is warned about on first use", func() {
+			report(OutcomeSuccess, 800*time.Millisecond, 1)
+			So(logger.logged("warn hystrix-go: command advised timeout 50ms < p99 800ms, likely to time out"), ShouldBeTrue)
+		})
+
+		Convey("a command which often times out is warned about once it has enough executions", func() {
+			report(OutcomeSuccess, time.Millisecond, 5)
+			report(OutcomeTimeout, 0, 5)
+			So(logger.logged("warn hystrix-go: command advised timed out"), ShouldBeFalse)
+
+			clock.Advance(time.Second)
+			report(OutcomeSuccess, time.Millisecond, 1)
+			So(logger.logged("warn hystrix-go: command advised timed out 5 of 11 recent executions with timeout 50ms, likely too short"), ShouldBeTrue)
+		})
+
+		Convey("a command which is often rejected is warned about", func() {
+			report(OutcomeSuccess, time.Millisecond, 1)
+			report(OutcomeRejected, 0, 10)
+			clock.Advance(time.Second)
+			report(OutcomeSuccess, time.Millisecond, 1)
+			So(logger.logged("warn hystrix-go: command advised rejected 10 of 12 recent executions at MaxConcurrentRequests 10, likely too low"), ShouldBeTrue)
+		})
+
+		Convey("a command is warned about at most once per rolling window", func() {
+			report(OutcomeSuccess, 800*time.Millisecond, 1)
+			clock.Advance(time.Second)
+			report(OutcomeSuccess, 800*time.Millisecond, 1)
+			So(lines(), ShouldHaveLength, 1)
+		})
+
+		Convey("a healthy command is not warned about", func() {
+			report(OutcomeSuccess, time.Millisecond, 20)
+			clock.Advance(time.Second)
+			report(OutcomeSuccess, time.Millisecond, 1)
+			So(lines(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("advisory checks are off by default", t, func() {
+		defer Flush()
+		logger := &recordingLogger{}
+		SetLeveledLogger(logger)
+		defer SetLeveledLogger(DefaultLogger)
+
+		ConfigureCommand("advised", CommandConfig{Timeout: 50})
+		ReportEvent("advised", OutcomeSuccess, 800*time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		So(logger.logged("warn"), ShouldBeFalse)
+	})
+}
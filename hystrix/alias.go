@@ -0,0 +1,91 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	aliasesMutex *sync.RWMutex
+	aliases      map[string]string
+	// aliasCount is how many aliases there are, so that looking up a name takes no lock while
+	// there are none, as is usual outside a migration.
+	aliasCount int32
+)
+
+func init() {
+	aliasesMutex = &sync.RWMutex{}
+	aliases = make(map[string]string)
+}
+
+// AliasCommand makes oldName another name for newName while a command is being renamed, so that
+// executions under either name share newName's circuit, settings, executors, fallback and
+// metrics, rather than the old name's state being lost or its metrics split across two
+// commands. Configuring oldName, enabling or disabling it, or registering fallbacks, hooks,
+// middleware or result checks for it, applies to newName. An alias of an alias resolves to the
+// name at the end of the chain, and an alias which would make a name resolve to itself is
+// rejected.
+//
+// Removing the alias with RemoveAlias reverts the two names to independent commands. oldName
+// then goes back to any circuit and settings it had of its own before being aliased, which are
+// left unused meanwhile, or starts afresh. Flush removes every alias.
+func AliasCommand(oldName, newName string) error {
+	aliasesMutex.Lock()
+	defer aliasesMutex.Unlock()
+
+	for name, ok := newName, true; ok; name, ok = aliases[name] {
+		if name == oldName {
+			return fmt.Errorf("hystrix: aliasing command %q to %q would make it an alias of itself", oldName, newName)
+		}
+	}
+	if _, exists := aliases[oldName]; !exists {
+		atomic.AddInt32(&aliasCount, 1)
+	}
+	aliases[oldName] = newName
+	return nil
+}
+
+// RemoveAlias removes the alias set for oldName by AliasCommand, after which oldName is a command
+// of its own again.
+func RemoveAlias(oldName string) {
+	aliasesMutex.Lock()
+	defer aliasesMutex.Unlock()
+
+	if _, exists := aliases[oldName]; exists {
+		delete(aliases, oldName)
+		atomic.AddInt32(&aliasCount, -1)
+	}
+}
+
+// canonicalName returns the name the named command's state is kept under, which is the name
+// itself unless it has been aliased by AliasCommand.
+func canonicalName(name string) string {
+	if atomic.LoadInt32(&aliasCount) == 0 {
+		return name
+	}
+
+	aliasesMutex.RLock()
+	defer aliasesMutex.RUnlock()
+
+	return resolveAliasLocked(name)
+}
+
+func resolveAliasLocked(name string) string {
+	// AliasCommand rejects cycles, so every chain ends
+	for {
+		next, ok := aliases[name]
+		if !ok {
+			return name
+		}
+		name = next
+	}
+}
+
+func flushAliases() {
+	aliasesMutex.Lock()
+	defer aliasesMutex.Unlock()
+
+	aliases = make(map[string]string)
+	atomic.StoreInt32(&aliasCount, 0)
+}
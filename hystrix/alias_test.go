@@ -0,0 +1,87 @@
+package hystrix
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAliasCommand(t *testing.T) {
+	Convey("with an old command name aliased to its new name", t, func() {
+		defer Flush()
+		ConfigureCommand("users_get", CommandConfig{Timeout: 20, RequestVolumeThreshold: 2})
+		So(AliasCommand("get_user", "users_get"), ShouldBeNil)
+		fail := func() error { return fmt.Errorf("failure") }
+
+		Convey("both names share one circuit", func() {
+			old, _, _ := GetCircuit("get_user")
+			renamed, _, _ := GetCircuit("users_get")
+			So(old, ShouldEqual, renamed)
+			So(old.Name, ShouldEqual, "users_get")
+		})
+
+		Convey("the old name uses the new name's settings", func() {
+			So(timeoutForCommand("get_user"), ShouldEqual, 20*time.Millisecond)
+			So(isRegistered("get_user"), ShouldBeTrue)
+
+			Convey("and configuring it configures the new name", func() {
+				ConfigureCommand("get_user", CommandConfig{Timeout: 40})
+				So(getSettings("users_get").Timeout, ShouldEqual, 40*time.Millisecond)
+			})
+		})
+
+		Convey("failures under either name count against the shared circuit", func() {
+			Do("get_user", fail, nil)
+			Do("users_get", fail, nil)
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("users_get").Failures, ShouldEqual, 2)
+			So(IsOpen("get_user"), ShouldBeTrue)
+		})
+
+		Convey("a fallback registered for the new name serves the old name", func() {
+			RegisterFallback("users_get", func(err error) error { return nil })
+			So(Do("get_user", fail, nil), ShouldBeNil)
+		})
+
+		Convey("a fallback registered under the old name serves both names", func() {
+			RegisterFallback("get_user", func(err error) error { return nil })
+			So(Do("get_user", fail, nil), ShouldBeNil)
+			So(Do("users_get", fail, nil), ShouldBeNil)
+		})
+
+		Convey("disabling the old name disables the new name", func() {
+			SetEnabled("get_user", false)
+			So(IsEnabled("users_get"), ShouldBeFalse)
+			err := Do("get_user", fail, func(err error) error { return nil })
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "failure")
+		})
+
+		Convey("an alias of an alias resolves to the end of the chain", func() {
+			So(AliasCommand("fetch_user", "get_user"), ShouldBeNil)
+			So(canonicalName("fetch_user"), ShouldEqual, "users_get")
+		})
+
+		Convey("an alias which would loop is rejected", func() {
+			So(AliasCommand("users_get", "get_user"), ShouldNotBeNil)
+			So(AliasCommand("get_user", "get_user"), ShouldNotBeNil)
+			So(canonicalName("users_get"), ShouldEqual, "users_get")
+		})
+
+		Convey("removing the alias makes the names independent again", func() {
+			Do("get_user", fail, nil)
+			RemoveAlias("get_user")
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("get_user").Failures, ShouldEqual, 0)
+			So(GetMetrics("users_get").Failures, ShouldEqual, 1)
+			So(isRegistered("get_user"), ShouldBeFalse)
+		})
+
+		Convey("Flush removes every alias", func() {
+			Flush()
+			So(canonicalName("get_user"), ShouldEqual, "get_user")
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package hystrix
+
+import (
+	"sync"
+)
+
+// batchCall is a command execution shared by every GoBatch caller with the same name and key.
+type batchCall struct {
+	errChans []chan error
+}
+
+type batchKey struct {
+	name string
+	key  string
+}
+
+var (
+	batchesMutex *sync.Mutex
+	batches      map[batchKey]*batchCall
+)
+
+func init() {
+	batchesMutex = &sync.Mutex{}
+	batches = make(map[batchKey]*batchCall)
+}
+
+// GoBatch runs your function like Go, but collapses concurrent executions of the same command
+// and key into one. The first caller for a key executes the command, and callers arriving while
+// it is still running share its result instead of executing their own run and fallback. The
+// circuit records a single outcome for the shared execution.
+//
+// Since the run and fallback of later callers are never called, results should be passed back
+// through state which is shared by every caller, such as a cache filled by the first caller's run.
+// Once the shared execution finishes, the next caller for the key starts a new one.
+func GoBatch(name, key string, run runFunc, fallback fallbackFunc) chan error {
+	errChan := make(chan error, 1)
+	k := batchKey{name: name, key: key}
+
+	batchesMutex.Lock()
+	call, running := batches[k]
+	if !running {
+		call = &batchCall{}
+		batches[k] = call
+	}
+	call.errChans = append(call.errChans, errChan)
+	batchesMutex.Unlock()
+
+	if running {
+		return errChan
+	}
+
+	commandErrChan := Go(name, run, fallback)
+	go func() {
+		err := <-commandErrChan
+
+		batchesMutex.Lock()
+		delete(batches, k)
+		batchesMutex.Unlock()
+
+		// no caller can join once the call is removed, so errChans is no longer changing
+		for _, c := range call.errChans {
+			if err != nil {
+				c <- err
+			}
+			close(c)
+		}
+	}()
+
+	return errChan
+}
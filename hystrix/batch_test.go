@@ -0,0 +1,66 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGoBatch(t *testing.T) {
+	Convey("with several callers for the same key while the first is running", t, func() {
+		defer Flush()
+
+		var runs int32
+		release := make(chan struct{})
+		started := make(chan struct{})
+		first := GoBatch("", "key", func() error {
+			atomic.AddInt32(&runs, 1)
+			close(started)
+			<-release
+			return fmt.Errorf("run_error")
+		}, nil)
+		<-started
+
+		var later []chan error
+		for i := 0; i < 4; i++ {
+			later = append(later, GoBatch("", "key", func() error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			}, nil))
+		}
+		other := GoBatch("", "other", func() error {
+			return nil
+		}, nil)
+		close(release)
+
+		Convey("every caller shares the result of one execution", func() {
+			So((<-first).Error(), ShouldEqual, "run_error")
+			for _, errChan := range later {
+				So((<-errChan).Error(), ShouldEqual, "run_error")
+				_, open := <-errChan
+				So(open, ShouldBeFalse)
+			}
+			So(atomic.LoadInt32(&runs), ShouldEqual, 1)
+
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 1)
+		})
+
+		Convey("other keys are executed separately", func() {
+			So(<-other, ShouldBeNil)
+		})
+
+		Convey("a caller arriving after the execution finished starts a new one", func() {
+			<-first
+			err := <-GoBatch("", "key", func() error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			}, nil)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&runs), ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package hystrix
+
+import (
+	"time"
+)
+
+// Breaker decides when a command's circuit trips, for commands configured with a Breaker of
+// their own instead of the default error rate. Allow is asked before each execution, and Success
+// or Failure is told how each execution which was let through turned out, along with how long
+// its run took. Timeouts and rejections for lack of an executor are failures. State is reported
+// by GetState, status handlers and the event stream.
+//
+// ForceOpen and ForceClose still apply, and metrics are still recorded, but the command's
+// health settings, such as ErrorPercentThreshold and SleepWindow, are not used. A Breaker is
+// called concurrently by every execution of its command, so it must be safe for concurrent use.
+type Breaker interface {
+	Allow() bool
+	Success(runDuration time.Duration)
+	Failure(runDuration time.Duration)
+	State() CircuitState
+}
+
+// CircuitBreaker, the default error rate breaker, is itself a Breaker.
+var _ Breaker = &CircuitBreaker{}
+
+// Allow reports whether the circuit allows a request, like AllowRequest.
+func (circuit *CircuitBreaker) Allow() bool {
+	return circuit.AllowRequest()
+}
+
+// Success records an execution whose run succeeded after runDuration.
+func (circuit *CircuitBreaker) Success(runDuration time.Duration) {
+	circuit.report("success", runDuration)
+}
+
+// Failure records an execution whose run failed after runDuration.
+func (circuit *CircuitBreaker) Failure(runDuration time.Duration) {
+	circuit.report("failure", runDuration)
+}
+
+func (circuit *CircuitBreaker) report(eventType string, runDuration time.Duration) {
+	err := circuit.ReportEvent([]string{eventType}, getClock().Now().Add(-runDuration), runDuration)
+	if err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// breaker returns the custom Breaker configured for the circuit's command, or nil.
+func (circuit *CircuitBreaker) breaker() Breaker {
+	return getSettings(circuit.Name).Breaker
+}
+
+// watchBreaker calls f, and then the circuit's state change hooks if the breaker's state
+// changed meanwhile.
+func (circuit *CircuitBreaker) watchBreaker(b Breaker, f func()) {
+	from := b.State()
+	f()
+	if to := b.State(); to != from {
+		circuit.stateChanged(from, to, circuit.metrics.ErrorPercent(getClock().Now()))
+	}
+}
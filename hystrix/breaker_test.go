@@ -0,0 +1,118 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// consecutiveBreaker opens after a number of failures in a row, and closes on the next success.
+type consecutiveBreaker struct {
+	mutex    sync.Mutex
+	limit    int
+	failures int
+	// allowWhileOpen lets a request through an open breaker, to test whether it can close.
+	allowWhileOpen bool
+}
+
+func (b *consecutiveBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.failures < b.limit || b.allowWhileOpen
+}
+
+func (b *consecutiveBreaker) Success(time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures = 0
+}
+
+func (b *consecutiveBreaker) Failure(time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+}
+
+func (b *consecutiveBreaker) State() CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures >= b.limit {
+		return CircuitOpen
+	}
+	return CircuitClosed
+}
+
+func TestCustomBreaker(t *testing.T) {
+	Convey("with a command whose breaker opens after 3 failures in a row", t, func() {
+		defer Flush()
+		breaker := &consecutiveBreaker{limit: 3}
+		ConfigureCommand("", CommandConfig{Breaker: breaker, RequestVolumeThreshold: 100})
+
+		var changes []StateChange
+		var changesMutex sync.Mutex
+		RegisterStateChangeHook("", func(change StateChange) {
+			changesMutex.Lock()
+			changes = append(changes, change)
+			changesMutex.Unlock()
+		})
+
+		fail := func() error { return fmt.Errorf("fail") }
+		for i := 0; i < 3; i++ {
+			So(Do("", fail, nil), ShouldResemble, fmt.Errorf("fail"))
+		}
+		// Do can return before the outcome is reported
+		So(waitForCommands(time.Second), ShouldBeTrue)
+
+		Convey("it opens the circuit whatever the command's health settings", func() {
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(IsOpen(""), ShouldBeTrue)
+			So(Do("", func() error { return nil }, nil), ShouldResemble, ErrCircuitOpen)
+
+			changesMutex.Lock()
+			defer changesMutex.Unlock()
+			So(len(changes), ShouldEqual, 1)
+			So(changes[0].From, ShouldEqual, CircuitClosed)
+			So(changes[0].To, ShouldEqual, CircuitOpen)
+		})
+
+		Convey("a success it lets through closes the circuit", func() {
+			breaker.mutex.Lock()
+			breaker.allowWhileOpen = true
+			breaker.mutex.Unlock()
+
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+			So(waitForCommands(time.Second), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+
+		Convey("forcing the circuit still overrides it", func() {
+			ForceClose("")
+			So(AllowRequest(""), ShouldBeTrue)
+			ClearForced("")
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+
+		Convey("executions are still counted in its metrics", func() {
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 3)
+		})
+	})
+
+	Convey("the default circuit breaker is a Breaker", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2})
+		cb, _, _ := GetCircuit("")
+
+		var breaker Breaker = cb
+		So(breaker.Allow(), ShouldBeTrue)
+		breaker.Failure(time.Millisecond)
+		breaker.Failure(time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+
+		So(breaker.Allow(), ShouldBeFalse)
+		So(breaker.State(), ShouldEqual, CircuitOpen)
+	})
+}
@@ -0,0 +1,93 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type requestCacheContextKey struct{}
+type cacheKeyContextKey struct{}
+
+// requestCache holds the successful results of commands executed with a cache key, for the
+// lifetime of a request.
+type requestCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[requestCacheKey]requestCacheEntry
+}
+
+type requestCacheKey struct {
+	name string
+	key  string
+}
+
+type requestCacheEntry struct {
+	value  interface{}
+	stored time.Time
+}
+
+// WithRequestCache returns a context carrying a new request cache. Commands executed with the
+// returned context, or one derived from it, and a key set by WithCacheKey run only once for each
+// command name and key. Later executions succeed straight away without running, taking an
+// executor or consulting the circuit.
+//
+// Only successful runs are cached. A failed run, or one whose result came from the fallback, is
+// not, so the next execution runs again. With a ttl above zero, cached results expire after ttl.
+//
+// GoC and DoC report nothing but the error, so a cache hit only tells the caller that the
+// command succeeded. Commands which hand back a value through a closure should use DoTypedC,
+// which caches the value returned by run.
+func WithRequestCache(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, requestCacheContextKey{}, &requestCache{
+		ttl:     ttl,
+		entries: make(map[requestCacheKey]requestCacheEntry),
+	})
+}
+
+// WithCacheKey returns a context which caches the result of commands executed with it under key,
+// in the request cache added by WithRequestCache. Without a request cache, the key is ignored.
+//
+// The key does not reach run and fallback functions, so commands they execute with the same
+// context are not cached unless they set a key of their own.
+func WithCacheKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cacheKeyContextKey{}, key)
+}
+
+// requestCacheFor returns the request cache and key which apply to a command executed with ctx,
+// along with ctx without the key. The cache is nil if the command should not be cached.
+func requestCacheFor(ctx context.Context, name string) (*requestCache, requestCacheKey, context.Context) {
+	cache, _ := ctx.Value(requestCacheContextKey{}).(*requestCache)
+	key, _ := ctx.Value(cacheKeyContextKey{}).(string)
+	if key == "" {
+		return nil, requestCacheKey{}, ctx
+	}
+
+	ctx = context.WithValue(ctx, cacheKeyContextKey{}, "")
+	if cache == nil {
+		return nil, requestCacheKey{}, ctx
+	}
+	return cache, requestCacheKey{name: name, key: key}, ctx
+}
+
+func (c *requestCache) get(key requestCacheKey) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && getClock().Now().Sub(entry.stored) >= c.ttl {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *requestCache) set(key requestCacheKey, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[key] = requestCacheEntry{value: value, stored: getClock().Now()}
+}
@@ -0,0 +1,113 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestCache(t *testing.T) {
+	Convey("with a request cache", t, func() {
+		defer Flush()
+		ctx := WithRequestCache(context.Background(), 0)
+
+		runs := 0
+		run := func(ctx context.Context) error {
+			runs++
+			return nil
+		}
+
+		Convey("a command executed twice with the same key runs once", func() {
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 1)
+		})
+
+		Convey("different keys and command names are cached apart", func() {
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "2"), "", run, nil), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "1"), "other", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 3)
+		})
+
+		Convey("commands without a key are not cached", func() {
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+		})
+
+		Convey("a cache hit does not consult the circuit", func() {
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			ForceOpen("")
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 1)
+		})
+
+		Convey("failures and fallbacks are not cached", func() {
+			fail := func(ctx context.Context) error {
+				runs++
+				return fmt.Errorf("run_error")
+			}
+			fallback := func(ctx context.Context, err error) error {
+				return nil
+			}
+			So(DoC(WithCacheKey(ctx, "1"), "", fail, fallback), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "1"), "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+		})
+
+		Convey("the key does not reach commands executed by run", func() {
+			outer := func(ctx context.Context) error {
+				return DoC(ctx, "inner", run, nil)
+			}
+			So(DoC(WithCacheKey(ctx, "1"), "", outer, nil), ShouldBeNil)
+			So(DoC(WithCacheKey(ctx, "2"), "", outer, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+		})
+	})
+
+	Convey("with a request cache whose results expire", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ctx := WithCacheKey(WithRequestCache(context.Background(), 100*time.Millisecond), "1")
+
+		runs := 0
+		run := func(ctx context.Context) error {
+			runs++
+			return nil
+		}
+
+		Convey("the command runs again once the result has expired", func() {
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			clock.Advance(50 * time.Millisecond)
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 1)
+			clock.Advance(50 * time.Millisecond)
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+		})
+	})
+
+	Convey("with a key set but no request cache", t, func() {
+		defer Flush()
+
+		runs := 0
+		run := func(ctx context.Context) error {
+			runs++
+			return nil
+		}
+
+		Convey("nothing is cached", func() {
+			ctx := WithCacheKey(context.Background(), "1")
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(DoC(ctx, "", run, nil), ShouldBeNil)
+			So(runs, ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+)
+
+// capturedValue keeps the value of a command whose run and fallback return one, such as for
+// GoStreamC and DoTypedC, which execute it through functions returning only an error. run keeps
+// going after a timeout, so its value must not overwrite one from the fallback.
+type capturedValue struct {
+	mutex        sync.Mutex
+	value        interface{}
+	fallbackUsed bool
+	runSucceeded bool
+}
+
+// wrapRun returns a run function for the command, which keeps the value run returns when it
+// succeeds before any fallback has been used.
+func (c *capturedValue) wrapRun(run func(context.Context) (interface{}, error)) runFuncC {
+	return func(ctx context.Context) error {
+		v, err := run(ctx)
+
+		c.mutex.Lock()
+		if err == nil && !c.fallbackUsed {
+			c.value = v
+			c.runSucceeded = true
+		}
+		c.mutex.Unlock()
+
+		return err
+	}
+}
+
+// wrapFallback returns a fallback function for the named command, which keeps the value fallback
+// returns in place of run's. Without a fallback, the command's registered or global fallback is
+// used, which has no value to give, so the value is cleared instead. It returns nil when the
+// command has no fallback at all.
+func (c *capturedValue) wrapFallback(name string, fallback func(context.Context, error) (interface{}, error)) fallbackFuncC {
+	if fallback == nil {
+		registered := registeredFallback(name)
+		if registered == nil {
+			return nil
+		}
+		fallback = func(ctx context.Context, err error) (interface{}, error) {
+			return nil, registered(ctx, err)
+		}
+	}
+
+	return func(ctx context.Context, e error) error {
+		c.mutex.Lock()
+		c.fallbackUsed = true
+		c.value = nil
+		c.mutex.Unlock()
+
+		v, err := fallback(ctx, e)
+
+		c.mutex.Lock()
+		c.value = v
+		c.mutex.Unlock()
+
+		return err
+	}
+}
+
+// result returns the command's value, and whether it came from run, so that it may be cached.
+// It must only be called once the command has finished without sending an error.
+func (c *capturedValue) result() (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.value, c.runSucceeded && !c.fallbackUsed
+}
@@ -2,6 +2,7 @@ package hystrix
 
 import (
 	"fmt"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,69 +13,385 @@ import (
 type CircuitBreaker struct {
 	Name                   string
 	open                   bool
+	halfOpen               bool
 	forceOpen              bool
+	forceClosed            bool
 	mutex                  *sync.RWMutex
 	openedOrLastTestedTime int64
+	created                time.Time
+	// stateSince is when the circuit last changed state, or when it was created.
+	stateSince time.Time
+	// halfOpenProbes is how many requests have been let through since the circuit last went
+	// half-open, and halfOpenSuccesses and halfOpenFailures how many of them have reported.
+	halfOpenProbes    int
+	halfOpenSuccesses int
+	halfOpenFailures  int
+	// consecutiveFailures is how many executions have failed in a row while closed, for
+	// commands with a ConsecutiveFailureThreshold.
+	consecutiveFailures int
+	// sleepJitter is added to the sleep window, and is drawn again each time the circuit opens.
+	sleepJitter time.Duration
 
 	executorPool *executorPool
 	metrics      *metricExchange
+	rateLimiter  rateLimiter
+	recovery     recoveryWatch
+}
+
+// CircuitState is the state of a circuit, as decided by its health.
+type CircuitState int
+
+const (
+	// CircuitClosed circuits allow every request.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen circuits reject requests until their sleep window has passed.
+	CircuitOpen
+	// CircuitHalfOpen circuits have let a single request through to test whether they can close.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// StateChange describes a circuit moving from one state to another.
+type StateChange struct {
+	Name string
+	From CircuitState
+	To   CircuitState
+	// Time is when the circuit changed state.
+	Time time.Time
+	// ErrorPercent is the circuit's rolling error percentage just before it changed state.
+	ErrorPercent int
 }
 
 var (
 	circuitBreakersMutex *sync.RWMutex
 	circuitBreakers      map[string]*CircuitBreaker
+
+	stateChangeHooksMutex *sync.RWMutex
+	stateChangeHooks      map[string][]func(StateChange)
+
+	// jitterRand draws sleep window jitter. It is seeded from the time, so that instances
+	// started together draw differently.
+	jitterMutex *sync.Mutex
+	jitterRand  *rand.Rand
 )
 
 func init() {
 	circuitBreakersMutex = &sync.RWMutex{}
 	circuitBreakers = make(map[string]*CircuitBreaker)
+	stateChangeHooksMutex = &sync.RWMutex{}
+	stateChangeHooks = make(map[string][]func(StateChange))
+	jitterMutex = &sync.Mutex{}
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
 }
 
-// GetCircuit returns the circuit for the given command and whether this call created it.
+// RegisterStateChangeHook registers a function to be called each time the named command's circuit
+// changes state. Hooks are called synchronously by the goroutine which caused the change, but
+// without holding any of the circuit's locks, so they may safely query the circuit.
+func RegisterStateChangeHook(name string, hook func(StateChange)) {
+	name = canonicalName(name)
+	stateChangeHooksMutex.Lock()
+	defer stateChangeHooksMutex.Unlock()
+
+	stateChangeHooks[name] = append(stateChangeHooks[name], hook)
+}
+
+// GetCircuit returns the circuit for the given command and whether this call created it. Every
+// call returns the same circuit until Flush, or until SetMaxCommands evicts it, so it may be kept
+// and used instead of looking the command up by name each time.
 func GetCircuit(name string) (*CircuitBreaker, bool, error) {
+	name = canonicalName(name)
 	circuitBreakersMutex.RLock()
 	_, ok := circuitBreakers[name]
 	if !ok {
 		circuitBreakersMutex.RUnlock()
 		circuitBreakersMutex.Lock()
-		defer circuitBreakersMutex.Unlock()
 		// because we released the rlock before we obtained the exclusive lock,
 		// we need to double check that some other thread didn't beat us to
 		// creation.
 		if cb, ok := circuitBreakers[name]; ok {
+			useCircuit(name)
+			circuitBreakersMutex.Unlock()
 			return cb, false, nil
 		}
-		circuitBreakers[name] = newCircuitBreaker(name)
-	} else {
-		defer circuitBreakersMutex.RUnlock()
+		cb := newCircuitBreaker(name)
+		circuitBreakers[name] = cb
+		evicted := evictNewCircuitLocked(name)
+		circuitBreakersMutex.Unlock()
+
+		stopCircuits(evicted)
+		return cb, true, nil
+	}
+	defer circuitBreakersMutex.RUnlock()
+
+	useCircuit(name)
+	return circuitBreakers[name], false, nil
+}
+
+// lookupCircuit returns the circuit for the given command without creating one.
+func lookupCircuit(name string) (*CircuitBreaker, bool) {
+	circuitBreakersMutex.RLock()
+	defer circuitBreakersMutex.RUnlock()
+
+	cb, ok := circuitBreakers[canonicalName(name)]
+	return cb, ok
+}
+
+// IsOpen reports whether the circuit for the given command is open. A command which has not
+// been executed yet has no circuit, and is reported as closed.
+func IsOpen(name string) bool {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return false
 	}
 
-	return circuitBreakers[name], !ok, nil
+	return cb.IsOpen()
 }
 
-// Flush purges all circuit and metric information from memory.
+// GetState returns the state of the circuit for the given command, which unlike IsOpen tells a
+// half-open circuit from an open one. Like CircuitBreaker.State it ignores ForceOpen and
+// ForceClose. A command which has not been executed yet has no circuit, and is reported as
+// closed.
+func GetState(name string) CircuitState {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return CircuitClosed
+	}
+
+	return cb.State()
+}
+
+// StateSince returns the state of the circuit for the given command like GetState, along with
+// when it moved into that state, such as to alert on a circuit which has stayed open. A command
+// which has not been executed yet is reported as closed since the zero time.
+func StateSince(name string) (CircuitState, time.Time) {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return CircuitClosed, time.Time{}
+	}
+
+	return cb.StateSince()
+}
+
+// AllowRequest reports whether the circuit for the given command would allow a request. When the
+// circuit is open and its sleep window has passed, this consumes the single test request which
+// would otherwise go to the next command. A command which has not been executed yet has no
+// circuit, and always allows requests.
+func AllowRequest(name string) bool {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return true
+	}
+
+	return cb.AllowRequest()
+}
+
+// Outcome is the result of an execution reported with ReportEvent.
+type Outcome string
+
+const (
+	// OutcomeSuccess records an execution which succeeded.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure records an execution which failed.
+	OutcomeFailure Outcome = "failure"
+	// OutcomeTimeout records an execution which took too long.
+	OutcomeTimeout Outcome = "timeout"
+	// OutcomeRejected records an execution which was turned away for lack of capacity.
+	OutcomeRejected Outcome = "rejected"
+)
+
+// ReportEvent records the outcome of an execution which was not run through Go or Do, such as a
+// stream whose success is only known once it ends. The circuit and its metrics treat it exactly
+// like an execution of the named command whose run took duration. Combined with AllowRequest,
+// this lets a circuit be used as a standalone breaker.
+func ReportEvent(name string, outcome Outcome, duration time.Duration) error {
+	switch outcome {
+	case OutcomeSuccess, OutcomeFailure, OutcomeTimeout, OutcomeRejected:
+	default:
+		return fmt.Errorf("unknown outcome %q", string(outcome))
+	}
+
+	name = canonicalName(name)
+	circuit, _, err := GetCircuit(name)
+	if err != nil {
+		return err
+	}
+
+	emitEvent(Event{Name: name, Type: string(outcome), Duration: duration, Tags: getSettings(name).Tags})
+	return circuit.ReportEvent([]string{string(outcome)}, getClock().Now().Add(-duration), duration)
+}
+
+// Flush purges all circuits, metrics, command settings, state change and recovery hooks,
+// registered and global fallbacks, run middleware, event listeners and disabled commands from
+// memory, so the next execution of any command starts from a new circuit with default settings,
+// and lets commands run again after Shutdown. It is intended for tests, and should not be called
+// while commands are running.
 func Flush() {
 	circuitBreakersMutex.Lock()
 	defer circuitBreakersMutex.Unlock()
 
 	for name, cb := range circuitBreakers {
+		cb.metrics.stop()
+		cb.executorPool.Metrics.stop()
 		cb.metrics.Reset()
 		cb.executorPool.Metrics.Reset()
 		delete(circuitBreakers, name)
 	}
+	groupPools = make(map[string]*executorPool)
+
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	circuitSettings = make(map[string]*Settings)
+
+	stateChangeHooksMutex.Lock()
+	defer stateChangeHooksMutex.Unlock()
+
+	stateChangeHooks = make(map[string][]func(StateChange))
+
+	flushRecoveryHooks()
+	flushEventListeners()
+	flushFallbacks()
+	flushResultChecks()
+	flushRejectedHook()
+	flushDisabled()
+	flushMiddlewares()
+	flushGroupErrorThresholds()
+	flushAliases()
+	flushRecentCircuits()
+	resetShutdown()
+}
+
+// ResetCircuit gives the given command a clean slate, such as after its dependency has been fixed. Its
+// circuit is closed, ForceOpen and ForceClose are cleared, and its rolling metrics are zeroed.
+// Unlike Flush it leaves every other command alone, keeps the command's settings, and is safe to
+// call while the command is running. Executions which finish during the reset may be counted
+// before or after it. A command which has not been executed yet has nothing to reset.
+func ResetCircuit(name string) {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return
+	}
+
+	cb.setForced(false, false)
+	cb.setClose()
+	cb.metrics.Reset()
+}
+
+// ForceHalfOpen moves the circuit for the given command to half-open straight away, without
+// waiting for its sleep window, so recovery can be tested deterministically. ForceOpen and
+// ForceClose are cleared, and from then on the circuit lets probes through and closes or re-opens
+// on their outcomes as it would after a sleep window. It fails with ErrUnknownCommand for a
+// command which has not been executed yet, and with ErrCustomBreaker for a command with its own
+// Breaker.
+func ForceHalfOpen(name string) error {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return ErrUnknownCommand
+	}
+	if cb.breaker() != nil {
+		return ErrCustomBreaker
+	}
+
+	cb.mutex.Lock()
+	from := CircuitClosed
+	if cb.halfOpen {
+		from = CircuitHalfOpen
+	} else if cb.open {
+		from = CircuitOpen
+	}
+	cb.forceOpen = false
+	cb.forceClosed = false
+	cb.open = true
+	cb.halfOpen = true
+	cb.halfOpenProbes = 0
+	cb.halfOpenSuccesses = 0
+	cb.halfOpenFailures = 0
+	cb.consecutiveFailures = 0
+	// the sleep window starts again, so the probes come from allowProbe rather than a single test
+	cb.openedOrLastTestedTime = getClock().Now().UnixNano()
+	cb.mutex.Unlock()
+
+	log.Infof("hystrix-go: forcing circuit %v half-open", name)
+	if from != CircuitHalfOpen {
+		cb.stateChanged(from, CircuitHalfOpen, cb.metrics.ErrorPercent(getClock().Now()))
+	}
+	return nil
+}
+
+// ProbeEarly ends the sleep window of the given command's open circuit, such as when an external
+// health check finds its dependency healthy again, so the next execution is let through as the
+// test request rather than waiting out SleepWindow. Only that one execution is let through, as
+// after a sleep window, and its outcome closes or re-opens the circuit. A circuit which is closed,
+// already half-open or forced open is left alone. It fails with ErrUnknownCommand for a command
+// which has not been executed yet, and with ErrCustomBreaker for a command with its own Breaker.
+func ProbeEarly(name string) error {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return ErrUnknownCommand
+	}
+	if cb.breaker() != nil {
+		return ErrCustomBreaker
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if !cb.open || cb.halfOpen || cb.forceOpen {
+		return nil
+	}
+	log.Infof("hystrix-go: ending sleep window of circuit %v early", name)
+	// trySingleTest still lets only the first execution through, by swapping this for its start
+	atomic.StoreInt64(&cb.openedOrLastTestedTime, 0)
+	return nil
 }
 
 // newCircuitBreaker creates a CircuitBreaker with associated Health
 func newCircuitBreaker(name string) *CircuitBreaker {
+	if !RequireRegistration {
+		useSettings(name)
+	}
 	c := &CircuitBreaker{}
 	c.Name = name
 	c.metrics = newMetricExchange(name)
-	c.executorPool = newExecutorPool(name)
+	c.executorPool = executorPoolForCommand(name)
 	c.mutex = &sync.RWMutex{}
+	c.created = getClock().Now()
+	c.stateSince = c.created
 
 	return c
 }
 
+// ForceOpen forces the circuit for the given command open, so every execution goes straight to
+// its fallback until ForceClose or ClearForced is called.
+func ForceOpen(name string) {
+	cb, _, _ := GetCircuit(name)
+	cb.setForced(true, false)
+}
+
+// ForceClose forces the circuit for the given command closed, so every execution is attempted
+// regardless of its health until ForceOpen or ClearForced is called.
+func ForceClose(name string) {
+	cb, _, _ := GetCircuit(name)
+	cb.setForced(false, true)
+}
+
+// ClearForced returns the circuit for the given command to being opened and closed by its health.
+func ClearForced(name string) {
+	cb, _, _ := GetCircuit(name)
+	cb.setForced(false, false)
+}
+
 // toggleForceOpen allows manually causing the fallback logic for all instances
 // of a given command.
 func (circuit *CircuitBreaker) toggleForceOpen(toggle bool) error {
@@ -83,26 +400,66 @@ func (circuit *CircuitBreaker) toggleForceOpen(toggle bool) error {
 		return err
 	}
 
-	circuit.forceOpen = toggle
+	circuit.setForced(toggle, false)
 	return nil
 }
 
+func (circuit *CircuitBreaker) setForced(forceOpen, forceClosed bool) {
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+
+	circuit.forceOpen = forceOpen
+	circuit.forceClosed = forceClosed
+}
+
+// forced returns whether the circuit has been forced open or closed.
+func (circuit *CircuitBreaker) forced() (forceOpen, forceClosed bool) {
+	circuit.mutex.RLock()
+	defer circuit.mutex.RUnlock()
+
+	return circuit.forceOpen, circuit.forceClosed
+}
+
 // IsOpen is called before any Command execution to check whether or
 // not it should be attempted. An "open" circuit means it is disabled.
 func (circuit *CircuitBreaker) IsOpen() bool {
 	circuit.mutex.RLock()
-	o := circuit.forceOpen || circuit.open
+	forceOpen := circuit.forceOpen
+	o := forceOpen || circuit.open
+	forceClosed := circuit.forceClosed
 	circuit.mutex.RUnlock()
 
+	if forceClosed {
+		// a forced closed circuit ignores its health entirely
+		return false
+	}
+	if b := circuit.breaker(); b != nil {
+		return forceOpen || b.State() != CircuitClosed
+	}
 	if o {
 		return true
 	}
 
-	if uint64(circuit.metrics.Requests().Sum(time.Now())) < getSettings(circuit.Name).RequestVolumeThreshold {
+	if getSettings(circuit.Name).ConsecutiveFailureThreshold > 0 {
+		// the circuit is opened by reportConsecutive instead
 		return false
 	}
 
-	if !circuit.metrics.IsHealthy(time.Now()) {
+	now := getClock().Now()
+	settings := getSettings(circuit.Name)
+	health := circuit.metrics.Health(now)
+	circuit.watchRecovery(health.ErrorPercentage, settings, now)
+
+	if now.Sub(circuit.created) < settings.Warmup {
+		// still warming up, so early failures should not count against the circuit
+		return false
+	}
+
+	if health.Total < settings.RequestVolumeThreshold {
+		return false
+	}
+
+	if !circuit.metrics.IsHealthy(getClock().Now()) {
 		// too many failures, open the circuit
 		circuit.setOpen()
 		return true
@@ -111,23 +468,201 @@ func (circuit *CircuitBreaker) IsOpen() bool {
 	return false
 }
 
+// State returns the state of the circuit as decided by its health, ignoring ForceOpen and ForceClose.
+func (circuit *CircuitBreaker) State() CircuitState {
+	if b := circuit.breaker(); b != nil {
+		return b.State()
+	}
+
+	circuit.mutex.RLock()
+	defer circuit.mutex.RUnlock()
+
+	if circuit.halfOpen {
+		return CircuitHalfOpen
+	}
+	if circuit.open {
+		return CircuitOpen
+	}
+	return CircuitClosed
+}
+
+// StateSince returns the state of the circuit like State, along with when it moved into that
+// state. A circuit which has never changed state reports when it was created.
+func (circuit *CircuitBreaker) StateSince() (CircuitState, time.Time) {
+	state := circuit.State()
+
+	circuit.mutex.RLock()
+	defer circuit.mutex.RUnlock()
+
+	return state, circuit.stateSince
+}
+
+// Metrics returns a snapshot of the circuit's rolling metrics, like GetMetrics.
+func (circuit *CircuitBreaker) Metrics() Metrics {
+	now := getClock().Now()
+	metrics := circuit.metrics.Snapshot(now)
+	metrics.Concurrency = circuit.executorPool.Metrics.concurrency(now)
+	metrics.Queue = circuit.executorPool.queue(now)
+	return metrics
+}
+
+// Settings returns the settings of the circuit's command after defaults have been applied.
+func (circuit *CircuitBreaker) Settings() Settings {
+	return *getSettings(circuit.Name)
+}
+
 // AllowRequest is checked before a command executes, ensuring that circuit state and metric health allow it.
 // When the circuit is open, this call will occasionally return true to measure whether the external service
 // has recovered.
 func (circuit *CircuitBreaker) AllowRequest() bool {
-	return !circuit.IsOpen() || circuit.allowSingleTest()
+	if b := circuit.breaker(); b != nil {
+		forceOpen, forceClosed := circuit.forced()
+		if forceClosed || forceOpen {
+			return forceClosed
+		}
+
+		allowed := false
+		circuit.watchBreaker(b, func() {
+			allowed = b.Allow()
+		})
+		return allowed
+	}
+
+	return !circuit.IsOpen() || circuit.allowSingleTest() || circuit.allowProbe()
 }
 
 func (circuit *CircuitBreaker) allowSingleTest() bool {
+	if !circuit.trySingleTest() {
+		return false
+	}
+
+	circuit.mutex.Lock()
+	wasHalfOpen := circuit.halfOpen
+	circuit.halfOpen = circuit.open
+	circuit.halfOpenProbes = 1
+	circuit.halfOpenSuccesses = 0
+	circuit.halfOpenFailures = 0
+	circuit.mutex.Unlock()
+
+	if !wasHalfOpen {
+		circuit.stateChanged(CircuitOpen, CircuitHalfOpen, circuit.metrics.ErrorPercent(getClock().Now()))
+	}
+	return true
+}
+
+// allowProbe lets another request through a half-open circuit, until HalfOpenMaxRequests have
+// been let through since it went half-open, or HalfOpenMinSamples if that is more.
+func (circuit *CircuitBreaker) allowProbe() bool {
+	settings := getSettings(circuit.Name)
+	max := settings.HalfOpenMaxRequests
+	if settings.HalfOpenMinSamples > max {
+		max = settings.HalfOpenMinSamples
+	}
+	if settings.ConsecutiveFailureThreshold > 0 && settings.ConsecutiveSuccessThreshold > max {
+		// enough probes to close the circuit within one sleep window
+		max = settings.ConsecutiveSuccessThreshold
+	}
+
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+
+	if !circuit.halfOpen || circuit.forceOpen || circuit.halfOpenProbes >= max {
+		return false
+	}
+	circuit.halfOpenProbes++
+	return true
+}
+
+// releaseProbe gives back the slot of a probe which ended without an outcome, because its
+// caller's context was done, so that another request may probe in its place.
+func (circuit *CircuitBreaker) releaseProbe() {
+	circuit.mutex.Lock()
+	defer circuit.mutex.Unlock()
+
+	if circuit.halfOpen && circuit.halfOpenProbes > 0 {
+		circuit.halfOpenProbes--
+	}
+}
+
+// reportProbe records the outcome of a request let through a half-open circuit. The circuit
+// re-opens as soon as ErrorPercentThreshold percent of HalfOpenMaxRequests probes have failed,
+// and closes as soon as enough have succeeded that the rest cannot reach it. With a
+// ConsecutiveFailureThreshold, it instead re-opens on any failure, and closes once
+// ConsecutiveSuccessThreshold probes have succeeded. Either way, a HalfOpenMinSamples above one
+// re-opens it on any failure, and keeps it half-open until that many probes have succeeded.
+func (circuit *CircuitBreaker) reportProbe(success bool) {
+	settings := getSettings(circuit.Name)
+	probes := settings.HalfOpenMaxRequests
+	threshold := settings.ErrorPercentThreshold
+
+	circuit.mutex.Lock()
+	if !circuit.halfOpen {
+		circuit.mutex.Unlock()
+		return
+	}
+	if success {
+		circuit.halfOpenSuccesses++
+	} else {
+		circuit.halfOpenFailures++
+	}
+	reopen := circuit.halfOpenFailures*100 >= threshold*probes
+	close := circuit.halfOpenSuccesses*100 > (100-threshold)*probes
+	if settings.ConsecutiveFailureThreshold > 0 {
+		reopen = circuit.halfOpenFailures > 0
+		close = circuit.halfOpenSuccesses >= settings.ConsecutiveSuccessThreshold
+	}
+	if settings.HalfOpenMinSamples > 1 {
+		reopen = circuit.halfOpenFailures > 0
+		close = close && circuit.halfOpenSuccesses >= settings.HalfOpenMinSamples
+	}
+	circuit.mutex.Unlock()
+
+	if reopen {
+		circuit.setReopen()
+	} else if close {
+		circuit.setClose()
+	}
+}
+
+// reportConsecutive counts the failures in a row of a closed circuit with a
+// ConsecutiveFailureThreshold, opening it once they reach the threshold. A success starts the
+// count again.
+func (circuit *CircuitBreaker) reportConsecutive(eventType string) {
+	threshold := getSettings(circuit.Name).ConsecutiveFailureThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	circuit.mutex.Lock()
+	switch eventType {
+	case "success":
+		circuit.consecutiveFailures = 0
+	case "failure", "timeout", "rejected":
+		circuit.consecutiveFailures++
+	}
+	trip := circuit.consecutiveFailures >= threshold
+	circuit.mutex.Unlock()
+
+	if trip {
+		circuit.setOpen()
+	}
+}
+
+func (circuit *CircuitBreaker) trySingleTest() bool {
 	circuit.mutex.RLock()
 	defer circuit.mutex.RUnlock()
 
-	now := time.Now().UnixNano()
+	if circuit.forceOpen {
+		return false
+	}
+
+	now := getClock().Now().UnixNano()
 	openedOrLastTestedTime := atomic.LoadInt64(&circuit.openedOrLastTestedTime)
-	if circuit.open && now > openedOrLastTestedTime+getSettings(circuit.Name).SleepWindow.Nanoseconds() {
+	sleep := getSettings(circuit.Name).SleepWindow + circuit.sleepJitter
+	if circuit.open && now > openedOrLastTestedTime+sleep.Nanoseconds() {
 		swapped := atomic.CompareAndSwapInt64(&circuit.openedOrLastTestedTime, openedOrLastTestedTime, now)
 		if swapped {
-			log.Printf("hystrix-go: allowing single test to possibly close circuit %v", circuit.Name)
+			log.Infof("hystrix-go: allowing single test to possibly close circuit %v", circuit.Name)
 		}
 		return swapped
 	}
@@ -135,45 +670,142 @@ func (circuit *CircuitBreaker) allowSingleTest() bool {
 	return false
 }
 
+// sleepJitter returns a random wait of less than max to add to a sleep window, or zero if max
+// is not above zero.
+func sleepJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	jitterMutex.Lock()
+	defer jitterMutex.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(max)))
+}
+
 func (circuit *CircuitBreaker) setOpen() {
 	circuit.mutex.Lock()
-	defer circuit.mutex.Unlock()
-
 	if circuit.open {
+		circuit.mutex.Unlock()
 		return
 	}
 
-	log.Printf("hystrix-go: opening circuit %v", circuit.Name)
+	log.Warnf("hystrix-go: opening circuit %v", circuit.Name)
 
-	circuit.openedOrLastTestedTime = time.Now().UnixNano()
+	circuit.openedOrLastTestedTime = getClock().Now().UnixNano()
 	circuit.open = true
+	circuit.consecutiveFailures = 0
+	circuit.sleepJitter = sleepJitter(getSettings(circuit.Name).SleepWindowJitter)
+	circuit.mutex.Unlock()
+	circuit.recovery.reset()
+
+	circuit.stateChanged(CircuitClosed, CircuitOpen, circuit.metrics.ErrorPercent(getClock().Now()))
 }
 
-func (circuit *CircuitBreaker) setClose() {
+// setReopen returns a half-open circuit to open after its test requests failed, restarting the
+// sleep window.
+func (circuit *CircuitBreaker) setReopen() {
 	circuit.mutex.Lock()
-	defer circuit.mutex.Unlock()
+	if !circuit.halfOpen {
+		circuit.mutex.Unlock()
+		return
+	}
+
+	log.Warnf("hystrix-go: test request failed, keeping circuit %v open", circuit.Name)
+
+	circuit.openedOrLastTestedTime = getClock().Now().UnixNano()
+	circuit.halfOpen = false
+	circuit.sleepJitter = sleepJitter(getSettings(circuit.Name).SleepWindowJitter)
+	circuit.mutex.Unlock()
+
+	circuit.stateChanged(CircuitHalfOpen, CircuitOpen, circuit.metrics.ErrorPercent(getClock().Now()))
+}
 
+func (circuit *CircuitBreaker) setClose() {
+	circuit.mutex.Lock()
 	if !circuit.open {
+		circuit.mutex.Unlock()
 		return
 	}
 
-	log.Printf("hystrix-go: closing circuit %v", circuit.Name)
+	log.Infof("hystrix-go: closing circuit %v", circuit.Name)
+
+	from := CircuitOpen
+	if circuit.halfOpen {
+		from = CircuitHalfOpen
+	}
+	errorPercent := circuit.metrics.ErrorPercent(getClock().Now())
 
 	circuit.open = false
+	circuit.halfOpen = false
 	circuit.metrics.Reset()
+	circuit.mutex.Unlock()
+
+	circuit.stateChanged(from, CircuitClosed, errorPercent)
+}
+
+// stateChanged records when the circuit changed state and calls the hooks registered for it. It
+// must not be called while holding the circuit's mutex.
+func (circuit *CircuitBreaker) stateChanged(from, to CircuitState, errorPercent int) {
+	stateChangeHooksMutex.RLock()
+	hooks := stateChangeHooks[circuit.Name]
+	stateChangeHooksMutex.RUnlock()
+
+	change := StateChange{
+		Name:         circuit.Name,
+		From:         from,
+		To:           to,
+		Time:         getClock().Now(),
+		ErrorPercent: errorPercent,
+	}
+
+	circuit.mutex.Lock()
+	circuit.stateSince = change.Time
+	circuit.mutex.Unlock()
+
+	for _, hook := range hooks {
+		hook(change)
+	}
 }
 
 // ReportEvent records command metrics for tracking recent error rates and exposing data to the dashboard.
 func (circuit *CircuitBreaker) ReportEvent(eventTypes []string, start time.Time, runDuration time.Duration) error {
+	return circuit.reportEvent(eventTypes, start, runDuration, getSettings(circuit.Name).Tags)
+}
+
+// reportEvent records command metrics like ReportEvent, labeled with tags.
+func (circuit *CircuitBreaker) reportEvent(eventTypes []string, start time.Time, runDuration time.Duration, tags map[string]string) error {
 	if len(eventTypes) == 0 {
 		return fmt.Errorf("no event types sent for metrics")
 	}
 
-	circuit.mutex.RLock()
-	o := circuit.open
-	circuit.mutex.RUnlock()
-	if eventTypes[0] == "success" && o {
-		circuit.setClose()
+	if b := circuit.breaker(); b != nil {
+		switch eventTypes[0] {
+		case "success":
+			circuit.watchBreaker(b, func() {
+				b.Success(runDuration)
+			})
+		case "failure", "timeout", "rejected":
+			circuit.watchBreaker(b, func() {
+				b.Failure(runDuration)
+			})
+		}
+	} else {
+		circuit.mutex.RLock()
+		o := circuit.open
+		h := circuit.halfOpen
+		circuit.mutex.RUnlock()
+		canceled := eventTypes[0] == "context_canceled" || eventTypes[0] == "context_deadline_exceeded"
+		if h && canceled {
+			// the caller gave up, which says nothing about the dependency's health
+			circuit.releaseProbe()
+		} else if h && eventTypes[0] != "short-circuit" && eventTypes[0] != "rate-limited" {
+			circuit.reportProbe(eventTypes[0] == "success")
+		} else if eventTypes[0] == "success" && o {
+			circuit.setClose()
+		} else if !o {
+			circuit.reportConsecutive(eventTypes[0])
+		}
 	}
 
 	var concurrencyInUse float64
@@ -187,6 +819,9 @@ func (circuit *CircuitBreaker) ReportEvent(eventTypes []string, start time.Time,
 		Start:            start,
 		RunDuration:      runDuration,
 		ConcurrencyInUse: concurrencyInUse,
+		QueueDepth:       circuit.executorPool.QueueDepth(),
+		Tags:             tags,
+		CircuitState:     circuit.State().String(),
 	}:
 	default:
 		return CircuitError{Message: fmt.Sprintf("metrics channel (%v) is at capacity", circuit.Name)}
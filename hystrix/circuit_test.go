@@ -1,6 +1,8 @@
 package hystrix
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -71,6 +73,635 @@ func TestMultithreadedGetCircuit(t *testing.T) {
 	})
 }
 
+func TestIsOpenAndAllowRequest(t *testing.T) {
+	Convey("for a command which has never been executed", t, func() {
+		defer Flush()
+
+		Convey("the circuit is reported closed and allows requests", func() {
+			So(IsOpen("unknown"), ShouldBeFalse)
+			So(GetState("unknown"), ShouldEqual, CircuitClosed)
+			So(AllowRequest("unknown"), ShouldBeTrue)
+
+			_, exists := lookupCircuit("unknown")
+			So(exists, ShouldBeFalse)
+		})
+	})
+
+	Convey("when a circuit is open", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{SleepWindow: 50})
+
+		cb, _, err := GetCircuit("")
+		So(err, ShouldBeNil)
+		cb.setOpen()
+
+		Convey("it is reported open and rejects requests", func() {
+			So(IsOpen(""), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+
+		Convey("after the sleep window a single request is allowed", func() {
+			time.Sleep(60 * time.Millisecond)
+			So(AllowRequest(""), ShouldBeTrue)
+			So(AllowRequest(""), ShouldBeFalse)
+			So(IsOpen(""), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+			So(GetState("").String(), ShouldEqual, "half-open")
+		})
+	})
+}
+
+func TestCircuitObject(t *testing.T) {
+	Convey("with a circuit kept by its caller", t, func() {
+		defer Flush()
+		ConfigureCommand("kept", CommandConfig{Timeout: 300, RequestVolumeThreshold: 2})
+		cb, _, _ := GetCircuit("kept")
+
+		Convey("later lookups return the same circuit", func() {
+			again, created, _ := GetCircuit("kept")
+			So(created, ShouldBeFalse)
+			So(again, ShouldEqual, cb)
+		})
+
+		Convey("it reports the command's settings", func() {
+			So(cb.Settings().Timeout, ShouldEqual, 300*time.Millisecond)
+			So(cb.Settings().RequestVolumeThreshold, ShouldEqual, 2)
+		})
+
+		Convey("events reported on it are counted and can open it", func() {
+			So(cb.AllowRequest(), ShouldBeTrue)
+			So(cb.ReportEvent([]string{"failure"}, time.Now(), time.Millisecond), ShouldBeNil)
+			So(cb.ReportEvent([]string{"failure"}, time.Now(), time.Millisecond), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			So(cb.Metrics().Failures, ShouldEqual, 2)
+			So(cb.Metrics(), ShouldResemble, GetMetrics("kept"))
+			So(cb.AllowRequest(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestHalfOpenMaxRequests(t *testing.T) {
+	Convey("with an open circuit which lets 4 probes through once half-open", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{SleepWindow: 50, HalfOpenMaxRequests: 4, ErrorPercentThreshold: 50})
+
+		cb, _, _ := GetCircuit("")
+		cb.setOpen()
+		clock.Advance(60 * time.Millisecond)
+
+		Convey("exactly 4 requests are let through", func() {
+			for i := 0; i < 4; i++ {
+				So(AllowRequest(""), ShouldBeTrue)
+			}
+			So(AllowRequest(""), ShouldBeFalse)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+		})
+
+		Convey("it closes once 3 of them succeed", func() {
+			for i := 0; i < 4; i++ {
+				AllowRequest("")
+			}
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+
+		Convey("it re-opens once 2 of them fail", func() {
+			for i := 0; i < 4; i++ {
+				AllowRequest("")
+			}
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+
+			Convey("and probes again after another sleep window", func() {
+				clock.Advance(60 * time.Millisecond)
+				So(AllowRequest(""), ShouldBeTrue)
+				So(GetState(""), ShouldEqual, CircuitHalfOpen)
+			})
+		})
+	})
+
+	Convey("by default a single probe decides", t, func() {
+		defer Flush()
+		So(getSettings("").HalfOpenMaxRequests, ShouldEqual, 1)
+		So(getSettings("").HalfOpenMinSamples, ShouldEqual, 1)
+	})
+}
+
+func TestHalfOpenMinSamples(t *testing.T) {
+	Convey("with an open circuit which needs 3 probe samples to close", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{SleepWindow: 50, HalfOpenMinSamples: 3})
+
+		cb, _, _ := GetCircuit("")
+		cb.setOpen()
+		clock.Advance(60 * time.Millisecond)
+
+		Convey("3 requests are let through", func() {
+			for i := 0; i < 3; i++ {
+				So(AllowRequest(""), ShouldBeTrue)
+			}
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+
+		Convey("it stays half-open until all 3 succeed", func() {
+			for i := 0; i < 3; i++ {
+				AllowRequest("")
+			}
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+
+		Convey("it re-opens on the first failure", func() {
+			for i := 0; i < 3; i++ {
+				AllowRequest("")
+			}
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitOpen)
+		})
+	})
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	Convey("with a circuit which opens after 3 failures in a row and closes after 2 successes", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{
+			SleepWindow:                 50,
+			ConsecutiveFailureThreshold: 3,
+			ConsecutiveSuccessThreshold: 2,
+			RequestVolumeThreshold:      1,
+		})
+
+		Convey("a success in between starts the count again", func() {
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			// the error percentage alone would have tripped it by now
+			So(AllowRequest(""), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+
+		Convey("once open", func() {
+			for i := 0; i < 3; i++ {
+				So(ReportEvent("", OutcomeTimeout, time.Millisecond), ShouldBeNil)
+			}
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+			clock.Advance(60 * time.Millisecond)
+
+			Convey("it lets 2 probes through and closes once both succeed", func() {
+				So(AllowRequest(""), ShouldBeTrue)
+				So(AllowRequest(""), ShouldBeTrue)
+				So(AllowRequest(""), ShouldBeFalse)
+
+				So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+				So(GetState(""), ShouldEqual, CircuitHalfOpen)
+				So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+				So(GetState(""), ShouldEqual, CircuitClosed)
+			})
+
+			Convey("it re-opens as soon as a probe fails", func() {
+				So(AllowRequest(""), ShouldBeTrue)
+				So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+				So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+				So(GetState(""), ShouldEqual, CircuitOpen)
+			})
+		})
+	})
+}
+
+func TestCloseClearsMetrics(t *testing.T) {
+	Convey("when a circuit opened by failures closes again", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{SleepWindow: 50, RequestVolumeThreshold: 2, ErrorPercentThreshold: 60})
+
+		for i := 0; i < 3; i++ {
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		}
+		time.Sleep(10 * time.Millisecond)
+		So(AllowRequest(""), ShouldBeFalse)
+
+		clock.Advance(60 * time.Millisecond)
+		So(AllowRequest(""), ShouldBeTrue)
+		So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+		So(GetState(""), ShouldEqual, CircuitClosed)
+
+		Convey("the failures from before it opened no longer count", func() {
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.ErrorPercent(clock.Now()), ShouldEqual, 0)
+
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			So(AllowRequest(""), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+	})
+}
+
+func TestResetCircuit(t *testing.T) {
+	Convey("with two commands whose circuits have opened", t, func() {
+		defer Flush()
+		for _, name := range []string{"broken", "other"} {
+			ConfigureCommand(name, CommandConfig{RequestVolumeThreshold: 2})
+			So(ReportEvent(name, OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(ReportEvent(name, OutcomeFailure, time.Millisecond), ShouldBeNil)
+		}
+		time.Sleep(10 * time.Millisecond)
+		So(IsOpen("broken"), ShouldBeTrue)
+		So(IsOpen("other"), ShouldBeTrue)
+		ForceOpen("broken")
+
+		ResetCircuit("broken")
+
+		Convey("only the reset command is closed, unforced and has no metrics", func() {
+			So(IsOpen("broken"), ShouldBeFalse)
+			So(GetState("broken"), ShouldEqual, CircuitClosed)
+			So(GetMetrics("broken").Failures, ShouldEqual, 0)
+			So(GetHealth("broken").Total, ShouldEqual, 0)
+
+			So(IsOpen("other"), ShouldBeTrue)
+			So(GetMetrics("other").Failures, ShouldEqual, 2)
+		})
+	})
+
+	Convey("resetting a command while it runs", t, func() {
+		defer Flush()
+		ConfigureCommand("busy", CommandConfig{MaxConcurrentRequests: 100})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				ResetCircuit("busy")
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			Go("busy", func() error { return nil }, nil)
+		}
+		<-done
+
+		Convey("does not disturb the executions", func() {
+			So(waitForCommands(time.Second), ShouldBeTrue)
+		})
+	})
+
+	Convey("resetting a command which has never run does nothing", t, func() {
+		defer Flush()
+		ResetCircuit("unknown")
+		_, exists := lookupCircuit("unknown")
+		So(exists, ShouldBeFalse)
+	})
+}
+
+func TestForceHalfOpen(t *testing.T) {
+	Convey("with a command whose circuit has opened", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2, SleepWindow: 60000})
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		So(IsOpen(""), ShouldBeTrue)
+
+		var changes []StateChange
+		RegisterStateChangeHook("", func(c StateChange) {
+			changes = append(changes, c)
+		})
+
+		So(ForceHalfOpen(""), ShouldBeNil)
+
+		Convey("it is half-open without waiting for the sleep window", func() {
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+			So(changes, ShouldHaveLength, 1)
+			So(changes[0].From, ShouldEqual, CircuitOpen)
+			So(changes[0].To, ShouldEqual, CircuitHalfOpen)
+		})
+
+		Convey("it lets a single probe through", func() {
+			So(AllowRequest(""), ShouldBeTrue)
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+
+		Convey("a probe which succeeds closes it", func() {
+			So(AllowRequest(""), ShouldBeTrue)
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitClosed)
+		})
+
+		Convey("a probe which fails re-opens it", func() {
+			So(AllowRequest(""), ShouldBeTrue)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+
+		Convey("a probe canceled by its caller leaves it half-open for another probe", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			err := DoC(ctx, "", func(ctx context.Context) error {
+				cancel()
+				<-ctx.Done()
+				return ctx.Err()
+			}, nil)
+			So(err, ShouldEqual, context.Canceled)
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+			So(AllowRequest(""), ShouldBeTrue)
+		})
+	})
+
+	Convey("with a command which is forced open", t, func() {
+		defer Flush()
+		ForceOpen("forced")
+
+		Convey("forcing it half-open clears the forcing", func() {
+			So(ForceHalfOpen("forced"), ShouldBeNil)
+			So(AllowRequest("forced"), ShouldBeTrue)
+		})
+	})
+
+	Convey("forcing a command which has never run half-open fails", t, func() {
+		So(ForceHalfOpen("unknown"), ShouldEqual, ErrUnknownCommand)
+		_, exists := lookupCircuit("unknown")
+		So(exists, ShouldBeFalse)
+	})
+
+	Convey("forcing a command with its own Breaker half-open fails", t, func() {
+		defer Flush()
+		ConfigureCommand("custom", CommandConfig{Breaker: &consecutiveBreaker{limit: 3}})
+		GetCircuit("custom")
+		So(ForceHalfOpen("custom"), ShouldEqual, ErrCustomBreaker)
+	})
+}
+
+func TestProbeEarly(t *testing.T) {
+	Convey("with a command whose circuit has opened", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2, SleepWindow: 60000})
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		So(AllowRequest(""), ShouldBeFalse)
+
+		So(ProbeEarly(""), ShouldBeNil)
+
+		Convey("it lets a single test through without waiting for the sleep window", func() {
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeTrue)
+			So(GetState(""), ShouldEqual, CircuitHalfOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+
+			Convey("and nudging it again while half-open lets no more through", func() {
+				So(ProbeEarly(""), ShouldBeNil)
+				So(AllowRequest(""), ShouldBeFalse)
+			})
+		})
+
+		Convey("a test which fails starts the sleep window again", func() {
+			So(AllowRequest(""), ShouldBeTrue)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(GetState(""), ShouldEqual, CircuitOpen)
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+	})
+
+	Convey("a forced open circuit is left alone", t, func() {
+		defer Flush()
+		ForceOpen("forced")
+		So(ProbeEarly("forced"), ShouldBeNil)
+		So(AllowRequest("forced"), ShouldBeFalse)
+	})
+
+	Convey("nudging a command which has never run fails", t, func() {
+		So(ProbeEarly("unknown"), ShouldEqual, ErrUnknownCommand)
+	})
+
+	Convey("nudging a command with its own Breaker fails", t, func() {
+		defer Flush()
+		ConfigureCommand("custom", CommandConfig{Breaker: &consecutiveBreaker{limit: 3}})
+		GetCircuit("custom")
+		So(ProbeEarly("custom"), ShouldEqual, ErrCustomBreaker)
+	})
+}
+
+func TestSleepWindowJitter(t *testing.T) {
+	Convey("with commands whose sleep window has a jitter", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+
+		open := func(name string, config CommandConfig) *CircuitBreaker {
+			config.RequestVolumeThreshold = 1
+			ConfigureCommand(name, config)
+			So(ReportEvent(name, OutcomeFailure, time.Millisecond), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			So(IsOpen(name), ShouldBeTrue)
+			cb, _, _ := GetCircuit(name)
+			return cb
+		}
+
+		Convey("the probe waits out the sleep window and the circuit's jitter", func() {
+			cb := open("jittered", CommandConfig{SleepWindow: 1000, SleepWindowJitter: 1000})
+			cb.mutex.RLock()
+			jitter := cb.sleepJitter
+			cb.mutex.RUnlock()
+			So(jitter, ShouldBeBetweenOrEqual, 0, time.Second)
+
+			clock.Advance(time.Second + jitter)
+			So(cb.AllowRequest(), ShouldBeFalse)
+			clock.Advance(time.Millisecond)
+			So(cb.AllowRequest(), ShouldBeTrue)
+		})
+
+		Convey("circuits opening together draw different jitters", func() {
+			jitters := make(map[time.Duration]bool)
+			for i := 0; i < 10; i++ {
+				cb := open(fmt.Sprintf("jittered_%d", i), CommandConfig{SleepWindowJitter: 60000})
+				cb.mutex.RLock()
+				jitters[cb.sleepJitter] = true
+				cb.mutex.RUnlock()
+			}
+			So(len(jitters), ShouldBeGreaterThan, 1)
+		})
+
+		Convey("without a jitter the probe comes exactly after the sleep window", func() {
+			cb := open("", CommandConfig{SleepWindow: 1000})
+			clock.Advance(time.Second)
+			So(cb.AllowRequest(), ShouldBeFalse)
+			clock.Advance(time.Nanosecond)
+			So(cb.AllowRequest(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestReportEventOutcome(t *testing.T) {
+	Convey("when outcomes are reported manually for a command", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2, SleepWindow: 50})
+
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("they are counted in the command's metrics and open its circuit", func() {
+			So(GetMetrics("").Failures, ShouldEqual, 2)
+			So(AllowRequest(""), ShouldBeFalse)
+			So(IsOpen(""), ShouldBeTrue)
+		})
+
+		Convey("a reported success after the sleep window closes the circuit", func() {
+			So(IsOpen(""), ShouldBeTrue)
+			clock.Advance(60 * time.Millisecond)
+			So(AllowRequest(""), ShouldBeTrue)
+			So(ReportEvent("", OutcomeSuccess, time.Millisecond), ShouldBeNil)
+			So(IsOpen(""), ShouldBeFalse)
+		})
+
+		Convey("an unknown outcome is refused", func() {
+			So(ReportEvent("", Outcome("maybe"), time.Millisecond), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestStateChangeHook(t *testing.T) {
+	Convey("with a hook registered for a circuit", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 1, SleepWindow: 1000})
+
+		var changes []StateChange
+		var states []CircuitState
+		RegisterStateChangeHook("", func(change StateChange) {
+			changes = append(changes, change)
+			// hooks must be able to query the circuit without deadlocking
+			cb, _, _ := GetCircuit(change.Name)
+			states = append(states, cb.State())
+		})
+
+		Do("", func() error { return fmt.Errorf("failure") }, nil)
+		time.Sleep(10 * time.Millisecond)
+		So(IsOpen(""), ShouldBeTrue)
+
+		Convey("opening the circuit calls it once", func() {
+			So(len(changes), ShouldEqual, 1)
+			So(changes[0].From, ShouldEqual, CircuitClosed)
+			So(changes[0].To, ShouldEqual, CircuitOpen)
+			So(changes[0].ErrorPercent, ShouldEqual, 100)
+			So(changes[0].Time, ShouldEqual, clock.Now())
+			So(states, ShouldResemble, []CircuitState{CircuitOpen})
+		})
+
+		Convey("a successful test request calls it for half-open then closed", func() {
+			clock.Advance(1001 * time.Millisecond)
+			So(<-Go("", func() error { return nil }, nil), ShouldBeNil)
+
+			So(len(changes), ShouldEqual, 3)
+			So(changes[1].From, ShouldEqual, CircuitOpen)
+			So(changes[1].To, ShouldEqual, CircuitHalfOpen)
+			So(changes[2].From, ShouldEqual, CircuitHalfOpen)
+			So(changes[2].To, ShouldEqual, CircuitClosed)
+			So(states, ShouldResemble, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed})
+		})
+
+		Convey("a failed test request calls it for half-open then open", func() {
+			clock.Advance(1001 * time.Millisecond)
+			// wait for the channel to close, which happens once the outcome has been reported
+			for range Go("", func() error { return fmt.Errorf("failure") }, nil) {
+			}
+
+			So(len(changes), ShouldEqual, 3)
+			So(changes[1].To, ShouldEqual, CircuitHalfOpen)
+			So(changes[2].From, ShouldEqual, CircuitHalfOpen)
+			So(changes[2].To, ShouldEqual, CircuitOpen)
+			So(IsOpen(""), ShouldBeTrue)
+			So(AllowRequest(""), ShouldBeFalse)
+		})
+	})
+}
+
+func TestStateSince(t *testing.T) {
+	Convey("with a circuit created at a known time", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2, SleepWindow: 60000})
+		created := clock.Now()
+		_, _, err := GetCircuit("")
+		So(err, ShouldBeNil)
+
+		Convey("it is closed since it was created", func() {
+			state, since := StateSince("")
+			So(state, ShouldEqual, CircuitClosed)
+			So(since, ShouldEqual, created)
+		})
+
+		Convey("opening it moves the time forward", func() {
+			clock.Advance(5 * time.Second)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			So(ReportEvent("", OutcomeFailure, time.Millisecond), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			So(IsOpen(""), ShouldBeTrue)
+			opened := clock.Now()
+			clock.Advance(time.Second)
+
+			state, since := StateSince("")
+			So(state, ShouldEqual, CircuitOpen)
+			So(since, ShouldEqual, opened)
+			So(GetCircuitStatuses()[0].StateSince, ShouldEqual, opened)
+		})
+	})
+
+	Convey("a command which has not been executed is closed since the zero time", t, func() {
+		state, since := StateSince("unknown")
+		So(state, ShouldEqual, CircuitClosed)
+		So(since.IsZero(), ShouldBeTrue)
+	})
+}
+
 func TestReportEventOpenThenClose(t *testing.T) {
 	Convey("when a circuit is closed", t, func() {
 		defer Flush()
@@ -98,6 +729,172 @@ func TestReportEventOpenThenClose(t *testing.T) {
 	})
 }
 
+func TestHalfOpenProbe(t *testing.T) {
+	Convey("when a circuit has been open for longer than its sleep window", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10, SleepWindow: 50})
+
+		cb, _, err := GetCircuit("")
+		So(err, ShouldBeNil)
+		cb.setOpen()
+		time.Sleep(60 * time.Millisecond)
+
+		Convey("a single probe is allowed through, and it times out", func() {
+			started := make(chan struct{}, 1)
+			probe := GoC(context.Background(), "", func(ctx context.Context) error {
+				started <- struct{}{}
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			}, nil)
+
+			Convey("other requests are rejected while the probe runs", func() {
+				<-started
+				errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+					return nil
+				}, nil)
+				So(<-errChan, ShouldResemble, ErrCircuitOpen)
+			})
+
+			Convey("the timed out probe leaves the circuit open and restarts the sleep window", func() {
+				So(<-probe, ShouldResemble, ErrTimeout)
+				So(cb.IsOpen(), ShouldBeTrue)
+
+				errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+					return nil
+				}, nil)
+				So(<-errChan, ShouldResemble, ErrCircuitOpen)
+			})
+		})
+	})
+}
+
+func TestRequestVolumeThreshold(t *testing.T) {
+	Convey("with a circuit which needs 20 requests before it can trip", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 20, ErrorPercentThreshold: 50})
+
+		cb, _, err := GetCircuit("")
+		So(err, ShouldBeNil)
+
+		reportFailures := func(n int) {
+			for i := 0; i < n; i++ {
+				So(cb.ReportEvent([]string{"failure"}, time.Now(), 0), ShouldBeNil)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("19 failures do not open the circuit", func() {
+			reportFailures(19)
+			So(cb.IsOpen(), ShouldBeFalse)
+
+			Convey("but the 20th does", func() {
+				reportFailures(1)
+				So(cb.IsOpen(), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestWarmup(t *testing.T) {
+	Convey("with a command which warms up for 1 second", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{Warmup: 1000, RequestVolumeThreshold: 1})
+
+		for i := 0; i < 5; i++ {
+			Do("", func() error { return fmt.Errorf("failure") }, nil)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("failures during the warmup do not open the circuit", func() {
+			So(GetHealth("").ErrorPercentage, ShouldEqual, 100)
+			So(IsOpen(""), ShouldBeFalse)
+		})
+
+		Convey("once the warmup has passed the circuit can open", func() {
+			clock.Advance(time.Second)
+			So(IsOpen(""), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSlowCallRateThreshold(t *testing.T) {
+	Convey("with a command which counts runs slower than 100ms as slow calls", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{
+			RequestVolumeThreshold:    4,
+			SlowCallDurationThreshold: 100,
+			SlowCallRateThreshold:     50,
+		})
+
+		run := func(d time.Duration) {
+			Do("", func() error {
+				clock.Advance(d)
+				return nil
+			}, nil)
+		}
+
+		Convey("when half the calls are slow, the circuit opens even though they succeeded", func() {
+			run(200 * time.Millisecond)
+			run(200 * time.Millisecond)
+			run(time.Millisecond)
+			run(time.Millisecond)
+			time.Sleep(10 * time.Millisecond)
+
+			health := GetHealth("")
+			So(health.ErrorPercentage, ShouldEqual, 0)
+			So(health.SlowCalls, ShouldEqual, 2)
+			So(health.SlowCallPercentage, ShouldEqual, 50)
+			So(IsOpen(""), ShouldBeTrue)
+		})
+
+		Convey("when fewer calls are slow, the circuit stays closed", func() {
+			run(200 * time.Millisecond)
+			run(time.Millisecond)
+			run(time.Millisecond)
+			run(time.Millisecond)
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetHealth("").SlowCallPercentage, ShouldEqual, 25)
+			So(IsOpen(""), ShouldBeFalse)
+		})
+	})
+}
+
+func TestErrorPercentThreshold(t *testing.T) {
+	Convey("with a circuit which trips at 50% errors", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 20, ErrorPercentThreshold: 50})
+
+		cb, _, err := GetCircuit("")
+		So(err, ShouldBeNil)
+
+		report := func(eventType string, n int) {
+			for i := 0; i < n; i++ {
+				So(cb.ReportEvent([]string{eventType}, time.Now(), 0), ShouldBeNil)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("49% errors keep the circuit closed", func() {
+			report("success", 51)
+			report("failure", 49)
+			So(cb.IsOpen(), ShouldBeFalse)
+		})
+
+		Convey("50% errors open it", func() {
+			report("success", 50)
+			report("failure", 50)
+			So(cb.IsOpen(), ShouldBeTrue)
+		})
+	})
+}
+
 func TestReportEventMultiThreaded(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	run := func() bool {
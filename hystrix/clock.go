@@ -0,0 +1,68 @@
+package hystrix
+
+import (
+	"sync"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix/rolling"
+)
+
+// Clock tells the time and creates timers. Command timeouts, sleep windows and rolling metrics
+// are all measured with it, so tests can replace it using SetClock to control time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event created by a Clock. It behaves like a time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+var clock Clock
+var clockMutex *sync.RWMutex
+
+func init() {
+	clock = realClock{}
+	clockMutex = &sync.RWMutex{}
+}
+
+// SetClock replaces the clock used by the hystrix package. Passing nil restores the real clock.
+// It is intended for tests, and should be called before any commands run.
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+
+	clockMutex.Lock()
+	clock = c
+	clockMutex.Unlock()
+
+	rolling.SetNow(c.Now)
+}
+
+func getClock() Clock {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+
+	return clock
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,142 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock only moves when Advance is called.
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	when  time.Time
+	c     chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.now
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) Timer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t := &fakeTimer{clock: f, when: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance moves the clock forward, firing any timers which are due.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.now = f.now.Add(d)
+
+	var pending []*fakeTimer
+	for _, t := range f.timers {
+		if t.when.After(f.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- f.now
+		}
+	}
+	f.timers = pending
+}
+
+// waitForTimers blocks until n timers are waiting to fire.
+func (f *fakeClock) waitForTimers(n int) {
+	for {
+		f.mutex.Lock()
+		waiting := len(f.timers)
+		f.mutex.Unlock()
+
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestFakeClock(t *testing.T) {
+	Convey("with a fake clock", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+
+		Convey("a command times out as soon as its timeout passes", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 1000})
+
+			errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}, nil)
+
+			clock.waitForTimers(1)
+			clock.Advance(999 * time.Millisecond)
+			select {
+			case err := <-errChan:
+				t.Fatalf("command finished before its timeout with %v", err)
+			default:
+			}
+
+			clock.Advance(time.Millisecond)
+			So(<-errChan, ShouldResemble, ErrTimeout)
+		})
+
+		Convey("an open circuit allows a test request once its sleep window passes", func() {
+			ConfigureCommand("", CommandConfig{SleepWindow: 5000})
+			cb, _, _ := GetCircuit("")
+			cb.setOpen()
+
+			So(AllowRequest(""), ShouldBeFalse)
+			clock.Advance(5001 * time.Millisecond)
+			So(AllowRequest(""), ShouldBeTrue)
+		})
+
+		Convey("requests fall out of the rolling window as the clock advances", func() {
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.Requests().Sum(clock.Now()), ShouldEqual, 1)
+
+			clock.Advance(10 * time.Second)
+			So(cb.metrics.Requests().Sum(clock.Now()), ShouldEqual, 0)
+		})
+	})
+}
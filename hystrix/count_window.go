@@ -0,0 +1,64 @@
+package hystrix
+
+import (
+	"sync"
+)
+
+// countWindow keeps the outcomes of a command's last requests, however long ago they were, for
+// circuits using a CountBased window.
+type countWindow struct {
+	mutex    sync.Mutex
+	outcomes []requestOutcome
+	// next is where the next outcome is stored, overwriting the oldest once the window is full.
+	next int
+	full bool
+}
+
+type requestOutcome struct {
+	err  bool
+	slow bool
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{outcomes: make([]requestOutcome, size)}
+}
+
+func (w *countWindow) add(o requestOutcome) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.outcomes[w.next] = o
+	w.next++
+	if w.next == len(w.outcomes) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// counts returns how many requests the window holds, and how many of them were errors or slow.
+func (w *countWindow) counts() (total, errs, slow uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	held := w.outcomes[:w.next]
+	if w.full {
+		held = w.outcomes
+	}
+	for _, o := range held {
+		if o.err {
+			errs++
+		}
+		if o.slow {
+			slow++
+		}
+	}
+	return uint64(len(held)), errs, slow
+}
+
+func (w *countWindow) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.next = 0
+	w.full = false
+}
@@ -32,6 +32,7 @@ This triggers when your code returns an error, or whenever it is unable to compl
 Waiting for output
 
 Calling Go is like launching a goroutine, except you receive a channel of errors you can choose to monitor.
+The channel receives at most one error and is closed once the command finishes, so reading a nil error means the command succeeded.
 
 	output := make(chan bool, 1)
 	errors := hystrix.Go("my_command", func() error {
@@ -43,8 +44,11 @@ Calling Go is like launching a goroutine, except you receive a channel of errors
 	select {
 	case out := <-output:
 		// success
-	case err := <-errors:
-		// failure
+	case err, ok := <-errors:
+		if ok && err != nil {
+			// failure
+		}
+		// otherwise the channel was closed because the command succeeded
 	}
 
 Synchronous API
@@ -0,0 +1,54 @@
+package hystrix
+
+import (
+	"time"
+)
+
+// DefaultDynamicTimeoutSamples is how many recent runs a command with a DynamicTimeout needs
+// before its timeout follows their latency, unless it sets MinSamples.
+const DefaultDynamicTimeoutSamples = 100
+
+// DynamicTimeout makes a command's timeout follow the latency of its recent runs, so that it
+// adapts to a dependency whose usual latency drifts. Durations are in milliseconds.
+type DynamicTimeout struct {
+	// Multiplier is how many times the 99th percentile of recent run durations the timeout is.
+	// Zero disables the dynamic timeout.
+	Multiplier float64 `json:"multiplier"`
+	// MinTimeout and MaxTimeout clamp the timeout. Zero leaves that side unclamped.
+	MinTimeout int `json:"min_timeout"`
+	MaxTimeout int `json:"max_timeout"`
+	// MinSamples is how many recent runs are needed before the timeout follows them. Until
+	// then the command's Timeout is used. Zero means DefaultDynamicTimeoutSamples.
+	MinSamples int `json:"min_samples"`
+}
+
+// timeoutForCommand returns the timeout of an execution of the named command starting now.
+func timeoutForCommand(name string) time.Duration {
+	settings := getSettings(name)
+	dynamic := settings.DynamicTimeout
+	if dynamic.Multiplier <= 0 || settings.Timeout <= 0 {
+		return settings.Timeout
+	}
+
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return settings.Timeout
+	}
+	runDuration := cb.metrics.DefaultCollector().RunDuration()
+	if len(runDuration.SortedDurations()) < dynamic.MinSamples {
+		return settings.Timeout
+	}
+
+	timeout := time.Duration(float64(runDuration.PercentileDuration(99)) * dynamic.Multiplier)
+	if min := time.Duration(dynamic.MinTimeout) * time.Millisecond; min > 0 && timeout < min {
+		timeout = min
+	}
+	if max := time.Duration(dynamic.MaxTimeout) * time.Millisecond; max > 0 && timeout > max {
+		timeout = max
+	}
+	if timeout <= 0 {
+		// a dependency answering in under a nanosecond would otherwise never time out
+		return settings.Timeout
+	}
+	return timeout
+}
@@ -0,0 +1,76 @@
+package hystrix
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDynamicTimeout(t *testing.T) {
+	Convey("with a command whose timeout is 3 times its p99, between 50ms and 500ms", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{
+			Timeout:        1000,
+			DynamicTimeout: DynamicTimeout{Multiplier: 3, MinTimeout: 50, MaxTimeout: 500, MinSamples: 10},
+		})
+		observe := func(d time.Duration, n int) {
+			cb, _, _ := GetCircuit("")
+			for i := 0; i < n; i++ {
+				cb.metrics.DefaultCollector().RunDuration().Add(d)
+			}
+		}
+
+		Convey("the static timeout is used before the command has run", func() {
+			So(timeoutForCommand(""), ShouldEqual, time.Second)
+		})
+
+		Convey("the static timeout is used until there are enough samples", func() {
+			observe(20*time.Millisecond, 9)
+			So(timeoutForCommand(""), ShouldEqual, time.Second)
+		})
+
+		Convey("with enough samples the timeout follows the p99", func() {
+			observe(20*time.Millisecond, 10)
+			So(timeoutForCommand(""), ShouldEqual, 60*time.Millisecond)
+		})
+
+		Convey("the timeout is clamped to MinTimeout", func() {
+			observe(5*time.Millisecond, 10)
+			So(timeoutForCommand(""), ShouldEqual, 50*time.Millisecond)
+		})
+
+		Convey("the timeout is clamped to MaxTimeout", func() {
+			observe(400*time.Millisecond, 10)
+			So(timeoutForCommand(""), ShouldEqual, 500*time.Millisecond)
+		})
+
+		Convey("a run far slower than usual times out at the dynamic timeout", func() {
+			observe(20*time.Millisecond, 10)
+			start := time.Now()
+			err := Do("", func() error {
+				time.Sleep(300 * time.Millisecond)
+				return nil
+			}, nil)
+			So(err, ShouldResemble, ErrTimeout)
+			So(time.Since(start), ShouldBeLessThan, 250*time.Millisecond)
+		})
+	})
+
+	Convey("with a dynamic timeout on a command with no timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: NoTimeout, DynamicTimeout: DynamicTimeout{Multiplier: 3, MinSamples: 1}})
+		cb, _, _ := GetCircuit("")
+		cb.metrics.DefaultCollector().RunDuration().Add(time.Millisecond)
+
+		Convey("the command still never times out", func() {
+			So(timeoutForCommand(""), ShouldEqual, 0)
+		})
+	})
+
+	Convey("the number of samples needed defaults to DefaultDynamicTimeoutSamples", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{DynamicTimeout: DynamicTimeout{Multiplier: 3}})
+		So(getSettings("").DynamicTimeout.MinSamples, ShouldEqual, DefaultDynamicTimeoutSamples)
+	})
+}
@@ -0,0 +1,61 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	disabledMutex *sync.RWMutex
+	disabled      map[string]bool
+)
+
+func init() {
+	disabledMutex = &sync.RWMutex{}
+	disabled = make(map[string]bool)
+}
+
+// SetEnabled turns the protection of the named command on or off at runtime, such as from a
+// feature flag. A disabled command runs its function directly, with no circuit, timeout,
+// concurrency limit, fallback or metrics, and delivers its error as is. Commands are enabled by
+// default, and Flush enables them all again.
+func SetEnabled(name string, enabled bool) {
+	name = canonicalName(name)
+	disabledMutex.Lock()
+	defer disabledMutex.Unlock()
+
+	if enabled {
+		delete(disabled, name)
+		return
+	}
+	disabled[name] = true
+}
+
+// IsEnabled reports whether the named command is protected, as set by SetEnabled.
+func IsEnabled(name string) bool {
+	name = canonicalName(name)
+	disabledMutex.RLock()
+	defer disabledMutex.RUnlock()
+
+	return !disabled[name]
+}
+
+// runUnprotected runs a disabled command's function, sending its error on errChan. A panic to
+// propagate is re-panicked here unless inline is set, like a protected command's.
+func runUnprotected(ctx context.Context, run runFuncC, errChan chan error, inline bool) {
+	err := callRun(ctx, run)
+	if !inline {
+		repanic(err)
+	}
+	if err != nil {
+		errChan <- err
+	}
+	close(errChan)
+}
+
+func flushDisabled() {
+	disabledMutex.Lock()
+	defer disabledMutex.Unlock()
+
+	disabled = make(map[string]bool)
+}
@@ -0,0 +1,53 @@
+package hystrix
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSetEnabled(t *testing.T) {
+	Convey("with a command which has been disabled", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10})
+		ForceOpen("")
+		SetEnabled("", false)
+
+		Convey("it is reported as disabled", func() {
+			So(IsEnabled(""), ShouldBeFalse)
+			So(IsEnabled("other"), ShouldBeTrue)
+			So(GetCircuitStatuses()[0].Enabled, ShouldBeFalse)
+		})
+
+		Convey("its run is called directly, without a circuit or timeout", func() {
+			err := Do("", func() error {
+				time.Sleep(30 * time.Millisecond)
+				return nil
+			}, nil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("its run's error is delivered without calling the fallback", func() {
+			fallbackCalled := false
+			err := <-Go("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				fallbackCalled = true
+				return nil
+			})
+			So(err, ShouldResemble, fmt.Errorf("run_error"))
+			So(fallbackCalled, ShouldBeFalse)
+
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Requests, ShouldEqual, 0)
+		})
+
+		Convey("enabling it again restores its protection", func() {
+			SetEnabled("", true)
+			So(IsEnabled(""), ShouldBeTrue)
+			So(Do("", func() error { return nil }, nil), ShouldResemble, ErrCircuitOpen)
+		})
+	})
+}
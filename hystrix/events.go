@@ -0,0 +1,99 @@
+package hystrix
+
+import (
+	"sync"
+	"time"
+)
+
+// Event describes a single step in the execution of a command.
+//
+// Type is one of "attempt", "success", "failure", "timeout", "short-circuit", "rejected",
+// "rate-limited", "context_canceled", "context_deadline_exceeded", "fallback-success",
+// "fallback-stale", "fallback-failure", "fallback-rejection", "response-from-cache", "retry" or
+// "health-recovered". A fallback-stale is a fallback which succeeded by returning StaleResult, and
+// a health-recovered is sent alongside a HealthRecovery. An attempt is sent
+// just before run is called, and a retry just before run is called again after a failure.
+// A response from the request cache is not recorded in the circuit's metrics.
+type Event struct {
+	Name string
+	Type string
+	// Duration is how long the command had been executing when the event happened.
+	Duration time.Duration
+	// Err is the error behind the event, if there was one.
+	Err error
+	// Tags are the command's configured Tags, along with any added by WithTags.
+	Tags map[string]string
+}
+
+// EventListener receives the events of every command execution.
+type EventListener interface {
+	OnEvent(Event)
+}
+
+// EventListenerFunc adapts an ordinary function to an EventListener.
+type EventListenerFunc func(Event)
+
+// OnEvent calls f(e).
+func (f EventListenerFunc) OnEvent(e Event) {
+	f(e)
+}
+
+type eventDispatcher struct {
+	listener EventListener
+	events   chan Event
+}
+
+var (
+	eventListenersMutex *sync.RWMutex
+	eventListeners      []*eventDispatcher
+)
+
+func init() {
+	eventListenersMutex = &sync.RWMutex{}
+}
+
+// RegisterEventListener adds a listener for the events of every command. Each listener receives
+// its events in order on its own goroutine. Commands never wait for listeners, so events are
+// dropped for a listener which falls too far behind.
+func RegisterEventListener(l EventListener) {
+	d := &eventDispatcher{
+		listener: l,
+		events:   make(chan Event, 2000),
+	}
+	go d.dispatch()
+
+	eventListenersMutex.Lock()
+	defer eventListenersMutex.Unlock()
+
+	eventListeners = append(eventListeners, d)
+}
+
+func (d *eventDispatcher) dispatch() {
+	for e := range d.events {
+		d.listener.OnEvent(e)
+	}
+}
+
+func emitEvent(e Event) {
+	eventListenersMutex.RLock()
+	defer eventListenersMutex.RUnlock()
+
+	for _, d := range eventListeners {
+		select {
+		case d.events <- e:
+		default:
+			log.Warnf("hystrix-go: dropping %v event for %v, listener is at capacity", e.Type, e.Name)
+		}
+	}
+}
+
+// flushEventListeners removes every listener, letting each finish its queued events.
+func flushEventListeners() {
+	eventListenersMutex.Lock()
+	defer eventListenersMutex.Unlock()
+
+	for _, d := range eventListeners {
+		close(d.events)
+	}
+	eventListeners = nil
+}
@@ -0,0 +1,76 @@
+package hystrix
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventListener(t *testing.T) {
+	Convey("with an event listener registered", t, func() {
+		defer Flush()
+
+		events := make(chan Event, 10)
+		RegisterEventListener(EventListenerFunc(func(e Event) {
+			events <- e
+		}))
+		next := func() Event {
+			select {
+			case e := <-events:
+				return e
+			case <-time.After(time.Second):
+				return Event{}
+			}
+		}
+
+		Convey("a successful command sends an attempt then a success", func() {
+			So(Do("events", func() error { return nil }, nil), ShouldBeNil)
+
+			attempt := next()
+			So(attempt.Name, ShouldEqual, "events")
+			So(attempt.Type, ShouldEqual, "attempt")
+			So(next().Type, ShouldEqual, "success")
+		})
+
+		Convey("a failing command sends the failure and its fallback outcome", func() {
+			runErr := fmt.Errorf("run_error")
+			fallbackErr := fmt.Errorf("fallback_error")
+			Do("events", func() error { return runErr }, func(err error) error { return fallbackErr })
+
+			So(next().Type, ShouldEqual, "attempt")
+			failure := next()
+			So(failure.Type, ShouldEqual, "failure")
+			So(failure.Err, ShouldEqual, runErr)
+			fallback := next()
+			So(fallback.Type, ShouldEqual, "fallback-failure")
+			So(fallback.Err, ShouldEqual, fallbackErr)
+		})
+
+		Convey("a short-circuited command never sends an attempt", func() {
+			ForceOpen("events")
+			Do("events", func() error { return nil }, nil)
+
+			shortCircuit := next()
+			So(shortCircuit.Type, ShouldEqual, "short-circuit")
+			So(shortCircuit.Err, ShouldResemble, ErrCircuitOpen)
+		})
+	})
+
+	Convey("with a listener which never returns", t, func() {
+		defer Flush()
+
+		block := make(chan struct{})
+		defer close(block)
+		RegisterEventListener(EventListenerFunc(func(e Event) {
+			<-block
+		}))
+
+		Convey("commands still run without waiting for it", func() {
+			for i := 0; i < 1100; i++ {
+				So(Do("events", func() error { return nil }, nil), ShouldBeNil)
+			}
+		})
+	})
+}
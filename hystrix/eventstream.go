@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,31 +12,138 @@ import (
 )
 
 const (
+	// streamEventBufferSize is how many ticks of events are queued for each client before
+	// further ticks are dropped for it.
 	streamEventBufferSize = 10
+	// DefaultStreamInterval is how often a StreamHandler publishes metrics unless WithInterval
+	// says otherwise.
+	DefaultStreamInterval = time.Second
+	// MinStreamInterval is the shortest interval WithInterval accepts. Shorter ones are raised to
+	// it, since every tick snapshots every command.
+	MinStreamInterval = 100 * time.Millisecond
 )
 
+// A StreamOption changes how a StreamHandler publishes metrics.
+type StreamOption func(*StreamHandler)
+
+// WithInterval sets how often the StreamHandler publishes metrics, which is DefaultStreamInterval
+// by default. Intervals below MinStreamInterval are raised to it.
+//
+// Every tick publishes each command's counts over its RollingWindow, so the interval changes
+// how often the dashboard updates, not the window its rolling counts and rates cover. An
+// interval longer than a command's RollingWindow leaves executions the dashboard never sees.
+func WithInterval(d time.Duration) StreamOption {
+	if d < MinStreamInterval {
+		d = MinStreamInterval
+	}
+
+	return func(sh *StreamHandler) {
+		sh.interval = d
+	}
+}
+
+// WithoutThreadPools leaves the "HystrixThreadPool" events out of the stream, so it only carries
+// one "HystrixCommand" event per command. By default each executor pool, which is shared by the
+// commands of a Group, is also published as a thread pool, so the dashboard's thread pool panel
+// shows its size, active and queued executions and rejections.
+func WithoutThreadPools() StreamOption {
+	return func(sh *StreamHandler) {
+		sh.noThreadPools = true
+	}
+}
+
 // NewStreamHandler returns a server capable of exposing dashboard metrics via HTTP.
-func NewStreamHandler() *StreamHandler {
-	return &StreamHandler{}
+func NewStreamHandler(opts ...StreamOption) *StreamHandler {
+	sh := &StreamHandler{interval: DefaultStreamInterval}
+	for _, opt := range opts {
+		opt(sh)
+	}
+	return sh
 }
 
-// StreamHandler publishes metrics for each command and each pool once a second to all connected HTTP client.
+// StreamHandler publishes metrics for each command and each pool once a second, or at the
+// interval given to WithInterval, to all connected HTTP client. A single loop snapshots every
+// command each tick and queues the events for each client in one write, so a client which falls
+// behind has whole ticks dropped rather than holding up the loop.
 type StreamHandler struct {
-	requests map[*http.Request]chan []byte
-	mu       sync.RWMutex
-	done     chan struct{}
+	// MaxEventRate is the most events published each second, which each tick gets its share of.
+	// With more commands and pools than that, each tick carries on where the last one stopped,
+	// so every one is still published in turn. Zero publishes every command and pool each tick.
+	// It is read by Start.
+	MaxEventRate int
+
+	// interval is how often metrics are published. A zero StreamHandler uses
+	// DefaultStreamInterval.
+	interval time.Duration
+	// noThreadPools is set by WithoutThreadPools.
+	noThreadPools bool
+	requests      map[*http.Request]chan []byte
+	mu            sync.RWMutex
+	done          chan struct{}
+}
+
+var (
+	streamHandlersMutex *sync.Mutex
+	streamHandlers      map[*StreamHandler]struct{}
+)
+
+func init() {
+	streamHandlersMutex = &sync.Mutex{}
+	streamHandlers = make(map[*StreamHandler]struct{})
 }
 
 // Start begins watching the in-memory circuit breakers for metrics
 func (sh *StreamHandler) Start() {
+	done := make(chan struct{})
+	sh.mu.Lock()
 	sh.requests = make(map[*http.Request]chan []byte)
-	sh.done = make(chan struct{})
-	go sh.loop()
+	sh.done = done
+	interval := sh.interval
+	if interval == 0 {
+		interval = DefaultStreamInterval
+	}
+	maxEvents := sh.MaxEventRate
+	if maxEvents > 0 {
+		maxEvents = int(int64(maxEvents) * int64(interval) / int64(time.Second))
+		if maxEvents < 1 {
+			maxEvents = 1
+		}
+	}
+	sh.mu.Unlock()
+	go sh.loop(done, interval, maxEvents)
+
+	streamHandlersMutex.Lock()
+	streamHandlers[sh] = struct{}{}
+	streamHandlersMutex.Unlock()
 }
 
-// Stop shuts down the metric collection routine
+// Stop shuts down the metric collection routine. It is safe to call more than once, and is
+// called by Shutdown for every started handler.
 func (sh *StreamHandler) Stop() {
-	close(sh.done)
+	sh.mu.Lock()
+	if sh.done != nil {
+		close(sh.done)
+		sh.done = nil
+	}
+	sh.mu.Unlock()
+
+	streamHandlersMutex.Lock()
+	delete(streamHandlers, sh)
+	streamHandlersMutex.Unlock()
+}
+
+// stopStreamHandlers stops every started StreamHandler.
+func stopStreamHandlers() {
+	streamHandlersMutex.Lock()
+	handlers := make([]*StreamHandler, 0, len(streamHandlers))
+	for sh := range streamHandlers {
+		handlers = append(handlers, sh)
+	}
+	streamHandlersMutex.Unlock()
+
+	for _, sh := range handlers {
+		sh.Stop()
+	}
 }
 
 var _ http.Handler = (*StreamHandler)(nil)
@@ -50,7 +158,8 @@ func (sh *StreamHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	events := sh.register(req)
 	defer sh.unregister(req)
 
-	notify := rw.(http.CloseNotifier).CloseNotify()
+	// the request context is canceled once the client disconnects
+	notify := req.Context().Done()
 
 	rw.Header().Add("Content-Type", "text/event-stream")
 	rw.Header().Set("Cache-Control", "no-cache")
@@ -70,48 +179,118 @@ func (sh *StreamHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (sh *StreamHandler) loop() {
-	tick := time.Tick(1 * time.Second)
+func (sh *StreamHandler) loop(done chan struct{}, interval time.Duration, maxEvents int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	next := 0
 	for {
 		select {
-		case <-tick:
-			circuitBreakersMutex.RLock()
-			for _, cb := range circuitBreakers {
-				sh.publishMetrics(cb)
-				sh.publishThreadPools(cb.executorPool)
-			}
-			circuitBreakersMutex.RUnlock()
-		case <-sh.done:
+		case <-ticker.C:
+			var events [][]byte
+			events, next = streamSnapshot(next, maxEvents, !sh.noThreadPools)
+			sh.writeToRequests(events)
+		case <-done:
 			return
 		}
 	}
 }
 
-func (sh *StreamHandler) publishMetrics(cb *CircuitBreaker) error {
-	now := time.Now()
-	reqCount := cb.metrics.Requests().Sum(now)
-	errCount := cb.metrics.DefaultCollector().Errors().Sum(now)
-	errPct := cb.metrics.ErrorPercent(now)
+// streamSource is a command or a pool published on the stream.
+type streamSource struct {
+	cb   *CircuitBreaker
+	pool *executorPool
+}
 
-	eventBytes, err := json.Marshal(&streamCmdMetric{
+// streamSnapshot returns the events of every command, and of every pool if pools is set, or at
+// most maxEvents of them starting from the next'th, along with where the following snapshot
+// should start.
+func streamSnapshot(next, maxEvents int, pools bool) ([][]byte, int) {
+	circuitBreakersMutex.RLock()
+	circuits := make([]*CircuitBreaker, 0, len(circuitBreakers))
+	for _, cb := range circuitBreakers {
+		circuits = append(circuits, cb)
+	}
+	circuitBreakersMutex.RUnlock()
+
+	sort.Slice(circuits, func(i, j int) bool {
+		return circuits[i].Name < circuits[j].Name
+	})
+
+	sources := make([]streamSource, 0, 2*len(circuits))
+	// commands in the same group share a pool, which is only published once
+	published := make(map[*executorPool]bool)
+	for _, cb := range circuits {
+		sources = append(sources, streamSource{cb: cb})
+		if pools && !published[cb.executorPool] {
+			published[cb.executorPool] = true
+			sources = append(sources, streamSource{pool: cb.executorPool})
+		}
+	}
+
+	count := len(sources)
+	if maxEvents > 0 && maxEvents < count {
+		count = maxEvents
+	}
+	if next >= len(sources) {
+		next = 0
+	}
+
+	events := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		source := sources[(next+i)%len(sources)]
+
+		var event []byte
+		var err error
+		if source.cb != nil {
+			event, err = commandEvent(source.cb)
+		} else {
+			event, err = threadPoolEvent(source.pool)
+		}
+		if err == nil {
+			events = append(events, event)
+		}
+	}
+
+	if count == 0 {
+		return events, 0
+	}
+	return events, (next + count) % len(sources)
+}
+
+func commandEvent(cb *CircuitBreaker) ([]byte, error) {
+	now := getClock().Now()
+	// the counts are read together, so the dashboard never sees an execution half counted
+	metrics, health, _ := cb.metrics.status(now)
+	forceOpen, forceClosed := cb.forced()
+	state, since := cb.StateSince()
+
+	return json.Marshal(&streamCmdMetric{
 		Type:           "HystrixCommand",
 		Name:           cb.Name,
 		Group:          cb.Name,
 		Time:           currentTime(),
 		ReportingHosts: 1,
 
-		RequestCount:       uint32(reqCount),
-		ErrorCount:         uint32(errCount),
-		ErrorPct:           uint32(errPct),
-		CircuitBreakerOpen: cb.IsOpen(),
-
-		RollingCountSuccess:            uint32(cb.metrics.DefaultCollector().Successes().Sum(now)),
-		RollingCountFailure:            uint32(cb.metrics.DefaultCollector().Failures().Sum(now)),
-		RollingCountThreadPoolRejected: uint32(cb.metrics.DefaultCollector().Rejects().Sum(now)),
-		RollingCountShortCircuited:     uint32(cb.metrics.DefaultCollector().ShortCircuits().Sum(now)),
-		RollingCountTimeout:            uint32(cb.metrics.DefaultCollector().Timeouts().Sum(now)),
-		RollingCountFallbackSuccess:    uint32(cb.metrics.DefaultCollector().FallbackSuccesses().Sum(now)),
-		RollingCountFallbackFailure:    uint32(cb.metrics.DefaultCollector().FallbackFailures().Sum(now)),
+		RequestCount:             uint32(metrics.Requests),
+		ErrorCount:               uint32(metrics.Errors),
+		ErrorPct:                 uint32(health.ErrorPercentage),
+		CircuitBreakerOpen:       cb.IsOpen(),
+		CircuitBreakerState:      state.String(),
+		CircuitBreakerStateSince: since.UnixNano() / int64(time.Millisecond),
+
+		RollingCountSuccess:                 uint32(metrics.Successes),
+		RollingCountFailure:                 uint32(metrics.Failures),
+		RollingCountThreadPoolRejected:      uint32(metrics.Rejects),
+		RollingCountShortCircuited:          uint32(metrics.ShortCircuits),
+		RollingCountTimeout:                 uint32(metrics.Timeouts),
+		RollingCountRateLimited:             uint32(metrics.RateLimited),
+		RollingCountContextCanceled:         uint32(metrics.ContextCanceled),
+		RollingCountContextDeadlineExceeded: uint32(metrics.ContextDeadlineExceeded),
+		RollingCountFallbackSuccess:         uint32(metrics.FallbackSuccesses),
+		RollingCountFallbackFailure:         uint32(metrics.FallbackFailures),
+		RollingCountFallbackRejection:       uint32(metrics.FallbackRejections),
+		RollingCountFallbackStale:           uint32(metrics.StaleFallbacks),
 
 		LatencyTotal:       generateLatencyTimings(cb.metrics.DefaultCollector().TotalDuration()),
 		LatencyTotalMean:   cb.metrics.DefaultCollector().TotalDuration().Mean(),
@@ -120,31 +299,24 @@ func (sh *StreamHandler) publishMetrics(cb *CircuitBreaker) error {
 
 		// TODO: all hard-coded values should become configurable settings, per circuit
 
-		RollingStatsWindow:         10000,
+		RollingStatsWindow:         uint32(getSettings(cb.Name).RollingWindow.Milliseconds()),
 		ExecutionIsolationStrategy: "THREAD",
 
 		CircuitBreakerEnabled:                true,
-		CircuitBreakerForceClosed:            false,
-		CircuitBreakerForceOpen:              cb.forceOpen,
+		CircuitBreakerForceClosed:            forceClosed,
+		CircuitBreakerForceOpen:              forceOpen,
 		CircuitBreakerErrorThresholdPercent:  uint32(getSettings(cb.Name).ErrorPercentThreshold),
 		CircuitBreakerSleepWindow:            uint32(getSettings(cb.Name).SleepWindow.Seconds() * 1000),
 		CircuitBreakerRequestVolumeThreshold: uint32(getSettings(cb.Name).RequestVolumeThreshold),
-	})
-	if err != nil {
-		return err
-	}
-	err = sh.writeToRequests(eventBytes)
-	if err != nil {
-		return err
-	}
 
-	return nil
+		FallbackIsolationSemaphoreMaxConcurrentRequests: uint32(getSettings(cb.Name).FallbackMaxConcurrent),
+	})
 }
 
-func (sh *StreamHandler) publishThreadPools(pool *executorPool) error {
-	now := time.Now()
+func threadPoolEvent(pool *executorPool) ([]byte, error) {
+	now := getClock().Now()
 
-	eventBytes, err := json.Marshal(&streamThreadPoolMetric{
+	return json.Marshal(&streamThreadPoolMetric{
 		Type:           "HystrixThreadPool",
 		Name:           pool.Name,
 		ReportingHosts: 1,
@@ -154,39 +326,32 @@ func (sh *StreamHandler) publishThreadPools(pool *executorPool) error {
 		CurrentCompletedTaskCount: 0,
 
 		RollingCountThreadsExecuted: uint32(pool.Metrics.Executed.Sum(now)),
+		RollingCountRejections:      uint32(pool.Metrics.rejections(now)),
 		RollingMaxActiveThreads:     uint32(pool.Metrics.MaxActiveRequests.Max(now)),
 
-		CurrentPoolSize:        uint32(pool.Max),
-		CurrentCorePoolSize:    uint32(pool.Max),
+		// an unlimited pool's UnlimitedConcurrency converts to the largest size there is
+		CurrentPoolSize:        uint32(pool.limit()),
+		CurrentCorePoolSize:    uint32(pool.limit()),
 		CurrentLargestPoolSize: uint32(pool.Max),
 		CurrentMaximumPoolSize: uint32(pool.Max),
 
-		RollingStatsWindow:          10000,
+		RollingStatsWindow:          uint32(getSettings(pool.Name).RollingWindow.Milliseconds()),
 		QueueSizeRejectionThreshold: 0,
-		CurrentQueueSize:            0,
+		CurrentQueueSize:            uint32(pool.QueueDepth()),
 	})
-	if err != nil {
-		return err
-	}
-	err = sh.writeToRequests(eventBytes)
-
-	return nil
 }
 
-func (sh *StreamHandler) writeToRequests(eventBytes []byte) error {
-	var b bytes.Buffer
-	_, err := b.Write([]byte("data:"))
-	if err != nil {
-		return err
+// writeToRequests queues events for every client as separate SSE events in a single write.
+func (sh *StreamHandler) writeToRequests(events [][]byte) {
+	if len(events) == 0 {
+		return
 	}
 
-	_, err = b.Write(eventBytes)
-	if err != nil {
-		return err
-	}
-	_, err = b.Write([]byte("\n\n"))
-	if err != nil {
-		return err
+	var b bytes.Buffer
+	for _, event := range events {
+		b.WriteString("data:")
+		b.Write(event)
+		b.WriteString("\n\n")
 	}
 	dataBytes := b.Bytes()
 	sh.mu.RLock()
@@ -198,8 +363,6 @@ func (sh *StreamHandler) writeToRequests(eventBytes []byte) error {
 		}
 	}
 	sh.mu.RUnlock()
-
-	return nil
 }
 
 func (sh *StreamHandler) register(req *http.Request) <-chan []byte {
@@ -249,19 +412,34 @@ type streamCmdMetric struct {
 	ErrorCount         uint32 `json:"errorCount"`
 	ErrorPct           uint32 `json:"errorPercentage"`
 	CircuitBreakerOpen bool   `json:"isCircuitBreakerOpen"`
-
-	RollingCountCollapsedRequests  uint32 `json:"rollingCountCollapsedRequests"`
-	RollingCountExceptionsThrown   uint32 `json:"rollingCountExceptionsThrown"`
-	RollingCountFailure            uint32 `json:"rollingCountFailure"`
-	RollingCountFallbackFailure    uint32 `json:"rollingCountFallbackFailure"`
-	RollingCountFallbackRejection  uint32 `json:"rollingCountFallbackRejection"`
-	RollingCountFallbackSuccess    uint32 `json:"rollingCountFallbackSuccess"`
+	// CircuitBreakerState is "closed", "open" or "half-open", ignoring forcing, and
+	// CircuitBreakerStateSince is when the circuit moved into it, in milliseconds since the epoch
+	// like currentTime.
+	CircuitBreakerState      string `json:"circuitBreakerState"`
+	CircuitBreakerStateSince int64  `json:"circuitBreakerStateSince"`
+
+	RollingCountCollapsedRequests uint32 `json:"rollingCountCollapsedRequests"`
+	RollingCountExceptionsThrown  uint32 `json:"rollingCountExceptionsThrown"`
+	RollingCountFailure           uint32 `json:"rollingCountFailure"`
+	RollingCountFallbackFailure   uint32 `json:"rollingCountFallbackFailure"`
+	RollingCountFallbackRejection uint32 `json:"rollingCountFallbackRejection"`
+	RollingCountFallbackSuccess   uint32 `json:"rollingCountFallbackSuccess"`
+	// RollingCountFallbackStale is not part of the Hystrix stream, and counts fallbacks which
+	// served stale data.
+	RollingCountFallbackStale      uint32 `json:"rollingCountFallbackStale"`
 	RollingCountResponsesFromCache uint32 `json:"rollingCountResponsesFromCache"`
 	RollingCountSemaphoreRejected  uint32 `json:"rollingCountSemaphoreRejected"`
 	RollingCountShortCircuited     uint32 `json:"rollingCountShortCircuited"`
 	RollingCountSuccess            uint32 `json:"rollingCountSuccess"`
 	RollingCountThreadPoolRejected uint32 `json:"rollingCountThreadPoolRejected"`
 	RollingCountTimeout            uint32 `json:"rollingCountTimeout"`
+	// Together with the Hystrix counts above, these counts break the window's errors down by
+	// cause. They are not part of the Hystrix stream: rollingCountRateLimited counts executions
+	// turned away by RateLimit, and the context counts executions whose caller's context was
+	// canceled or passed its deadline, which are left out of the circuit's health.
+	RollingCountRateLimited             uint32 `json:"rollingCountRateLimited"`
+	RollingCountContextCanceled         uint32 `json:"rollingCountContextCanceled"`
+	RollingCountContextDeadlineExceeded uint32 `json:"rollingCountContextDeadlineExceeded"`
 
 	CurrentConcurrentExecutionCount uint32 `json:"currentConcurrentExecutionCount"`
 
@@ -316,6 +494,7 @@ type streamThreadPoolMetric struct {
 
 	RollingMaxActiveThreads     uint32 `json:"rollingMaxActiveThreads"`
 	RollingCountThreadsExecuted uint32 `json:"rollingCountThreadsExecuted"`
+	RollingCountRejections      uint32 `json:"rollingCountCommandRejections"`
 
 	RollingStatsWindow          uint32 `json:"propertyValue_metricsRollingStatisticalWindowInMilliseconds"`
 	QueueSizeRejectionThreshold uint32 `json:"propertyValue_queueSizeRejectionThreshold"`
@@ -1,6 +1,7 @@
 package hystrix
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -161,6 +162,7 @@ func TestEventStream(t *testing.T) {
 
 				So(event.Name, ShouldEqual, "eventstream")
 				So(int(event.RequestCount), ShouldEqual, 2)
+				So(event.CircuitBreakerState, ShouldEqual, "closed")
 			})
 		})
 
@@ -175,6 +177,73 @@ func TestEventStream(t *testing.T) {
 				So(metric.ErrorPct, ShouldEqual, 67)
 			})
 		})
+
+		Convey("after a fallback serves stale data", func() {
+			Do("stale", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return StaleResult
+			})
+
+			Convey("the stale serve is in the stream", func() {
+				metric := grabFirstCommandFromStream(t, server.URL)
+
+				So(metric.RollingCountFallbackSuccess, ShouldEqual, 1)
+				So(metric.RollingCountFallbackStale, ShouldEqual, 1)
+			})
+		})
+
+		Convey("after errors with causes the Hystrix stream has no count for", func() {
+			ConfigureCommand("causes", CommandConfig{RateLimit: 2})
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			DoC(ctx, "causes", func(ctx context.Context) error {
+				return ctx.Err()
+			}, nil)
+			for i := 0; i < 3; i++ {
+				Do("causes", func() error { return nil }, nil)
+			}
+
+			Convey("each cause has its own count", func() {
+				metric := grabFirstCommandFromStream(t, server.URL)
+
+				So(metric.RollingCountRateLimited, ShouldBeGreaterThanOrEqualTo, 1)
+				So(metric.RollingCountContextCanceled, ShouldEqual, 1)
+				So(metric.RollingCountFailure, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestStreamInterval(t *testing.T) {
+	Convey("given an event stream publishing every 100ms", t, func() {
+		sh := NewStreamHandler(WithInterval(100 * time.Millisecond))
+		sh.Start()
+		server := &eventStreamTestServer{httptest.NewServer(sh), sh}
+		defer server.stopTestServer()
+		sleepingCommand(t, "interval", 1*time.Millisecond)
+
+		Convey("a command is published several times a second", func() {
+			metrics, done := streamMetrics(t, server.URL)
+			start := time.Now()
+			count := 0
+			for m := range metrics {
+				if strings.Contains(m, "HystrixCommand") {
+					count++
+				}
+				if count == 3 {
+					done <- true
+					close(done)
+					break
+				}
+			}
+			So(time.Since(start), ShouldBeLessThan, 800*time.Millisecond)
+		})
+	})
+
+	Convey("an interval below the minimum is raised to it", t, func() {
+		So(NewStreamHandler(WithInterval(time.Millisecond)).interval, ShouldEqual, MinStreamInterval)
+		So(NewStreamHandler().interval, ShouldEqual, DefaultStreamInterval)
 	})
 }
 
@@ -266,5 +335,90 @@ func TestThreadPoolStream(t *testing.T) {
 				So(metric.CurrentPoolSize, ShouldEqual, 10)
 			})
 		})
+
+		Convey("after a command rejected by its pool", func() {
+			ConfigureCommand("threadpool", CommandConfig{MaxConcurrentRequests: 1})
+			release := make(chan struct{})
+			started := make(chan struct{})
+			holder := Go("threadpool", func() error {
+				close(started)
+				<-release
+				return nil
+			}, nil)
+			<-started
+			Do("threadpool", func() error { return nil }, nil)
+			close(release)
+			<-holder
+			metric := grabFirstThreadPoolFromStream(t, server.URL)
+
+			Convey("the rejection is counted", func() {
+				So(metric.RollingCountRejections, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestStreamSnapshot(t *testing.T) {
+	Convey("with more commands than a client queues events for", t, func() {
+		defer Flush()
+		for i := 0; i < 3*streamEventBufferSize; i++ {
+			GetCircuit(fmt.Sprintf("command-%02d", i))
+		}
+
+		Convey("one snapshot holds an event for every command and pool", func() {
+			events, _ := streamSnapshot(0, 0, true)
+			So(events, ShouldHaveLength, 6*streamEventBufferSize)
+		})
+
+		Convey("without thread pools a snapshot only holds the commands", func() {
+			events, _ := streamSnapshot(0, 0, false)
+			So(events, ShouldHaveLength, 3*streamEventBufferSize)
+			for _, event := range events {
+				So(string(event), ShouldContainSubstring, `"type":"HystrixCommand"`)
+			}
+		})
+
+		Convey("a streamed second carries every command's event", func() {
+			server := startTestServer()
+			defer server.stopTestServer()
+
+			metrics, done := streamMetrics(t, server.URL)
+			seen := make(map[string]bool)
+			for m := range metrics {
+				var event streamCmdMetric
+				if strings.Contains(m, "HystrixCommand") && json.Unmarshal([]byte(m), &event) == nil {
+					seen[event.Name] = true
+				}
+				if len(seen) == 3*streamEventBufferSize {
+					done <- true
+					break
+				}
+			}
+			So(seen, ShouldHaveLength, 3*streamEventBufferSize)
+		})
+	})
+
+	Convey("with a maximum event rate below the number of events", t, func() {
+		defer Flush()
+		for _, name := range []string{"a", "b", "c"} {
+			GetCircuit(name)
+		}
+
+		Convey("each snapshot is capped, and carries on where the last stopped", func() {
+			names := make(map[string]bool)
+			next := 0
+			for i := 0; i < 3; i++ {
+				var events [][]byte
+				events, next = streamSnapshot(next, 2, true)
+				So(events, ShouldHaveLength, 2)
+				for _, e := range events {
+					var event streamCmdMetric
+					So(json.Unmarshal(e, &event), ShouldBeNil)
+					names[event.Type+"/"+event.Name] = true
+				}
+			}
+			So(names, ShouldHaveLength, 6)
+			So(next, ShouldEqual, 0)
+		})
 	})
 }
@@ -0,0 +1,90 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fairQueue hands the tickets of a pool to waiting commands in the order they started waiting.
+// A returned ticket only goes back into the pool's channel when nobody is waiting, and a command
+// only takes one from the channel when nobody is ahead of it.
+type fairQueue struct {
+	mutex   sync.Mutex
+	waiters []chan *struct{}
+}
+
+// put gives ticket to the longest waiting command, or puts it back in tickets if none is waiting.
+// Both happen under the mutex, so a command cannot start waiting just as a ticket is put back.
+func (q *fairQueue) put(tickets chan *struct{}, ticket *struct{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.waiters) == 0 {
+		tickets <- ticket
+		return
+	}
+	waiter := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	waiter <- ticket
+}
+
+// leave removes a waiter which has given up, reporting false if it was already handed a ticket.
+func (q *fairQueue) leave(waiter chan *struct{}) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, w := range q.waiters {
+		if w == waiter {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// acquireFair takes a ticket like acquire, queueing behind the commands already waiting.
+func (p *executorPool) acquireFair(ctx context.Context, name string, maxWait time.Duration) *struct{} {
+	q := p.fair
+
+	q.mutex.Lock()
+	if len(q.waiters) == 0 {
+		select {
+		case ticket := <-p.Tickets:
+			q.mutex.Unlock()
+			return ticket
+		default:
+		}
+	}
+	if maxWait <= 0 {
+		q.mutex.Unlock()
+		reportRejected(name)
+		p.Metrics.rejected()
+		return nil
+	}
+	// buffered, so handing over a ticket never blocks on a waiter which is giving up
+	waiter := make(chan *struct{}, 1)
+	q.waiters = append(q.waiters, waiter)
+	q.mutex.Unlock()
+	reportRejected(name)
+
+	atomic.AddInt32(&p.queued, 1)
+	defer atomic.AddInt32(&p.queued, -1)
+	timer := getClock().NewTimer(maxWait)
+	defer timer.Stop()
+
+	select {
+	case ticket := <-waiter:
+		return ticket
+	case <-timer.C():
+		p.Metrics.queueTimedOut()
+	case <-ctx.Done():
+	}
+
+	if !q.leave(waiter) {
+		// a ticket was handed over while giving up, so pass it on to the next in line
+		p.put(<-waiter)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package hystrix
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitingCommands returns how many commands are queued for one of the pool's tickets.
+func waitingCommands(pool *executorPool) int {
+	pool.fair.mutex.Lock()
+	defer pool.fair.mutex.Unlock()
+
+	return len(pool.fair.waiters)
+}
+
+func TestFairQueue(t *testing.T) {
+	Convey("with a fair pool of one executor which is in use", t, func() {
+		defer Flush()
+		ConfigureCommand("fair", CommandConfig{MaxConcurrentRequests: 1, FairQueue: true})
+		pool := newExecutorPool("fair")
+		ticket := pool.acquire(context.Background(), "", 0)
+		So(ticket, ShouldNotBeNil)
+
+		Convey("commands are granted the executor in the order they started waiting", func() {
+			granted := make(chan int, 5)
+			for i := 0; i < 5; i++ {
+				i := i
+				go func() {
+					ticket := pool.acquire(context.Background(), "", time.Second)
+					granted <- i
+					pool.Return(ticket)
+				}()
+				for waitingCommands(pool) < i+1 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			pool.Return(ticket)
+
+			order := make([]int, 0, 5)
+			for i := 0; i < 5; i++ {
+				order = append(order, <-granted)
+			}
+			So(order, ShouldResemble, []int{0, 1, 2, 3, 4})
+		})
+
+		Convey("a command which gives up leaves the queue", func() {
+			So(pool.acquire(context.Background(), "", 10*time.Millisecond), ShouldBeNil)
+			So(waitingCommands(pool), ShouldEqual, 0)
+
+			pool.Return(ticket)
+			So(pool.acquire(context.Background(), "", 0), ShouldNotBeNil)
+		})
+
+		Convey("without a wait, the command is rejected straight away", func() {
+			So(pool.acquire(context.Background(), "", 0), ShouldBeNil)
+			So(waitingCommands(pool), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoFallback is returned by RunFallback for a command with neither a registered fallback nor
+// a global fallback to run.
+var ErrNoFallback = CircuitError{Message: "no fallback"}
+
+var (
+	fallbacksMutex *sync.RWMutex
+	fallbacks      map[string]fallbackFuncC
+	globalFallback func(name string, err error) error
+)
+
+func init() {
+	fallbacksMutex = &sync.RWMutex{}
+	fallbacks = make(map[string]fallbackFuncC)
+}
+
+// RegisterFallback sets the default fallback for the named command, which is used whenever the
+// command is executed without a fallback of its own. A fallback passed to Go, Do or their
+// variants still takes precedence. Registering nil removes the default.
+//
+// Typed commands, such as DoTyped, which fall back to a registered fallback return the zero value.
+func RegisterFallback(name string, fallback fallbackFunc) {
+	if fallback == nil {
+		RegisterFallbackC(name, nil)
+		return
+	}
+	RegisterFallbackC(name, func(ctx context.Context, err error) error {
+		return fallback(err)
+	})
+}
+
+// RegisterFallbackC sets the default fallback for the named command like RegisterFallback, for
+// fallbacks which need the command's context.
+func RegisterFallbackC(name string, fallback fallbackFuncC) {
+	name = canonicalName(name)
+	fallbacksMutex.Lock()
+	defer fallbacksMutex.Unlock()
+
+	if fallback == nil {
+		delete(fallbacks, name)
+		return
+	}
+	fallbacks[name] = fallback
+}
+
+// SetGlobalFallback sets the fallback used by every command which has neither a fallback of its
+// own nor one registered with RegisterFallback, which suits returning the same error from every
+// command during an outage. It is passed the command's name along with the error. Setting nil
+// removes it.
+//
+// A command's fallback is chosen in this order: the fallback passed when it is executed, the one
+// registered for it with RegisterFallback, then the global fallback. With none of them, the
+// command sends the reason it failed.
+func SetGlobalFallback(fallback func(name string, err error) error) {
+	fallbacksMutex.Lock()
+	defer fallbacksMutex.Unlock()
+
+	globalFallback = fallback
+}
+
+// registeredFallback returns the default fallback for the named command, the global fallback
+// if it has none, or nil.
+func registeredFallback(name string) fallbackFuncC {
+	name = canonicalName(name)
+	fallbacksMutex.RLock()
+	defer fallbacksMutex.RUnlock()
+
+	if fallback, ok := fallbacks[name]; ok {
+		return fallback
+	}
+	if globalFallback == nil {
+		return nil
+	}
+
+	global := globalFallback
+	return func(ctx context.Context, err error) error {
+		return global(name, err)
+	}
+}
+
+// RunFallback runs the fallback the named command falls back to when executed without one of
+// its own, as chosen by RegisterFallback and SetGlobalFallback, without running the command,
+// such as to check a backup store works or to prime a cache during a drill. The fallback is
+// passed a *CommandError of KindFailure wrapping err, and its error is returned, or nil for a
+// StaleResult.
+//
+// The fallback takes one of the command's FallbackMaxConcurrent slots, failing with
+// ErrFallbackRejected when none is free, but nothing is recorded: the circuit, its metrics and
+// event listeners never see it, and FallbackTimeout does not apply. It fails with ErrNoFallback
+// when there is no fallback to run, and with ErrUnknownCommand for a command which is not
+// registered under RequireRegistration.
+func RunFallback(name string, err error) error {
+	if RequireRegistration && !isRegistered(name) {
+		return ErrUnknownCommand
+	}
+	fallback := registeredFallback(name)
+	if fallback == nil {
+		return ErrNoFallback
+	}
+	cb, _, cbErr := GetCircuit(name)
+	if cbErr != nil {
+		return cbErr
+	}
+
+	ticket, ok := cb.executorPool.acquireFallback()
+	if !ok {
+		return ErrFallbackRejected
+	}
+	cause := &CommandError{Name: name, Kind: KindFailure, Err: err}
+	fallbackErr := callFallback(context.Background(), fallback, cause)
+	cb.executorPool.returnFallback(ticket)
+
+	repanic(fallbackErr)
+	if errors.Is(fallbackErr, StaleResult) {
+		return nil
+	}
+	return fallbackErr
+}
+
+func flushFallbacks() {
+	fallbacksMutex.Lock()
+	defer fallbacksMutex.Unlock()
+
+	fallbacks = make(map[string]fallbackFuncC)
+	globalFallback = nil
+}
@@ -0,0 +1,229 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegisterFallback(t *testing.T) {
+	Convey("with a fallback registered for a command", t, func() {
+		defer Flush()
+
+		var registeredErr error
+		RegisterFallback("registered", func(err error) error {
+			registeredErr = err
+			return nil
+		})
+
+		Convey("executing the command without a fallback uses the registered one", func() {
+			err := Do("registered", func() error {
+				return fmt.Errorf("run_error")
+			}, nil)
+
+			So(err, ShouldBeNil)
+			So(registeredErr, ShouldNotBeNil)
+			So(registeredErr.Error(), ShouldEqual, "run_error")
+		})
+
+		Convey("a fallback passed to the command takes precedence", func() {
+			err := Do("registered", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return fmt.Errorf("explicit_error")
+			})
+
+			So(err.Error(), ShouldEqual, "fallback failed with 'explicit_error'. run error was 'run_error'")
+			So(registeredErr, ShouldBeNil)
+		})
+
+		Convey("other commands are not affected", func() {
+			err := Do("other", func() error {
+				return fmt.Errorf("run_error")
+			}, nil)
+
+			So(err.Error(), ShouldEqual, "run_error")
+			So(registeredErr, ShouldBeNil)
+		})
+
+		Convey("registering nil removes the fallback", func() {
+			RegisterFallback("registered", nil)
+			err := Do("registered", func() error {
+				return fmt.Errorf("run_error")
+			}, nil)
+
+			So(err.Error(), ShouldEqual, "run_error")
+		})
+	})
+
+	Convey("with a context fallback registered for a command whose circuit is open", t, func() {
+		defer Flush()
+
+		RegisterFallbackC("registered", func(ctx context.Context, err error) error {
+			return err
+		})
+		cb, _, _ := GetCircuit("registered")
+		cb.toggleForceOpen(true)
+
+		Convey("GoC passes the circuit error to the registered fallback", func() {
+			err := <-GoC(context.Background(), "registered", func(ctx context.Context) error {
+				return nil
+			}, nil)
+
+			So(err.Error(), ShouldEqual, "fallback failed with 'hystrix: circuit open'. run error was 'hystrix: circuit open'")
+		})
+	})
+}
+
+func TestSetGlobalFallback(t *testing.T) {
+	Convey("with a global fallback set", t, func() {
+		defer Flush()
+
+		var globalName string
+		var globalErr error
+		SetGlobalFallback(func(name string, err error) error {
+			globalName = name
+			globalErr = err
+			return fmt.Errorf("unavailable")
+		})
+		fail := func() error { return fmt.Errorf("run_error") }
+
+		Convey("commands without a fallback use it", func() {
+			err := Do("global", fail, nil)
+
+			So(err.Error(), ShouldEqual, "fallback failed with 'unavailable'. run error was 'run_error'")
+			So(globalName, ShouldEqual, "global")
+			So(globalErr.Error(), ShouldEqual, "run_error")
+		})
+
+		Convey("a registered fallback takes precedence", func() {
+			RegisterFallback("global", func(err error) error { return nil })
+
+			So(Do("global", fail, nil), ShouldBeNil)
+			So(globalErr, ShouldBeNil)
+		})
+
+		Convey("a fallback passed to the command takes precedence", func() {
+			So(Do("global", fail, func(err error) error { return nil }), ShouldBeNil)
+			So(globalErr, ShouldBeNil)
+		})
+
+		Convey("setting nil removes it", func() {
+			SetGlobalFallback(nil)
+
+			So(Do("global", fail, nil).Error(), ShouldEqual, "run_error")
+			So(globalErr, ShouldBeNil)
+		})
+
+		Convey("Flush removes it", func() {
+			Flush()
+
+			So(Do("global", fail, nil).Error(), ShouldEqual, "run_error")
+			So(globalErr, ShouldBeNil)
+		})
+	})
+}
+
+func TestRunFallback(t *testing.T) {
+	Convey("with a registered fallback", t, func() {
+		defer Flush()
+
+		drill := fmt.Errorf("drill")
+		reasons := make(chan error, 2)
+		release := make(chan struct{})
+		RegisterFallback("backup", func(err error) error {
+			reasons <- err
+			if errors.Is(err, errBlockFallback) {
+				<-release
+			}
+			return nil
+		})
+
+		Convey("it is run with a CommandError wrapping the error", func() {
+			So(RunFallback("backup", drill), ShouldBeNil)
+			reason := <-reasons
+			var cmdErr *CommandError
+			So(errors.As(reason, &cmdErr), ShouldBeTrue)
+			So(cmdErr.Kind, ShouldEqual, KindFailure)
+			So(errors.Is(reason, drill), ShouldBeTrue)
+
+			Convey("without recording anything against the command", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("backup").Requests, ShouldEqual, 0)
+				So(GetMetrics("backup").FallbackSuccesses, ShouldEqual, 0)
+				So(GetState("backup"), ShouldEqual, CircuitClosed)
+			})
+		})
+
+		Convey("it is rejected once FallbackMaxConcurrent fallbacks are running", func() {
+			ConfigureCommand("backup", CommandConfig{FallbackMaxConcurrent: 1})
+			done := make(chan error)
+			go func() {
+				done <- RunFallback("backup", errBlockFallback)
+			}()
+			<-reasons
+			So(RunFallback("backup", drill), ShouldEqual, ErrFallbackRejected)
+			close(release)
+			So(<-done, ShouldBeNil)
+		})
+	})
+
+	Convey("a command without a fallback fails with ErrNoFallback", t, func() {
+		defer Flush()
+		So(RunFallback("no_backup", fmt.Errorf("drill")), ShouldEqual, ErrNoFallback)
+	})
+}
+
+// errBlockFallback makes the fallback of TestRunFallback wait to be released.
+var errBlockFallback = errors.New("block")
+
+func TestCountFallbackSuccessAsSuccess(t *testing.T) {
+	Convey("with a command whose run fails and fallback succeeds", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2})
+		failWithFallback := func() error {
+			return Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return nil
+			})
+		}
+
+		Convey("by default the breaker still sees the run's failure", func() {
+			So(failWithFallback(), ShouldBeNil)
+			So(failWithFallback(), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetMetrics("").Failures, ShouldEqual, 2)
+			So(GetMetrics("").FallbackSuccesses, ShouldEqual, 2)
+			So(GetHealth("").Errors, ShouldEqual, 2)
+			So(IsOpen(""), ShouldBeTrue)
+		})
+
+		Convey("with CountFallbackSuccessAsSuccess the execution counts as a success", func() {
+			ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 2, CountFallbackSuccessAsSuccess: true})
+			So(failWithFallback(), ShouldBeNil)
+			So(failWithFallback(), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetMetrics("").Failures, ShouldEqual, 0)
+			So(GetMetrics("").Successes, ShouldEqual, 2)
+			So(GetMetrics("").FallbackSuccesses, ShouldEqual, 2)
+			So(IsOpen(""), ShouldBeFalse)
+
+			Convey("but a fallback which fails still counts the run's failure", func() {
+				Do("", func() error {
+					return fmt.Errorf("run_error")
+				}, func(err error) error {
+					return fmt.Errorf("fallback_error")
+				})
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").Failures, ShouldEqual, 1)
+			})
+		})
+	})
+}
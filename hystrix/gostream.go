@@ -0,0 +1,66 @@
+package hystrix
+
+import (
+	"context"
+)
+
+// GoStream runs your function asynchronously like Go, sending the value produced by run, or by
+// fallback if it was used, on the returned result channel. If the command fails, its error is
+// sent on the error channel instead. Exactly one of the two receives something, and both are
+// closed once the command has finished, so they can be read from a select loop.
+func GoStream(name string, run func() (interface{}, error), fallback func(error) (interface{}, error)) (chan interface{}, chan error) {
+	runC := func(ctx context.Context) (interface{}, error) {
+		return run()
+	}
+	var fallbackC func(context.Context, error) (interface{}, error)
+	if fallback != nil {
+		fallbackC = func(ctx context.Context, err error) (interface{}, error) {
+			return fallback(err)
+		}
+	}
+	return GoStreamC(context.Background(), name, runC, fallbackC)
+}
+
+// GoStreamC runs your function asynchronously like GoC, delivering its value like GoStream.
+// A command served by a registered or global fallback sends a nil value, even if run returned
+// one along with its error.
+func GoStreamC(ctx context.Context, name string, run func(context.Context) (interface{}, error), fallback func(context.Context, error) (interface{}, error)) (chan interface{}, chan error) {
+	name = canonicalName(name)
+	results := make(chan interface{}, 1)
+	errs := make(chan error, 1)
+
+	// Like DoTypedC, the value is cached here rather than by GoC, which would only know that
+	// run succeeded.
+	cache, cacheKey, ctx := requestCacheFor(ctx, name)
+	if cache != nil {
+		if v, ok := cache.get(cacheKey); ok {
+			emitEvent(Event{Name: name, Type: "response-from-cache", Tags: tagsFor(ctx, name)})
+			results <- v
+			close(results)
+			close(errs)
+			return results, errs
+		}
+	}
+
+	var captured capturedValue
+	errChan := GoC(ctx, name, captured.wrapRun(run), captured.wrapFallback(name, fallback))
+	go func() {
+		defer close(errs)
+		defer close(results)
+
+		// errChan is only closed once the function which completed the command has returned,
+		// so the value is final by then.
+		if err := <-errChan; err != nil {
+			errs <- err
+			return
+		}
+
+		result, fromRun := captured.result()
+		if cache != nil && fromRun {
+			cache.set(cacheKey, result)
+		}
+		results <- result
+	}()
+
+	return results, errs
+}
@@ -0,0 +1,101 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGoStream(t *testing.T) {
+	Convey("with a command executed with GoStream", t, func() {
+		defer Flush()
+
+		Convey("a successful run sends its value and no error", func() {
+			results, errs := GoStream("", func() (interface{}, error) {
+				return 1, nil
+			}, nil)
+			So(<-results, ShouldEqual, 1)
+			_, ok := <-errs
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("a failed run sends the fallback's value", func() {
+			results, errs := GoStream("", func() (interface{}, error) {
+				return "run", fmt.Errorf("run_error")
+			}, func(err error) (interface{}, error) {
+				return "fallback", nil
+			})
+			So(<-results, ShouldEqual, "fallback")
+			So(<-errs, ShouldBeNil)
+		})
+
+		Convey("a failed run served by a registered fallback sends a nil value", func() {
+			RegisterFallback("", func(err error) error { return nil })
+			results, errs := GoStream("", func() (interface{}, error) {
+				return "partial", fmt.Errorf("run_error")
+			}, nil)
+			So(<-results, ShouldBeNil)
+			So(<-errs, ShouldBeNil)
+		})
+
+		Convey("a failed command sends only its error", func() {
+			results, errs := GoStream("", func() (interface{}, error) {
+				return 1, fmt.Errorf("run_error")
+			}, nil)
+			So(<-errs, ShouldResemble, fmt.Errorf("run_error"))
+			_, ok := <-results
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("a timed out run cannot send its value", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 10})
+			finished := make(chan struct{})
+
+			results, errs := GoStream("", func() (interface{}, error) {
+				defer close(finished)
+				time.Sleep(50 * time.Millisecond)
+				return "run", nil
+			}, func(err error) (interface{}, error) {
+				return "fallback", nil
+			})
+
+			var received []interface{}
+			for results != nil || errs != nil {
+				select {
+				case v, ok := <-results:
+					if !ok {
+						results = nil
+						continue
+					}
+					received = append(received, v)
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
+					}
+					received = append(received, err)
+				}
+			}
+			So(received, ShouldResemble, []interface{}{"fallback"})
+			<-finished
+		})
+
+		Convey("a cached value is sent without running", func() {
+			ctx := WithCacheKey(WithRequestCache(context.Background(), 0), "key")
+			runs := 0
+			run := func(ctx context.Context) (interface{}, error) {
+				runs++
+				return runs, nil
+			}
+
+			results, _ := GoStreamC(ctx, "", run, nil)
+			So(<-results, ShouldEqual, 1)
+			results, _ = GoStreamC(ctx, "", run, nil)
+			So(<-results, ShouldEqual, 1)
+			So(runs, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package hystrix
+
+import (
+	"sync"
+)
+
+var (
+	groupErrorThresholdsMutex *sync.RWMutex
+	groupErrorThresholds      map[string]int
+)
+
+func init() {
+	groupErrorThresholdsMutex = &sync.RWMutex{}
+	groupErrorThresholds = make(map[string]int)
+}
+
+// SetGroupErrorThreshold makes GroupHealthy report the named group as unhealthy once any of its
+// commands' error percentage reaches percent, even while its circuit is still closed, such as to
+// take an instance out of a load balancer before its circuits start to open. Like the circuit,
+// a command is only judged on its error percentage once its rolling window holds at least its
+// RequestVolumeThreshold requests. A percent of zero or less removes the threshold, leaving only
+// open circuits to make the group unhealthy. Flush removes every threshold.
+func SetGroupErrorThreshold(group string, percent int) {
+	groupErrorThresholdsMutex.Lock()
+	defer groupErrorThresholdsMutex.Unlock()
+
+	if percent <= 0 {
+		delete(groupErrorThresholds, group)
+		return
+	}
+	groupErrorThresholds[group] = percent
+}
+
+func groupErrorThreshold(group string) int {
+	groupErrorThresholdsMutex.RLock()
+	defer groupErrorThresholdsMutex.RUnlock()
+
+	return groupErrorThresholds[group]
+}
+
+func flushGroupErrorThresholds() {
+	groupErrorThresholdsMutex.Lock()
+	defer groupErrorThresholdsMutex.Unlock()
+
+	groupErrorThresholds = make(map[string]int)
+}
+
+// GroupHealthy reports whether every command configured with the given Group is healthy, such
+// as for a readiness probe which should fail while a dependency is down. A command is unhealthy
+// while its circuit is open, forced open or half-open, since most of its executions are still
+// short-circuited, or once its error percentage reaches the threshold set by
+// SetGroupErrorThreshold.
+//
+// Only commands which have a circuit, because they have been executed or checked, are judged,
+// so commands which were never configured or never run are ignored. A group with no such
+// commands is healthy. Commands configured without a Group form the group "".
+func GroupHealthy(group string) bool {
+	threshold := groupErrorThreshold(group)
+	now := getClock().Now()
+	for _, cb := range sortedCircuits() {
+		settings := getSettings(cb.Name)
+		if settings.Group != group {
+			continue
+		}
+		if forceOpen, _ := cb.forced(); forceOpen || cb.State() != CircuitClosed {
+			return false
+		}
+		if threshold == 0 {
+			continue
+		}
+		health := cb.metrics.Health(now)
+		if health.Total >= settings.RequestVolumeThreshold && health.ErrorPercentage >= threshold {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package hystrix
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGroupHealthy(t *testing.T) {
+	Convey("with two commands in a group", t, func() {
+		defer Flush()
+		ConfigureCommand("users_get", CommandConfig{Group: "users", RequestVolumeThreshold: 10, ErrorPercentThreshold: 50})
+		ConfigureCommand("users_put", CommandConfig{Group: "users", RequestVolumeThreshold: 10, ErrorPercentThreshold: 50})
+		ConfigureCommand("orders_get", CommandConfig{Group: "orders"})
+		report := func(name string, outcome Outcome, n int) {
+			for i := 0; i < n; i++ {
+				ReportEvent(name, outcome, time.Millisecond)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("a group whose commands never ran is healthy", func() {
+			So(GroupHealthy("users"), ShouldBeTrue)
+			So(GroupHealthy("unknown"), ShouldBeTrue)
+		})
+
+		Convey("one open circuit makes the group unhealthy", func() {
+			report("users_get", OutcomeSuccess, 5)
+			report("users_put", OutcomeFailure, 10)
+			So(IsOpen("users_put"), ShouldBeTrue)
+			So(GroupHealthy("users"), ShouldBeFalse)
+
+			Convey("but not other groups", func() {
+				So(GroupHealthy("orders"), ShouldBeTrue)
+			})
+		})
+
+		Convey("a circuit forced open makes the group unhealthy", func() {
+			ForceOpen("users_put")
+			So(GroupHealthy("users"), ShouldBeFalse)
+		})
+
+		Convey("with an error threshold below the circuits'", func() {
+			SetGroupErrorThreshold("users", 20)
+
+			Convey("a command reaching it makes the group unhealthy while its circuit is closed", func() {
+				report("users_get", OutcomeSuccess, 8)
+				report("users_get", OutcomeFailure, 2)
+				So(GetState("users_get"), ShouldEqual, CircuitClosed)
+				So(GroupHealthy("users"), ShouldBeFalse)
+			})
+
+			Convey("a command below its request volume is not judged", func() {
+				report("users_get", OutcomeFailure, 3)
+				So(GroupHealthy("users"), ShouldBeTrue)
+			})
+
+			Convey("removing it leaves only open circuits to count", func() {
+				report("users_get", OutcomeSuccess, 8)
+				report("users_get", OutcomeFailure, 2)
+				SetGroupErrorThreshold("users", 0)
+				So(GroupHealthy("users"), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -2,7 +2,9 @@ package hystrix
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 )
@@ -22,6 +24,91 @@ func (e CircuitError) Error() string {
 	return "hystrix: " + e.Message
 }
 
+// A PanicError is returned when a run or fallback function panics. It is treated like any
+// other error returned by that function. SetPanicHandling can make panics propagate instead.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("hystrix: recovered from panic: %v", e.Value)
+}
+
+// ErrorKind says why a command's fallback was run. Each kind is named after the event the
+// command reported.
+type ErrorKind string
+
+const (
+	// KindFailure is for a run which returned an error.
+	KindFailure ErrorKind = "failure"
+	// KindTimeout is for a run which took longer than the command's timeout.
+	KindTimeout ErrorKind = "timeout"
+	// KindShortCircuit is for a command whose circuit was open.
+	KindShortCircuit ErrorKind = "short-circuit"
+	// KindRejected is for a command which could not get an executor, or was not allowed to run
+	// at all, such as during a shutdown.
+	KindRejected ErrorKind = "rejected"
+	// KindRateLimited is for a command executed more often than its RateLimit allows.
+	KindRateLimited ErrorKind = "rate-limited"
+	// KindContextCanceled is for a command whose caller canceled its context.
+	KindContextCanceled ErrorKind = "context_canceled"
+	// KindContextDeadlineExceeded is for a command whose caller's deadline passed.
+	KindContextDeadlineExceeded ErrorKind = "context_deadline_exceeded"
+)
+
+// A CommandError is passed to fallbacks, saying which command failed and why. Its message is
+// that of Err, and it unwraps to Err, so errors.Is(err, ErrTimeout) still works, while
+// errors.As lets a fallback branch on Kind.
+type CommandError struct {
+	Name string
+	Kind ErrorKind
+	// Err is the error returned by run, or the sentinel error such as ErrCircuitOpen which
+	// stopped it running.
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// businessError wraps an error returned by run which should not count against the circuit.
+type businessError struct {
+	err error
+}
+
+func (e businessError) Error() string {
+	return e.err.Error()
+}
+
+func (e businessError) Unwrap() error {
+	return e.err
+}
+
+// BusinessError marks an error returned by run as an outcome of the request rather than a
+// problem with the dependency, such as a user which does not exist. The command records the
+// execution as a success, skips the fallback and retries, and returns err to the caller without
+// the mark. It complements a command's IsFailure, for errors only run can tell apart. A nil err
+// stays nil.
+func BusinessError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return businessError{err: err}
+}
+
+// StaleResult is returned by a fallback which served the call, but from stale data such as an
+// expired cache entry. It is not an error: the caller receives nil as for any fallback which
+// succeeded, while the execution is recorded as a "fallback-stale" event and DoWithResult
+// reports it as Stale. A fallback may also return it wrapped.
+var StaleResult = errors.New("hystrix: stale result")
+
 // command models the state used for a single execution on a circuit. "hystrix command" is commonly
 // used to describe the pairing of your run/fallback functions with a circuit.
 type command struct {
@@ -30,105 +117,256 @@ type command struct {
 	ticket      *struct{}
 	start       time.Time
 	errChan     chan error
-	finished    chan bool
 	circuit     *CircuitBreaker
 	run         runFuncC
 	fallback    fallbackFuncC
 	runDuration time.Duration
 	events      []string
+	span        Span
+	// fallbackDuration is how long the fallback ran, or was waited for, if it ran.
+	fallbackDuration time.Duration
+	// returnErr is the error sent on errChan by sendErr, if any. errChan receives at most one
+	// error and is then closed, and a command which succeeds closes it without sending.
+	returnErr error
+	// cache is the request cache a successful run is stored in, if any.
+	cache    *requestCache
+	cacheKey requestCacheKey
+	// tags label the command's events and metrics, and must not be modified.
+	tags map[string]string
+	// inline is set when the caller waits for the command on its own goroutine, as with Do.
+	inline bool
+	// durations receives the run and fallback durations when the command finishes, if set.
+	durations *commandDurations
+
+	// ticketCond is signaled once ticketChecked is set, meaning the run goroutine
+	// has either taken a ticket or given up on getting one.
+	ticketCond    sync.Cond
+	ticketChecked bool
+	// runReturned is set once run has returned, after which the command cannot time out, however
+	// long its fallback takes.
+	runReturned bool
+	// returnOnce is shared by the run and watcher goroutines. It ensures only the faster
+	// goroutine runs errorWithFallback() and reportAllEvent(), and closes errChan.
+	returnOnce sync.Once
+	// eventBuf backs events, which rarely holds more than a result and a fallback result.
+	eventBuf [2]string
 }
 
 var (
 	// ErrMaxConcurrency occurs when too many of the same named command are executed at the same time.
+	// It means the command's MaxConcurrentRequests limit was hit and run was never called, not that
+	// the dependency itself failed.
 	ErrMaxConcurrency = CircuitError{Message: "max concurrency"}
 	// ErrCircuitOpen returns when an execution attempt "short circuits". This happens due to the circuit being measured as unhealthy.
 	ErrCircuitOpen = CircuitError{Message: "circuit open"}
+	// ErrFallbackRejected occurs when too many fallbacks of a command are already running, so the
+	// fallback was skipped. It is returned wrapped together with the run error, so match it with
+	// errors.Is.
+	ErrFallbackRejected = CircuitError{Message: "fallback rejected"}
 	// ErrTimeout occurs when the provided function takes too long to execute.
 	ErrTimeout = CircuitError{Message: "timeout"}
+	// ErrFallbackTimeout occurs when a fallback takes longer than the command's FallbackTimeout.
+	// Like ErrFallbackRejected, it is returned wrapped together with the run error.
+	ErrFallbackTimeout = CircuitError{Message: "fallback timeout"}
+	// ErrUnknownCommand is passed to the fallback of a command which was never configured, when
+	// RequireRegistration is set. ForceHalfOpen and ProbeEarly also return it for a command which
+	// has no circuit because it has not been executed yet.
+	ErrUnknownCommand = CircuitError{Message: "unknown command"}
+	// ErrCustomBreaker is returned by ForceHalfOpen and ProbeEarly for a command with its own
+	// Breaker, whose state is the Breaker's to change.
+	ErrCustomBreaker = CircuitError{Message: "command has its own breaker"}
+	// ErrRateLimited occurs when a command is executed more often than its RateLimit allows. Like
+	// ErrMaxConcurrency, run was never called.
+	ErrRateLimited = CircuitError{Message: "rate limited"}
 )
 
 // Go runs your function while tracking the health of previous calls to it.
 // If your function begins slowing down or failing repeatedly, we will block
 // new calls to it for you to give the dependent service time to repair.
 //
-// Define a fallback function if you want to define some code to execute during outages.
+// Define a fallback function if you want to define some code to execute during outages. Without
+// one, any fallback registered with RegisterFallback or set with SetGlobalFallback is used. The
+// fallback is passed a *CommandError wrapping the reason the command failed. A command with no
+// fallback at all sends the reason it failed: ErrCircuitOpen when short-circuited,
+// ErrMaxConcurrency when rejected, ErrRateLimited when over its rate limit, ErrTimeout when it
+// timed out, or the error returned by run.
+//
+// The returned channel receives at most one error, and is closed once the command has finished.
+// A command which succeeds closes the channel without sending anything.
 func Go(name string, run runFunc, fallback fallbackFunc) chan error {
+	return GoWithOptions(name, run, WithFallback(fallback))
+}
+
+// GoWithOptions runs your function like Go, with opts choosing how this execution behaves, such
+// as WithFallback for its fallback, WithTimeout for its timeout and WithCallTags for its tags.
+// Without WithFallback, any fallback registered with RegisterFallback or set with
+// SetGlobalFallback is used.
+func GoWithOptions(name string, run runFunc, opts ...CommandOption) chan error {
 	runC := func(ctx context.Context) error {
 		return run()
 	}
-	var fallbackC fallbackFuncC
-	if fallback != nil {
-		fallbackC = func(ctx context.Context, err error) error {
-			return fallback(err)
+	return GoC(context.Background(), name, runC, nil, opts...)
+}
+
+// GoMulti runs your function like Go, but with a chain of fallbacks for layered degradation.
+// When run fails the fallbacks are tried in order until one succeeds, each being passed the
+// error returned by the one before it. The first fallback is passed the run error.
+//
+// The returned channel only receives an error if every fallback fails, in which case it
+// reports the last fallback's error along with the run error.
+func GoMulti(name string, run runFunc, fallbacks ...fallbackFunc) chan error {
+	runC := func(ctx context.Context) error {
+		return run()
+	}
+	fallbacksC := make([]fallbackFuncC, 0, len(fallbacks))
+	for _, fallback := range fallbacks {
+		if fallback == nil {
+			continue
 		}
+		fallback := fallback
+		fallbacksC = append(fallbacksC, func(ctx context.Context, err error) error {
+			return fallback(err)
+		})
 	}
-	return GoC(context.Background(), name, runC, fallbackC)
+	return GoC(context.Background(), name, runC, chainFallbacks(fallbacksC))
 }
 
 // GoC runs your function while tracking the health of previous calls to it.
 // If your function begins slowing down or failing repeatedly, we will block
 // new calls to it for you to give the dependent service time to repair.
 //
-// Define a fallback function if you want to define some code to execute during outages.
-func GoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC) chan error {
+// The context passed to run is canceled when ctx is done or when the command times out. A
+// command which ends because ctx was canceled or passed its deadline records a
+// "context_canceled" or "context_deadline_exceeded" event, and is left out of the circuit's
+// health, since the caller gave up rather than the dependency failing.
+//
+// Define a fallback function if you want to define some code to execute during outages. Without
+// one, any fallback registered with RegisterFallback or set with SetGlobalFallback is used.
+//
+// The returned channel receives at most one error, and is closed once the command has finished.
+// A command which succeeds closes the channel without sending anything.
+//
+// opts change how this execution behaves, such as WithTimeout. A fallback set by WithFallback is
+// used only when fallback is nil.
+func GoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC, opts ...CommandOption) chan error {
+	ctx, fallback = newCommandOptions(opts).apply(ctx, fallback)
+	return goC(ctx, name, run, fallback, false, opts...)
+}
+
+// goC executes a command like GoC, whose caller has already applied the context and fallback
+// chosen by opts, leaving only the timeout to goC. inline is set by callers which wait for the
+// command on their own goroutine. A command with nothing to watch for, since it has no timeout
+// and ctx cannot be canceled, is then executed on the caller's goroutine, and has finished by
+// the time goC returns. A panic propagated under Propagate is also sent on errChan for the caller to
+// re-panic, rather than re-panicked by the command.
+func goC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC, inline bool, opts ...CommandOption) chan error {
+	name = canonicalName(name)
+	run = withMiddleware(name, run)
+	if !IsEnabled(name) {
+		errChan := make(chan error, 1)
+		go runUnprotected(ctx, run, errChan, inline)
+		return errChan
+	}
+
+	if fallback == nil {
+		fallback = registeredFallback(name)
+	}
+	options := newCommandOptions(opts)
 	cmd := &command{
-		run:      run,
-		fallback: fallback,
-		start:    time.Now(),
-		errChan:  make(chan error, 1),
-		finished: make(chan bool, 1),
+		run:       run,
+		fallback:  fallback,
+		start:     getClock().Now(),
+		errChan:   make(chan error, 1),
+		inline:    inline,
+		durations: options.durations,
+	}
+	cmd.ticketCond.L = cmd
+	cmd.events = cmd.eventBuf[:0]
+	cmd.tags = tagsFor(ctx, name)
+
+	cache, cacheKey, ctx := requestCacheFor(ctx, name)
+	if cache != nil {
+		if _, ok := cache.get(cacheKey); ok {
+			emitEvent(Event{Name: name, Type: "response-from-cache", Tags: cmd.tags})
+			close(cmd.errChan)
+			return cmd.errChan
+		}
+		cmd.cache = cache
+		cmd.cacheKey = cacheKey
 	}
 
 	// dont have methods with explicit params and returns
 	// let data come in and out naturally, like with any closure
 	// explicit error return to give place for us to kill switch the operation (fallback)
 
+	if RequireRegistration && !isRegistered(name) {
+		go rejectCommand(ctx, name, fallback, ErrUnknownCommand, cmd.errChan, inline)
+		return cmd.errChan
+	}
+
+	timeout := options.timeoutFor(name)
+	// Without a timeout or a ctx which can be canceled, nothing can interrupt the command, so
+	// there is nothing for a watcher goroutine to watch for.
+	watch := timeout > 0 || ctx.Done() != nil
+	goroutines := 1
+	if watch {
+		goroutines = 2
+	}
+	// This comes before GetCircuit, so commands executed during a shutdown never create circuits
+	// and their metric goroutines.
+	gen, ok := beginCommand(goroutines)
+	if !ok {
+		go rejectCommand(ctx, name, fallback, ErrShuttingDown, cmd.errChan, inline)
+		return cmd.errChan
+	}
+
 	circuit, _, err := GetCircuit(name)
 	if err != nil {
+		for i := 0; i < goroutines; i++ {
+			endCommand(gen)
+		}
 		cmd.errChan <- err
+		close(cmd.errChan)
 		return cmd.errChan
 	}
 	cmd.circuit = circuit
-	ticketCond := sync.NewCond(cmd)
-	ticketChecked := false
-	// When the caller extracts error from returned errChan, it's assumed that
-	// the ticket's been returned to executorPool. Therefore, returnTicket() can
-	// not run after cmd.errorWithFallback().
-	returnTicket := func() {
-		cmd.Lock()
-		// Avoid releasing before a ticket is acquired.
-		for !ticketChecked {
-			ticketCond.Wait()
-		}
-		cmd.circuit.executorPool.Return(cmd.ticket)
-		cmd.Unlock()
-	}
-	// Shared by the following two goroutines. It ensures only the faster
-	// goroutine runs errWithFallback() and reportAllEvent().
-	returnOnce := &sync.Once{}
-	reportAllEvent := func() {
-		err := cmd.circuit.ReportEvent(cmd.events, cmd.start, cmd.runDuration)
-		if err != nil {
-			log.Printf(err.Error())
-		}
+	if t := getTracer(); t != nil {
+		ctx, cmd.span = t.StartSpan(ctx, name)
 	}
 
-	go func() {
-		defer func() { cmd.finished <- true }()
+	// run gets its own context so that it can be told to stop once the command times out.
+	// It is also canceled once the run goroutine exits, which tells the watcher goroutine
+	// that the command has finished.
+	runCtx, cancelRun := context.WithCancel(ctx)
+
+	execute := func() {
+		defer endCommand(gen)
+		defer cancelRun()
+
+		// The rate limit comes first, so executions it holds back never take a half-open
+		// circuit's test request.
+		if !cmd.circuit.rateLimiter.allow(getSettings(name).RateLimit) {
+			cmd.setTicket(nil)
+			cmd.returnOnce.Do(func() {
+				cmd.returnTicket()
+				cmd.errorWithFallback(ctx, ErrRateLimited)
+				cmd.reportAllEvent()
+				close(cmd.errChan)
+			})
+			return
+		}
 
 		// Circuits get opened when recent executions have shown to have a high error rate.
 		// Rejecting new executions allows backends to recover, and the circuit will allow
 		// new traffic when it feels a healthly state has returned.
 		if !cmd.circuit.AllowRequest() {
-			cmd.Lock()
 			// It's safe for another goroutine to go ahead releasing a nil ticket.
-			ticketChecked = true
-			ticketCond.Signal()
-			cmd.Unlock()
-			returnOnce.Do(func() {
-				returnTicket()
+			cmd.setTicket(nil)
+			cmd.returnOnce.Do(func() {
+				cmd.returnTicket()
 				cmd.errorWithFallback(ctx, ErrCircuitOpen)
-				reportAllEvent()
+				cmd.reportAllEvent()
+				close(cmd.errChan)
 			})
 			return
 		}
@@ -138,57 +376,123 @@ func GoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC)
 		// When requests slow down but the incoming rate of requests stays the same, you have to
 		// run more at a time to keep up. By controlling concurrency during these situations, you can
 		// shed load which accumulates due to the increasing ratio of active commands to incoming requests.
-		cmd.Lock()
-		select {
-		case cmd.ticket = <-circuit.executorPool.Tickets:
-			ticketChecked = true
-			ticketCond.Signal()
-			cmd.Unlock()
-		default:
-			ticketChecked = true
-			ticketCond.Signal()
-			cmd.Unlock()
-			returnOnce.Do(func() {
-				returnTicket()
-				cmd.errorWithFallback(ctx, ErrMaxConcurrency)
-				reportAllEvent()
+		ticket := circuit.executorPool.acquire(runCtx, name, getSettings(name).MaxQueueWait)
+		cmd.setTicket(ticket)
+		if ticket == nil {
+			// While waiting for a ticket the command may have timed out or been canceled, in
+			// which case that is the real reason it failed.
+			var err error = ErrMaxConcurrency
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			} else if runCtx.Err() != nil {
+				err = ErrTimeout
+			}
+			cmd.returnOnce.Do(func() {
+				cmd.returnTicket()
+				cmd.errorWithFallback(ctx, err)
+				cmd.reportAllEvent()
+				close(cmd.errChan)
 			})
 			return
 		}
 
-		runStart := time.Now()
-		runErr := run(ctx)
-		returnOnce.Do(func() {
-			defer reportAllEvent()
-			cmd.runDuration = time.Since(runStart)
-			returnTicket()
-			if runErr != nil {
-				cmd.errorWithFallback(ctx, runErr)
+		runStart := getClock().Now()
+		emitEvent(Event{Name: name, Type: "attempt", Duration: runStart.Sub(cmd.start), Tags: cmd.tags})
+		runErr := callRunWithRetries(runCtx, name, run)
+		cmd.Lock()
+		cmd.runReturned = true
+		cmd.Unlock()
+		if runErr != nil && ctx.Err() == nil && runCtx.Err() != nil {
+			// run gave up because the command timed out, which may have beaten the watcher
+			runErr = ErrTimeout
+		}
+		propagated := false
+		cmd.returnOnce.Do(func() {
+			cmd.runDuration = getClock().Now().Sub(runStart)
+			cmd.returnTicket()
+			if p, ok := runErr.(propagatedPanic); ok {
+				// the panic is a bug for the caller to see, not a sign of the dependency's health
+				propagated = true
+				if cmd.span != nil {
+					cmd.endSpan()
+				}
+				cmd.propagate(p)
+				close(cmd.errChan)
 				return
 			}
-			cmd.reportEvent("success")
+			if runErr != nil && !isFailure(name, runErr) {
+				// The dependency is healthy, so only the caller needs to see this error.
+				if b, ok := runErr.(businessError); ok {
+					runErr = b.err
+				}
+				cmd.reportEvent("success", runErr)
+				cmd.sendErr(runErr)
+			} else if runErr != nil {
+				cmd.errorWithFallback(ctx, runErr)
+			} else {
+				cmd.reportEvent("success", nil)
+				if cmd.cache != nil {
+					cmd.cache.set(cmd.cacheKey, nil)
+				}
+			}
+			cmd.reportAllEvent()
+			close(cmd.errChan)
 		})
-	}()
+		if !propagated {
+			// the command already finished, so nobody is waiting for the panic
+			repanic(runErr)
+		}
+	}
+
+	if !watch {
+		if inline {
+			execute()
+		} else {
+			go execute()
+		}
+		return cmd.errChan
+	}
+	go execute()
 
 	go func() {
-		timer := time.NewTimer(getSettings(name).Timeout)
-		defer timer.Stop()
+		defer endCommand(gen)
+
+		// a nil channel never fires, so commands without a timeout only watch ctx
+		var timerC <-chan time.Time
+		if timeout > 0 {
+			timer := getClock().NewTimer(timeout)
+			defer timer.Stop()
+			timerC = timer.C()
+		}
 
 		select {
-		case <-cmd.finished:
-			// returnOnce has been executed in another goroutine
-		case <-ctx.Done():
-			returnOnce.Do(func() {
-				returnTicket()
+		case <-runCtx.Done():
+			if ctx.Err() == nil {
+				// the run goroutine has finished, and executed returnOnce
+				return
+			}
+			cmd.returnOnce.Do(func() {
+				cmd.returnTicket()
 				cmd.errorWithFallback(ctx, ctx.Err())
-				reportAllEvent()
+				cmd.reportAllEvent()
+				close(cmd.errChan)
 			})
 			return
-		case <-timer.C:
-			returnOnce.Do(func() {
-				returnTicket()
+		case <-timerC:
+			cmd.Lock()
+			runReturned := cmd.runReturned
+			cmd.Unlock()
+			if runReturned {
+				// The timeout covers run only, so a fallback for a run which failed in time is
+				// left to finish, limited by FallbackTimeout alone.
+				return
+			}
+			cancelRun()
+			cmd.returnOnce.Do(func() {
+				cmd.returnTicket()
 				cmd.errorWithFallback(ctx, ErrTimeout)
-				reportAllEvent()
+				cmd.reportAllEvent()
+				close(cmd.errChan)
 			})
 			return
 		}
@@ -214,7 +518,27 @@ func Do(name string, run runFunc, fallback fallbackFunc) error {
 
 // DoC runs your function in a synchronous manner, blocking until either your function succeeds
 // or an error is returned, including hystrix circuit errors
-func DoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC) error {
+//
+// A command configured with NoTimeout and executed with a ctx which cannot be canceled, such as
+// context.Background(), has nothing which could interrupt it. It runs on the caller's goroutine,
+// saving the cost of starting one, with its circuit and metrics working as for any other
+// execution. Do always uses such a ctx.
+//
+// opts change how this execution behaves, like those of GoC.
+func DoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC, opts ...CommandOption) error {
+	name = canonicalName(name)
+	ctx, fallback = newCommandOptions(opts).apply(ctx, fallback)
+
+	// DoC can return as soon as run does, before GoC would have cached the result, so the
+	// result is cached here instead.
+	cache, cacheKey, ctx := requestCacheFor(ctx, name)
+	if cache != nil {
+		if _, ok := cache.get(cacheKey); ok {
+			emitEvent(Event{Name: name, Type: "response-from-cache", Tags: tagsFor(ctx, name)})
+			return nil
+		}
+	}
+
 	done := make(chan struct{}, 1)
 
 	r := func(ctx context.Context) error {
@@ -223,6 +547,9 @@ func DoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC)
 			return err
 		}
 
+		if cache != nil {
+			cache.set(cacheKey, nil)
+		}
 		done <- struct{}{}
 		return nil
 	}
@@ -237,26 +564,215 @@ func DoC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC)
 		return nil
 	}
 
+	// With nothing to interrupt it, the command runs on this goroutine, since it would only be
+	// waited for here anyway.
 	var errChan chan error
 	if fallback == nil {
-		errChan = GoC(ctx, name, r, nil)
+		errChan = goC(ctx, name, r, nil, true, opts...)
 	} else {
-		errChan = GoC(ctx, name, r, f)
+		errChan = goC(ctx, name, r, f, true, opts...)
 	}
 
 	select {
 	case <-done:
 		return nil
 	case err := <-errChan:
+		repanic(err)
 		return err
 	}
 }
 
-func (c *command) reportEvent(eventType string) {
+// Result describes how a command executed by DoWithResult finished.
+type Result struct {
+	// Err is the error Do would have returned, which is nil if run or the fallback succeeded.
+	Err error
+	// FallbackUsed is true when the fallback was run, so a nil Err means the fallback served
+	// the call rather than run.
+	FallbackUsed bool
+	// ShortCircuited is true when run was never called because the circuit was open.
+	ShortCircuited bool
+	// Stale is true when the fallback served the call but returned StaleResult, so the result
+	// should be treated as out of date.
+	Stale bool
+	// RunDuration is how long run took, as recorded in the command's latency percentiles. It is
+	// zero when run did not return in time, such as when the command timed out or was
+	// short-circuited.
+	RunDuration time.Duration
+	// FallbackDuration is how long the fallback took, or how long it was waited for before
+	// FallbackTimeout gave up on it. It is zero when the fallback did not run, and for commands
+	// rejected before reaching their circuit, such as under RequireRegistration.
+	FallbackDuration time.Duration
+}
+
+// DoWithResult runs your function in a synchronous manner like Do, reporting whether the call was
+// served by run or by fallback.
+func DoWithResult(name string, run runFunc, fallback fallbackFunc) Result {
+	runC := func(ctx context.Context) error {
+		return run()
+	}
+	var fallbackC fallbackFuncC
+	if fallback != nil {
+		fallbackC = func(ctx context.Context, err error) error {
+			return fallback(err)
+		}
+	}
+	return DoWithResultC(context.Background(), name, runC, fallbackC)
+}
+
+// DoWithResultC runs your function in a synchronous manner like DoC, reporting whether the call
+// was served by run or by fallback.
+func DoWithResultC(ctx context.Context, name string, run runFuncC, fallback fallbackFuncC) Result {
+	var result Result
+
+	if fallback == nil {
+		fallback = registeredFallback(name)
+	}
+	// A fallback given up on by FallbackTimeout keeps running after DoWithResultC returns, so
+	// it reports through these rather than writing to result.
+	var mutex sync.Mutex
+	var fallbackUsed, shortCircuited, stale bool
+	var f fallbackFuncC
+	if fallback != nil {
+		f = func(ctx context.Context, err error) error {
+			mutex.Lock()
+			fallbackUsed = true
+			shortCircuited = errors.Is(err, ErrCircuitOpen)
+			mutex.Unlock()
+			fallbackErr := fallback(ctx, err)
+			mutex.Lock()
+			stale = errors.Is(fallbackErr, StaleResult)
+			mutex.Unlock()
+			return fallbackErr
+		}
+	}
+
+	// Like DoTypedC, wait for errChan so that a run which succeeds after the command timed out
+	// cannot hide the fallback. errChan is closed only once the fallback has returned, and the
+	// durations are only set once it is closed.
+	var durations commandDurations
+	errChan := goC(ctx, name, run, f, true, withDurations(&durations))
+	result.Err = <-errChan
+	for range errChan {
+	}
+	result.RunDuration, result.FallbackDuration = durations.run, durations.fallback
+	mutex.Lock()
+	result.FallbackUsed, result.ShortCircuited = fallbackUsed, shortCircuited
+	if !errors.Is(result.Err, ErrFallbackTimeout) {
+		// an abandoned fallback's result never served the call
+		result.Stale = stale
+	}
+	mutex.Unlock()
+	repanic(result.Err)
+	if result.Err == ErrCircuitOpen {
+		result.ShortCircuited = true
+	}
+
+	return result
+}
+
+// isFailure reports whether an error returned by run should count against the circuit. A
+// classifier which panics is treated as having reported a failure.
+func isFailure(name string, err error) (failure bool) {
+	if errors.As(err, &businessError{}) {
+		return false
+	}
+
+	settings := getSettings(name)
+	for _, ignored := range settings.IgnoredErrors {
+		if errors.Is(err, ignored) {
+			return false
+		}
+	}
+
+	classify := settings.IsFailure
+	if classify == nil {
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("hystrix-go: recovered from panic in IsFailure: %v", r)
+			failure = true
+		}
+	}()
+
+	return classify(err)
+}
+
+// setTicket records the ticket taken by the run goroutine, which is nil if it did not get one,
+// and wakes up any goroutine waiting in returnTicket.
+func (c *command) setTicket(ticket *struct{}) {
 	c.Lock()
-	defer c.Unlock()
+	c.ticket = ticket
+	c.ticketChecked = true
+	c.ticketCond.Signal()
+	c.Unlock()
+}
 
+// returnTicket gives the command's ticket back to the executor pool. When the caller extracts
+// the error from the returned errChan, it's assumed that the ticket's been returned to the
+// executorPool, so returnTicket must not run after errorWithFallback.
+func (c *command) returnTicket() {
+	c.Lock()
+	// Avoid releasing before a ticket is acquired.
+	for !c.ticketChecked {
+		c.ticketCond.Wait()
+	}
+	c.circuit.executorPool.Return(c.ticket)
+	c.Unlock()
+}
+
+func (c *command) reportAllEvent() {
+	if c.durations != nil {
+		c.durations.run, c.durations.fallback = c.runDuration, c.fallbackDuration
+	}
+	err := c.circuit.reportEvent(c.events, c.start, c.runDuration, c.tags)
+	if err != nil {
+		log.Warnf("%v", err)
+	}
+
+	switch c.events[0] {
+	case "success", "failure":
+		c.circuit.executorPool.observe(c.runDuration, false)
+	case "timeout":
+		c.circuit.executorPool.observe(0, true)
+	}
+
+	if c.span != nil {
+		c.endSpan()
+	}
+}
+
+func (c *command) endSpan() {
+	c.Lock()
+	result := SpanResult{
+		Duration: getClock().Now().Sub(c.start),
+		Err:      c.returnErr,
+	}
+	if len(c.events) > 0 {
+		result.Outcome = c.events[0]
+	}
+	if len(c.events) > 1 {
+		result.Fallback = c.events[1]
+	}
+	c.Unlock()
+
+	c.span.End(result)
+}
+
+// reportEvent records an event for the circuit's metrics, and sends it to any event listeners.
+func (c *command) reportEvent(eventType string, err error) {
+	c.Lock()
 	c.events = append(c.events, eventType)
+	c.Unlock()
+
+	emitEvent(Event{
+		Name:     c.circuit.Name,
+		Type:     eventType,
+		Duration: getClock().Now().Sub(c.start),
+		Err:      err,
+		Tags:     c.tags,
+	})
 }
 
 // errorWithFallback triggers the fallback while reporting the appropriate metric events.
@@ -266,34 +782,221 @@ func (c *command) errorWithFallback(ctx context.Context, err error) {
 		eventType = "short-circuit"
 	} else if err == ErrMaxConcurrency {
 		eventType = "rejected"
+	} else if err == ErrRateLimited {
+		eventType = "rate-limited"
 	} else if err == ErrTimeout {
 		eventType = "timeout"
-	} else if err == context.Canceled {
+	} else if ctx.Err() != nil && err == context.Canceled {
+		// only the caller's context counts, a dependency can return context errors of its own
 		eventType = "context_canceled"
-	} else if err == context.DeadlineExceeded {
+	} else if ctx.Err() != nil && err == context.DeadlineExceeded {
 		eventType = "context_deadline_exceeded"
 	}
 
-	c.reportEvent(eventType)
-	fallbackErr := c.tryFallback(ctx, err)
-	if fallbackErr != nil {
-		c.errChan <- fallbackErr
+	c.reportEvent(eventType, err)
+	fallbackErr := c.tryFallback(ctx, err, ErrorKind(eventType))
+	if fallbackErr == nil && eventType == "failure" && getSettings(c.circuit.Name).CountFallbackSuccessAsSuccess {
+		c.maskFailure()
+	}
+	if p, ok := fallbackErr.(propagatedPanic); ok {
+		c.propagate(p)
+	} else if fallbackErr != nil {
+		c.sendErr(fallbackErr)
 	}
 }
 
-func (c *command) tryFallback(ctx context.Context, err error) error {
+// sendErr delivers err to the caller as the error the command failed with. The command's
+// errChan has room for one error, and it is only sent on inside returnOnce, once per execution.
+// Should a second error ever be sent, such as by a run which returns after the caller took the
+// first and walked away, it is dropped rather than left blocking the goroutine forever, so the
+// caller always sees the first error and then the channel being closed.
+func (c *command) sendErr(err error) {
+	select {
+	case c.errChan <- err:
+		c.returnErr = err
+	default:
+	}
+}
+
+// maskFailure records the run's failure as a success in the circuit's metrics, for commands
+// with CountFallbackSuccessAsSuccess. Listeners have already been sent the failure.
+func (c *command) maskFailure() {
+	c.Lock()
+	defer c.Unlock()
+
+	for i, e := range c.events {
+		if e == "failure" {
+			c.events[i] = "success"
+			return
+		}
+	}
+}
+
+// tryFallback runs the command's fallback for err. It returns nil when the fallback succeeded,
+// which only means the caller was served: the run's failure has already been reported.
+func (c *command) tryFallback(ctx context.Context, err error, kind ErrorKind) error {
 	if c.fallback == nil {
 		// If we don't have a fallback return the original error.
 		return err
 	}
 
-	fallbackErr := c.fallback(ctx, err)
+	ticket, ok := c.circuit.executorPool.acquireFallback()
+	if !ok {
+		c.reportEvent("fallback-rejection", ErrFallbackRejected)
+		// keep the run error, which is the reason the fallback was needed
+		return fmt.Errorf("%w. run error was '%v'", ErrFallbackRejected, err)
+	}
+	cause := &CommandError{Name: c.circuit.Name, Kind: kind, Err: err}
+	var fallbackErr error
+	fallbackStart := getClock().Now()
+	if timeout := getSettings(c.circuit.Name).FallbackTimeout; timeout > 0 {
+		fallbackErr = c.callFallbackWithTimeout(ctx, cause, timeout, ticket)
+	} else {
+		fallbackErr = callFallback(ctx, c.fallback, cause)
+		c.circuit.executorPool.returnFallback(ticket)
+	}
+	c.fallbackDuration = getClock().Now().Sub(fallbackStart)
+	if isPropagatedPanic(fallbackErr) {
+		return fallbackErr
+	}
+	if errors.Is(fallbackErr, StaleResult) {
+		c.reportEvent("fallback-stale", nil)
+		return nil
+	}
+	if fallbackErr == ErrFallbackTimeout {
+		log.Warnf("hystrix-go: fallback for %v timed out", c.circuit.Name)
+		c.reportEvent("fallback-failure", ErrFallbackTimeout)
+		return fmt.Errorf("%w. run error was '%v'", ErrFallbackTimeout, err)
+	}
 	if fallbackErr != nil {
-		c.reportEvent("fallback-failure")
+		log.Warnf("hystrix-go: fallback for %v failed: %v", c.circuit.Name, fallbackErr)
+		c.reportEvent("fallback-failure", fallbackErr)
 		return fmt.Errorf("fallback failed with '%v'. run error was '%v'", fallbackErr, err)
 	}
 
-	c.reportEvent("fallback-success")
+	c.reportEvent("fallback-success", nil)
 
 	return nil
 }
+
+// rejectCommand finishes a command which is not allowed to run, by running its fallback with
+// reason. The command has no circuit, so nothing is recorded in metrics.
+func rejectCommand(ctx context.Context, name string, fallback fallbackFuncC, reason error, errChan chan error, inline bool) {
+	err := reason
+	if fallback != nil {
+		err = nil
+		cause := &CommandError{Name: name, Kind: KindRejected, Err: reason}
+		fallbackErr := callFallback(ctx, fallback, cause)
+		if isPropagatedPanic(fallbackErr) {
+			if !inline {
+				repanic(fallbackErr)
+			}
+			err = fallbackErr
+		} else if fallbackErr != nil && !errors.Is(fallbackErr, StaleResult) {
+			err = fmt.Errorf("fallback failed with '%v'. run error was '%v'", fallbackErr, reason)
+		}
+	}
+
+	if err != nil {
+		errChan <- err
+	}
+	close(errChan)
+}
+
+// callFallbackWithTimeout runs the command's fallback, giving up on it with ErrFallbackTimeout
+// once timeout has passed. The fallback's context is canceled when it is given up on, and its
+// ticket is only returned once it has actually finished.
+func (c *command) callFallbackWithTimeout(ctx context.Context, err error, timeout time.Duration, ticket *struct{}) error {
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fallbackCtx := withDeadline(cancelCtx, getClock().Now().Add(timeout))
+
+	result := make(chan error, 1)
+	go func() {
+		result <- callFallback(fallbackCtx, c.fallback, err)
+		c.circuit.executorPool.returnFallback(ticket)
+	}()
+
+	timer := getClock().NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case fallbackErr := <-result:
+		return fallbackErr
+	case <-timer.C():
+		return ErrFallbackTimeout
+	}
+}
+
+// deadlineContext reports a deadline which something else enforces, such as the timer of
+// callFallbackWithTimeout, which follows the Clock rather than the wall clock.
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+// withDeadline returns ctx reporting deadline as its Deadline, unless ctx has an earlier one.
+func withDeadline(ctx context.Context, deadline time.Time) context.Context {
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return ctx
+	}
+	return deadlineContext{Context: ctx, deadline: deadline}
+}
+
+func (c deadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// chainFallbacks combines fallbacks into one which tries each in turn until one succeeds.
+// It returns nil when there are no fallbacks.
+func chainFallbacks(fallbacks []fallbackFuncC) fallbackFuncC {
+	switch len(fallbacks) {
+	case 0:
+		return nil
+	case 1:
+		return fallbacks[0]
+	}
+
+	return func(ctx context.Context, err error) error {
+		for _, fallback := range fallbacks {
+			// a panicking fallback should not stop the rest of the chain from being tried
+			err = callFallback(ctx, fallback, err)
+			if err == nil || errors.Is(err, StaleResult) || isPropagatedPanic(err) {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+// callRun runs the given function, turning a panic into a PanicError, or one to propagate under
+// Propagate.
+func callRun(ctx context.Context, run runFuncC) (err error) {
+	defer func() {
+		if r := recover(); r != nil && getPanicHandling() == Propagate {
+			err = propagatedPanic{value: r}
+			log.Errorf("hystrix-go: propagating panic in run: %v", r)
+		} else if r != nil {
+			err = PanicError{Value: r, Stack: debug.Stack()}
+			log.Errorf("hystrix-go: recovered from panic in run: %v", r)
+		}
+	}()
+
+	return run(ctx)
+}
+
+// callFallback runs the given fallback, turning a panic into a PanicError, or one to propagate
+// under Propagate.
+func callFallback(ctx context.Context, fallback fallbackFuncC, runErr error) (err error) {
+	defer func() {
+		if r := recover(); r != nil && getPanicHandling() == Propagate {
+			err = propagatedPanic{value: r}
+			log.Errorf("hystrix-go: propagating panic in fallback: %v", r)
+		} else if r != nil {
+			err = PanicError{Value: r, Stack: debug.Stack()}
+			log.Errorf("hystrix-go: recovered from panic in fallback: %v", r)
+		}
+	}()
+
+	return fallback(ctx, runErr)
+}
@@ -2,7 +2,12 @@ package hystrix
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,6 +41,41 @@ func TestSuccess(t *testing.T) {
 	})
 }
 
+func TestErrChanClosed(t *testing.T) {
+	Convey("with a command which succeeds", t, func() {
+		defer Flush()
+
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			return nil
+		}, nil)
+
+		Convey("the error channel is closed without delivering an error", func() {
+			var errs []error
+			for err := range errChan {
+				errs = append(errs, err)
+			}
+			So(errs, ShouldBeEmpty)
+		})
+	})
+
+	Convey("with a command which fails", t, func() {
+		defer Flush()
+
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			return fmt.Errorf("run_error")
+		}, nil)
+
+		Convey("exactly one error is delivered before the channel is closed", func() {
+			var errs []error
+			for err := range errChan {
+				errs = append(errs, err)
+			}
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Error(), ShouldEqual, "run_error")
+		})
+	})
+}
+
 func TestFallback(t *testing.T) {
 	Convey("with a command which fails, and whose fallback sends to a channel", t, func() {
 		defer Flush()
@@ -78,7 +118,7 @@ func TestTimeout(t *testing.T) {
 			resultChan <- 1
 			return nil
 		}, func(ctx context.Context, err error) error {
-			if err == ErrTimeout {
+			if errors.Is(err, ErrTimeout) {
 				resultChan <- 2
 			}
 			return nil
@@ -93,6 +133,210 @@ func TestTimeout(t *testing.T) {
 	})
 }
 
+func TestNilFallback(t *testing.T) {
+	Convey("with commands which have no fallback", t, func() {
+		defer Flush()
+
+		Convey("a short-circuited command returns ErrCircuitOpen", func() {
+			ForceOpen("open")
+			So(<-Go("open", func() error { return nil }, nil), ShouldResemble, ErrCircuitOpen)
+			So(Do("open", func() error { return nil }, nil), ShouldResemble, ErrCircuitOpen)
+		})
+
+		Convey("a rejected command returns ErrMaxConcurrency", func() {
+			ConfigureCommand("full", CommandConfig{MaxConcurrentRequests: 1})
+			release := make(chan struct{})
+			defer close(release)
+			running := make(chan struct{})
+			Go("full", func() error {
+				close(running)
+				<-release
+				return nil
+			}, nil)
+			<-running
+
+			So(<-Go("full", func() error { return nil }, nil), ShouldResemble, ErrMaxConcurrency)
+			So(Do("full", func() error { return nil }, nil), ShouldResemble, ErrMaxConcurrency)
+		})
+
+		Convey("a command which times out returns ErrTimeout", func() {
+			ConfigureCommand("slow", CommandConfig{Timeout: 10})
+			block := func() error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			}
+
+			So(<-Go("slow", block, nil), ShouldResemble, ErrTimeout)
+			So(Do("slow", block, nil), ShouldResemble, ErrTimeout)
+		})
+
+		Convey("a failed command returns the run error", func() {
+			So(Do("failing", func() error { return fmt.Errorf("run_error") }, nil).Error(), ShouldEqual, "run_error")
+		})
+	})
+}
+
+func TestFailedLookup(t *testing.T) {
+	Convey("with a command whose lookup fails", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		RequireRegistration = true
+		defer func() { RequireRegistration = false }()
+
+		errChan := Go("unknown", func() error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}, nil)
+
+		Convey("exactly the lookup error is delivered, and never a later timeout", func() {
+			So(<-errChan, ShouldResemble, ErrUnknownCommand)
+			clock.Advance(time.Duration(DefaultTimeout) * time.Millisecond)
+
+			_, open := <-errChan
+			So(open, ShouldBeFalse)
+			So(waitForCommands(time.Second), ShouldBeTrue)
+		})
+	})
+}
+
+func TestTimeoutThenRunError(t *testing.T) {
+	Convey("with a command whose run fails just after it timed out", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		// the circuit must not open part way through the timeouts
+		ConfigureCommand("", CommandConfig{Timeout: 10, RequestVolumeThreshold: 1000})
+
+		Convey("exactly one error is delivered every time, and no goroutine is left blocked", func() {
+			for i := 0; i < 100; i++ {
+				release := make(chan struct{})
+				errChan := Go("", func() error {
+					<-release
+					return fmt.Errorf("run_error")
+				}, func(err error) error {
+					return fmt.Errorf("fallback_error")
+				})
+
+				clock.waitForTimers(1)
+				clock.Advance(10 * time.Millisecond)
+				So((<-errChan).Error(), ShouldEqual, "fallback failed with 'fallback_error'. run error was 'hystrix: timeout'")
+				close(release)
+
+				_, open := <-errChan
+				So(open, ShouldBeFalse)
+				So(waitForCommands(time.Second), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("with many commands racing their timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 1, MaxConcurrentRequests: 500, RequestVolumeThreshold: 1000})
+
+		errChans := make([]chan error, 500)
+		for i := range errChans {
+			errChans[i] = Go("", func() error {
+				time.Sleep(time.Millisecond)
+				return fmt.Errorf("run_error")
+			}, nil)
+		}
+
+		Convey("each delivers exactly one error", func() {
+			for _, errChan := range errChans {
+				count := 0
+				for range errChan {
+					count++
+				}
+				So(count, ShouldEqual, 1)
+			}
+			So(waitForCommands(time.Second), ShouldBeTrue)
+		})
+	})
+}
+
+// waitForCommands reports whether every command goroutine finishes within timeout.
+func waitForCommands(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		lifecycleMutex.Lock()
+		running := inFlight
+		lifecycleMutex.Unlock()
+
+		if running == 0 {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func TestTimeoutErrorIs(t *testing.T) {
+	Convey("with a command which times out", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10})
+
+		fallbackErr := make(chan error, 1)
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}, func(ctx context.Context, err error) error {
+			fallbackErr <- err
+			return err
+		})
+
+		Convey("the fallback can match the error with errors.Is", func() {
+			So(errors.Is(<-fallbackErr, ErrTimeout), ShouldBeTrue)
+		})
+		Convey("the returned error still reads as a timeout", func() {
+			So((<-errChan).Error(), ShouldContainSubstring, "hystrix: timeout")
+		})
+	})
+}
+
+func TestCommandError(t *testing.T) {
+	Convey("the fallback of a command receives a CommandError", t, func() {
+		defer Flush()
+
+		// fallbackCause runs the command and returns the CommandError its fallback received.
+		fallbackCause := func(name string, run func() error) *CommandError {
+			var cause *CommandError
+			Do(name, run, func(err error) error {
+				errors.As(err, &cause)
+				return nil
+			})
+			return cause
+		}
+
+		Convey("saying which command timed out", func() {
+			ConfigureCommand("slow", CommandConfig{Timeout: 10})
+			cause := fallbackCause("slow", func() error {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			})
+			So(cause, ShouldResemble, &CommandError{Name: "slow", Kind: KindTimeout, Err: ErrTimeout})
+			So(errors.Is(cause, ErrTimeout), ShouldBeTrue)
+		})
+
+		Convey("wrapping the error a failed run returned", func() {
+			runErr := errors.New("connection refused")
+			cause := fallbackCause("broken", func() error { return runErr })
+			So(cause.Kind, ShouldEqual, KindFailure)
+			So(cause.Err, ShouldEqual, runErr)
+			So(cause.Error(), ShouldEqual, "connection refused")
+		})
+
+		Convey("for a command whose circuit is open", func() {
+			ForceOpen("open")
+			cause := fallbackCause("open", func() error { return nil })
+			So(cause.Kind, ShouldEqual, KindShortCircuit)
+			So(errors.Is(cause, ErrCircuitOpen), ShouldBeTrue)
+		})
+	})
+}
+
 func TestTimeoutEmptyFallback(t *testing.T) {
 	Convey("with a command which times out, and has no fallback", t, func() {
 		defer Flush()
@@ -149,51 +393,663 @@ func TestMaxConcurrent(t *testing.T) {
 				}
 			}
 
-			Convey("one will return a 'max concurrency' error", func() {
-				So(bad, ShouldEqual, 1)
-				So(good, ShouldEqual, 2)
+			Convey("one will return a 'max concurrency' error", func() {
+				So(bad, ShouldEqual, 1)
+				So(good, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestForceOpenCircuit(t *testing.T) {
+	Convey("when a command with a forced open circuit is run", t, func() {
+		defer Flush()
+
+		cb, _, err := GetCircuit("")
+		So(err, ShouldEqual, nil)
+
+		cb.toggleForceOpen(true)
+
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			return nil
+		}, nil)
+
+		Convey("a 'circuit open' error is returned", func() {
+			So(<-errChan, ShouldResemble, ErrCircuitOpen)
+
+			Convey("metrics are recorded", func() {
+				time.Sleep(10 * time.Millisecond)
+				cb, _, _ := GetCircuit("")
+				So(cb.metrics.DefaultCollector().Successes().Sum(time.Now()), ShouldEqual, 0)
+				So(cb.metrics.DefaultCollector().ShortCircuits().Sum(time.Now()), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestForceOpen(t *testing.T) {
+	Convey("when a command's circuit is forced open", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{SleepWindow: 10})
+
+		ForceOpen("")
+
+		Convey("executions go to the fallback, even after the sleep window", func() {
+			cb, _, _ := GetCircuit("")
+			cb.setOpen()
+			time.Sleep(20 * time.Millisecond)
+
+			var fallbackErr error
+			err := Do("", func() error {
+				return nil
+			}, func(err error) error {
+				fallbackErr = err
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(fallbackErr, ShouldResemble, &CommandError{Kind: KindShortCircuit, Err: ErrCircuitOpen})
+		})
+
+		Convey("and then cleared, executions run again", func() {
+			ClearForced("")
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+		})
+	})
+}
+
+func TestForceClose(t *testing.T) {
+	Convey("when a command's circuit is forced closed", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 1})
+
+		ForceClose("")
+
+		Convey("executions are attempted even when it is unhealthy", func() {
+			for i := 0; i < 10; i++ {
+				Do("", func() error {
+					return fmt.Errorf("failure")
+				}, nil)
+			}
+			time.Sleep(10 * time.Millisecond)
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.IsHealthy(time.Now()), ShouldBeFalse)
+
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+			So(IsOpen(""), ShouldBeFalse)
+
+			Convey("and once cleared, the circuit opens", func() {
+				ClearForced("")
+				So(IsOpen(""), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestNilFallbackRunError(t *testing.T) {
+	Convey("when your run function returns an error and you have no fallback", t, func() {
+		defer Flush()
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			return fmt.Errorf("run_error")
+		}, nil)
+
+		Convey("the returned error should be the run error", func() {
+			err := <-errChan
+
+			So(err.Error(), ShouldEqual, "run_error")
+		})
+	})
+}
+
+func TestPanic(t *testing.T) {
+	Convey("when your run function panics", t, func() {
+		defer Flush()
+
+		Convey("the fallback is invoked with the recovered panic", func() {
+			fallbackErr := make(chan error, 1)
+			errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+				panic("boom")
+			}, func(ctx context.Context, err error) error {
+				fallbackErr <- err
+				return nil
+			})
+
+			err := <-fallbackErr
+			var panicErr PanicError
+			So(errors.As(err, &panicErr), ShouldBeTrue)
+			So(panicErr.Value, ShouldEqual, "boom")
+			So(len(panicErr.Stack), ShouldBeGreaterThan, 0)
+			So(<-errChan, ShouldBeNil)
+
+			Convey("and it is recorded as a failure", func() {
+				time.Sleep(10 * time.Millisecond)
+				cb, _, _ := GetCircuit("")
+				So(cb.metrics.DefaultCollector().Failures().Sum(time.Now()), ShouldEqual, 1)
+				So(cb.metrics.DefaultCollector().FallbackSuccesses().Sum(time.Now()), ShouldEqual, 1)
+			})
+		})
+
+		Convey("without a fallback the panic is returned as an error", func() {
+			err := <-GoC(context.Background(), "", func(ctx context.Context) error {
+				panic("boom")
+			}, nil)
+
+			So(err.Error(), ShouldEqual, "hystrix: recovered from panic: boom")
+		})
+	})
+
+	Convey("when your fallback function panics", t, func() {
+		defer Flush()
+
+		err := <-GoC(context.Background(), "", func(ctx context.Context) error {
+			return fmt.Errorf("run_error")
+		}, func(ctx context.Context, err error) error {
+			panic("boom")
+		})
+
+		Convey("the panic is returned as the fallback error", func() {
+			So(err.Error(), ShouldEqual, "fallback failed with 'hystrix: recovered from panic: boom'. run error was 'run_error'")
+		})
+	})
+}
+
+func TestIsFailure(t *testing.T) {
+	Convey("with a command which does not count not-found errors as failures", t, func() {
+		defer Flush()
+
+		errNotFound := fmt.Errorf("not found")
+		ConfigureCommand("", CommandConfig{IsFailure: func(err error) bool {
+			return err != errNotFound
+		}})
+
+		Convey("a not-found error is returned without running the fallback", func() {
+			fallbackRan := false
+			err := Do("", func() error {
+				return errNotFound
+			}, func(err error) error {
+				fallbackRan = true
+				return nil
+			})
+
+			So(err, ShouldEqual, errNotFound)
+			So(fallbackRan, ShouldBeFalse)
+
+			Convey("and is recorded as a success", func() {
+				time.Sleep(10 * time.Millisecond)
+				cb, _, _ := GetCircuit("")
+				So(cb.metrics.DefaultCollector().Successes().Sum(time.Now()), ShouldEqual, 1)
+				So(cb.metrics.DefaultCollector().Failures().Sum(time.Now()), ShouldEqual, 0)
+			})
+		})
+
+		Convey("other errors still run the fallback", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("boom")
+			}, func(err error) error {
+				return nil
+			})
+
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestIgnoredErrors(t *testing.T) {
+	Convey("with a command which ignores io.EOF", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{IgnoredErrors: []error{io.EOF}})
+
+		Convey("a wrapped io.EOF is returned without running the fallback", func() {
+			fallbackRan := false
+			wrapped := fmt.Errorf("reading: %w", io.EOF)
+			errChan := Go("", func() error {
+				return wrapped
+			}, func(err error) error {
+				fallbackRan = true
+				return nil
+			})
+
+			So(<-errChan, ShouldEqual, wrapped)
+			_, open := <-errChan
+			So(open, ShouldBeFalse)
+			So(fallbackRan, ShouldBeFalse)
+
+			Convey("and is recorded as a success", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").Successes, ShouldEqual, 1)
+				So(GetMetrics("").Failures, ShouldEqual, 0)
+			})
+		})
+
+		Convey("other errors still run the fallback", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("boom")
+			}, func(err error) error {
+				return nil
+			})
+
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestBusinessError(t *testing.T) {
+	Convey("with a command whose run returns a business error", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RetryAttempts: 2})
+
+		errNotFound := fmt.Errorf("user not found")
+		runs := 0
+		fallbackRan := false
+		err := Do("", func() error {
+			runs++
+			return BusinessError(errNotFound)
+		}, func(err error) error {
+			fallbackRan = true
+			return nil
+		})
+
+		Convey("the inner error is returned without running the fallback or retrying", func() {
+			So(err, ShouldEqual, errNotFound)
+			So(fallbackRan, ShouldBeFalse)
+			So(runs, ShouldEqual, 1)
+		})
+
+		Convey("the execution is recorded as a success", func() {
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Successes, ShouldEqual, 1)
+			So(GetMetrics("").Failures, ShouldEqual, 0)
+		})
+	})
+
+	Convey("a nil business error is nil", t, func() {
+		So(BusinessError(nil), ShouldBeNil)
+	})
+}
+
+func TestIsFailurePanic(t *testing.T) {
+	Convey("with a command whose failure classifier panics", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{IsFailure: func(err error) bool {
+			panic("bad classifier")
+		}})
+
+		Convey("the error counts as a failure and runs the fallback", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("boom")
+			}, func(err error) error {
+				return nil
+			})
+
+			So(err, ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestMaxQueueWait(t *testing.T) {
+	Convey("with a command whose only executor is busy", t, func() {
+		defer Flush()
+
+		// hold takes the command's executor, returning a function which gives it back.
+		hold := func() func() {
+			cb, _, _ := GetCircuit("")
+			ticket := <-cb.executorPool.Tickets
+			return func() {
+				cb.executorPool.Return(ticket)
+			}
+		}
+
+		Convey("a queued command runs once the executor is returned", func() {
+			ConfigureCommand("", CommandConfig{MaxConcurrentRequests: 1, MaxQueueWait: 1000})
+			release := hold()
+
+			errChan := Go("", func() error {
+				return nil
+			}, nil)
+			time.Sleep(20 * time.Millisecond)
+			release()
+
+			So(<-errChan, ShouldBeNil)
+		})
+
+		Convey("a queued command is rejected once it has waited too long", func() {
+			ConfigureCommand("", CommandConfig{MaxConcurrentRequests: 1, MaxQueueWait: 20})
+			release := hold()
+			defer release()
+
+			start := time.Now()
+			err := <-Go("", func() error {
+				return nil
+			}, nil)
+			So(err, ShouldResemble, ErrMaxConcurrency)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+		})
+
+		Convey("a queued command still times out", func() {
+			ConfigureCommand("", CommandConfig{MaxConcurrentRequests: 1, MaxQueueWait: 1000, Timeout: 20})
+			release := hold()
+			defer release()
+
+			err := <-Go("", func() error {
+				return nil
+			}, nil)
+			So(err, ShouldResemble, ErrTimeout)
+		})
+
+		Convey("a queued command gives up when its context's deadline passes", func() {
+			ConfigureCommand("", CommandConfig{MaxConcurrentRequests: 1, MaxQueueWait: 1000})
+			release := hold()
+			defer release()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			var fallbackErr error
+			err := <-GoC(ctx, "", func(ctx context.Context) error {
+				return nil
+			}, func(ctx context.Context, err error) error {
+				fallbackErr = err
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(fallbackErr, ShouldResemble, &CommandError{Kind: KindContextDeadlineExceeded, Err: context.DeadlineExceeded})
+
+			time.Sleep(10 * time.Millisecond)
+			metrics := GetMetrics("")
+			So(metrics.ContextDeadlineExceeded, ShouldEqual, 1)
+			So(metrics.Rejects, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestTimeoutWatcherStops(t *testing.T) {
+	Convey("with fast commands which have a long timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10000, MaxConcurrentRequests: 100})
+
+		for i := 0; i < 100; i++ {
+			So(<-Go("", func() error { return nil }, nil), ShouldBeNil)
+		}
+
+		Convey("their timeout goroutines exit as soon as they finish", func() {
+			So(waitForCommands(time.Second), ShouldBeTrue)
+		})
+	})
+}
+
+func TestNoTimeout(t *testing.T) {
+	Convey("with a command configured without a timeout", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{Timeout: NoTimeout})
+
+		Convey("it can run for as long as it needs", func() {
+			release := make(chan struct{})
+			errChan := Go("", func() error {
+				<-release
+				return nil
+			}, nil)
+
+			clock.Advance(time.Hour)
+			close(release)
+			So(<-errChan, ShouldBeNil)
+		})
+
+		Convey("it still stops when its context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := GoC(ctx, "", func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}, nil)
+
+			cancel()
+			So(<-errChan, ShouldEqual, context.Canceled)
+		})
+
+		Convey("it is still limited by its circuit", func() {
+			ForceOpen("")
+			So(<-Go("", func() error { return nil }, nil), ShouldResemble, ErrCircuitOpen)
+		})
+	})
+}
+
+func TestDoInline(t *testing.T) {
+	Convey("with a command configured without a timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("inline", CommandConfig{Timeout: NoTimeout, RequestVolumeThreshold: 2})
+
+		// the stack only reaches back to DoC when run is called on the caller's goroutine
+		onCaller := func() bool {
+			return strings.Contains(string(debug.Stack()), "hystrix.DoC(")
+		}
+
+		Convey("Do runs it on the caller's goroutine", func() {
+			inline := false
+			err := Do("inline", func() error {
+				inline = onCaller()
+				return nil
+			}, nil)
+
+			So(err, ShouldBeNil)
+			So(inline, ShouldBeTrue)
+		})
+
+		Convey("Do still runs it on a goroutine of its own when ctx can be canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			inline := true
+			DoC(ctx, "inline", func(ctx context.Context) error {
+				inline = onCaller()
+				return nil
+			}, nil)
+
+			So(inline, ShouldBeFalse)
+		})
+
+		Convey("its failures are recorded and open the circuit", func() {
+			fail := func() error { return fmt.Errorf("failure") }
+			So(Do("inline", fail, nil).Error(), ShouldEqual, "failure")
+			So(Do("inline", fail, nil).Error(), ShouldEqual, "failure")
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetMetrics("inline").Failures, ShouldEqual, 2)
+			So(Do("inline", func() error { return nil }, nil), ShouldEqual, ErrCircuitOpen)
+		})
+	})
+}
+
+func TestFallbackTimeout(t *testing.T) {
+	Convey("with a command whose fallback may take 10ms", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{FallbackTimeout: 10, FallbackMaxConcurrent: 1})
+
+		Convey("a fallback which hangs is given up on", func() {
+			stopped := make(chan error, 1)
+			err := Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				time.Sleep(50 * time.Millisecond)
+				stopped <- nil
+				return nil
+			})
+			So(errors.Is(err, ErrFallbackTimeout), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "hystrix: fallback timeout. run error was 'run_error'")
+
+			Convey("and keeps its fallback slot until it finishes", func() {
+				err := Do("", func() error {
+					return fmt.Errorf("second")
+				}, func(err error) error {
+					return nil
+				})
+				So(errors.Is(err, ErrFallbackRejected), ShouldBeTrue)
+				<-stopped
+
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").FallbackFailures, ShouldEqual, 1)
+			})
+		})
+
+		Convey("the fallback's context is canceled once it is given up on", func() {
+			canceled := make(chan error, 1)
+			errC := DoC(context.Background(), "", func(ctx context.Context) error {
+				return fmt.Errorf("run_error")
+			}, func(ctx context.Context, err error) error {
+				<-ctx.Done()
+				canceled <- ctx.Err()
+				return ctx.Err()
+			})
+			So(errors.Is(errC, ErrFallbackTimeout), ShouldBeTrue)
+			So(<-canceled, ShouldEqual, context.Canceled)
+		})
+
+		Convey("a quick fallback is unaffected", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return nil
+			})
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestTimeoutAndFallback(t *testing.T) {
+	Convey("with a command which times out after 20ms", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 20})
+
+		Convey("a run which times out goes to the fallback, which can succeed", func() {
+			var fallbackErr error
+			err := Do("", func() error {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			}, func(err error) error {
+				fallbackErr = err
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(errors.Is(fallbackErr, ErrTimeout), ShouldBeTrue)
+		})
+
+		Convey("a run which fails in time keeps its fallback's result, however long it takes", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			})
+			So(err, ShouldBeNil)
+
+			Convey("and is not recorded as a timeout", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").Failures, ShouldEqual, 1)
+				So(GetMetrics("").Timeouts, ShouldEqual, 0)
+				So(GetMetrics("").FallbackSuccesses, ShouldEqual, 1)
+			})
+		})
+
+		Convey("a fallback which hangs after a run which fails in time ends with FallbackTimeout", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 20, FallbackTimeout: 50})
+			err := Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				time.Sleep(200 * time.Millisecond)
+				return nil
+			})
+			So(errors.Is(err, ErrFallbackTimeout), ShouldBeTrue)
+			So(errors.Is(err, ErrTimeout), ShouldBeFalse)
+		})
+
+		Convey("the fallback of a run which timed out gets the caller's context", func() {
+			type key struct{}
+			ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "trace"), time.Second)
+			defer cancel()
+			callerDeadline, _ := ctx.Deadline()
+
+			var value interface{}
+			var fallbackErr error
+			var deadline time.Time
+			err := DoC(ctx, "", func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}, func(ctx context.Context, err error) error {
+				value = ctx.Value(key{})
+				fallbackErr = ctx.Err()
+				deadline, _ = ctx.Deadline()
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "trace")
+			So(fallbackErr, ShouldBeNil)
+			So(deadline, ShouldEqual, callerDeadline)
+
+			Convey("whose deadline reflects a shorter FallbackTimeout", func() {
+				ConfigureCommand("", CommandConfig{Timeout: 20, FallbackTimeout: 50})
+				start := time.Now()
+				DoC(ctx, "", func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}, func(ctx context.Context, err error) error {
+					deadline, _ = ctx.Deadline()
+					return nil
+				})
+				So(deadline, ShouldHappenBefore, callerDeadline)
+				So(deadline, ShouldHappenAfter, start)
 			})
 		})
 	})
 }
 
-func TestForceOpenCircuit(t *testing.T) {
-	Convey("when a command with a forced open circuit is run", t, func() {
+func TestFallbackMaxConcurrent(t *testing.T) {
+	Convey("with a command limited to 1 concurrent fallback", t, func() {
 		defer Flush()
+		ConfigureCommand("", CommandConfig{FallbackMaxConcurrent: 1})
 
-		cb, _, err := GetCircuit("")
-		So(err, ShouldEqual, nil)
-
-		cb.toggleForceOpen(true)
-
-		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+		release := make(chan struct{})
+		var releaseOnce sync.Once
+		releaseFirst := func() {
+			releaseOnce.Do(func() { close(release) })
+		}
+		// release the first fallback even when an assertion fails, so its goroutine cannot leak
+		defer releaseFirst()
+		started := make(chan struct{})
+		first := Go("", func() error {
+			return fmt.Errorf("first")
+		}, func(err error) error {
+			close(started)
+			<-release
 			return nil
-		}, nil)
-
-		Convey("a 'circuit open' error is returned", func() {
-			So(<-errChan, ShouldResemble, ErrCircuitOpen)
+		})
+		<-started
 
-			Convey("metrics are recorded", func() {
-				time.Sleep(10 * time.Millisecond)
-				cb, _, _ := GetCircuit("")
-				So(cb.metrics.DefaultCollector().Successes().Sum(time.Now()), ShouldEqual, 0)
-				So(cb.metrics.DefaultCollector().ShortCircuits().Sum(time.Now()), ShouldEqual, 1)
+		Convey("a second fallback is rejected while the first runs", func() {
+			err := Do("", func() error {
+				return fmt.Errorf("second")
+			}, func(err error) error {
+				return nil
 			})
-		})
-	})
-}
+			So(errors.Is(err, ErrFallbackRejected), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "hystrix: fallback rejected. run error was 'second'")
 
-func TestNilFallbackRunError(t *testing.T) {
-	Convey("when your run function returns an error and you have no fallback", t, func() {
-		defer Flush()
-		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
-			return fmt.Errorf("run_error")
-		}, nil)
+			releaseFirst()
+			So(<-first, ShouldBeNil)
 
-		Convey("the returned error should be the run error", func() {
-			err := <-errChan
+			Convey("and is recorded as a rejection", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").FallbackRejections, ShouldEqual, 1)
+			})
 
-			So(err.Error(), ShouldEqual, "run_error")
+			Convey("once the first finishes, fallbacks run again", func() {
+				err := Do("", func() error {
+					return fmt.Errorf("third")
+				}, func(err error) error {
+					return nil
+				})
+				So(err, ShouldBeNil)
+			})
 		})
 	})
 }
@@ -215,6 +1071,59 @@ func TestFailedFallback(t *testing.T) {
 	})
 }
 
+func TestGoMulti(t *testing.T) {
+	Convey("with a command which fails and has a chain of fallbacks", t, func() {
+		defer Flush()
+
+		Convey("each fallback is passed the error of the one before it until one succeeds", func() {
+			var seen []string
+			errChan := GoMulti("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				seen = append(seen, err.Error())
+				return fmt.Errorf("replica_error")
+			}, func(err error) error {
+				seen = append(seen, err.Error())
+				return nil
+			}, func(err error) error {
+				seen = append(seen, err.Error())
+				return nil
+			})
+
+			So(<-errChan, ShouldBeNil)
+			So(seen, ShouldResemble, []string{"run_error", "replica_error"})
+		})
+
+		Convey("a panicking fallback moves on to the next one", func() {
+			errChan := GoMulti("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				panic("replica down")
+			}, func(err error) error {
+				if _, ok := err.(PanicError); !ok {
+					return fmt.Errorf("unexpected error %v", err)
+				}
+				return nil
+			})
+
+			So(<-errChan, ShouldBeNil)
+		})
+
+		Convey("when every fallback fails, the last error is returned with the run error", func() {
+			errChan := GoMulti("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return fmt.Errorf("replica_error")
+			}, func(err error) error {
+				return fmt.Errorf("default_error")
+			})
+
+			err := <-errChan
+			So(err.Error(), ShouldEqual, "fallback failed with 'default_error'. run error was 'run_error'")
+		})
+	})
+}
+
 func TestCloseCircuitAfterSuccess(t *testing.T) {
 	Convey("when a circuit is open", t, func() {
 		defer Flush()
@@ -389,6 +1298,31 @@ func TestReturnTicket(t *testing.T) {
 	})
 }
 
+func TestTimeoutFreesExecutor(t *testing.T) {
+	Convey("with a command which has one executor, and whose run never returns", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10, MaxConcurrentRequests: 1})
+
+		stuck := make(chan struct{})
+		defer close(stuck)
+		fallbackStarted := make(chan struct{})
+		errChan := Go("", func() error {
+			<-stuck
+			return nil
+		}, func(err error) error {
+			close(fallbackStarted)
+			<-stuck
+			return nil
+		})
+
+		Convey("the next call gets the executor once the first times out, even while its fallback hangs", func() {
+			<-fallbackStarted
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+			So(len(errChan), ShouldEqual, 0)
+		})
+	})
+}
+
 func TestContextHandling(t *testing.T) {
 	Convey("with a run command which times out", t, func() {
 		defer Flush()
@@ -492,6 +1426,236 @@ func TestContextHandling(t *testing.T) {
 	})
 }
 
+func TestCallerCancellationHealth(t *testing.T) {
+	Convey("with a command which failed once and was canceled by its caller twice", t, func() {
+		defer Flush()
+
+		errs := []error{<-GoC(context.Background(), "", func(ctx context.Context) error {
+			return fmt.Errorf("run_error")
+		}, nil)}
+		for i := 0; i < 2; i++ {
+			testCtx, cancel := context.WithCancel(context.Background())
+			cancel()
+			errs = append(errs, <-GoC(testCtx, "", func(ctx context.Context) error {
+				return ctx.Err()
+			}, nil))
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("the cancellations are recorded but left out of the circuit's health", func() {
+			So(errs[1], ShouldEqual, context.Canceled)
+			So(errs[2], ShouldEqual, context.Canceled)
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.DefaultCollector().ContextCanceled().Sum(time.Now()), ShouldEqual, 2)
+			So(GetHealth(""), ShouldResemble, HealthCounts{Total: 1, Errors: 1, ErrorPercentage: 100})
+		})
+	})
+
+	Convey("with a command whose run returns context.Canceled by itself", t, func() {
+		defer Flush()
+
+		err := <-GoC(context.Background(), "", func(ctx context.Context) error {
+			return context.Canceled
+		}, nil)
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("it counts as a failure", func() {
+			So(err, ShouldEqual, context.Canceled)
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.DefaultCollector().Failures().Sum(time.Now()), ShouldEqual, 1)
+			So(cb.metrics.DefaultCollector().ContextCanceled().Sum(time.Now()), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestRunContextCanceledOnTimeout(t *testing.T) {
+	Convey("with a run command which waits on its context", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 10})
+
+		stopped := make(chan error, 1)
+		errChan := GoC(context.Background(), "", func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				stopped <- ctx.Err()
+			case <-time.After(time.Second):
+				stopped <- nil
+			}
+			return nil
+		}, nil)
+
+		Convey("the context is canceled once the command times out", func() {
+			So(<-errChan, ShouldResemble, ErrTimeout)
+			So(<-stopped, ShouldEqual, context.Canceled)
+		})
+	})
+}
+
+// withoutDurations returns r with its durations cleared, since they vary from run to run.
+func withoutDurations(r Result) Result {
+	r.RunDuration, r.FallbackDuration = 0, 0
+	return r
+}
+
+func TestDoWithResult(t *testing.T) {
+	Convey("with a command which succeeds", t, func() {
+		defer Flush()
+
+		result := DoWithResult("", func() error {
+			return nil
+		}, func(err error) error {
+			return nil
+		})
+
+		Convey("the fallback is not used", func() {
+			So(withoutDurations(result), ShouldResemble, Result{})
+		})
+	})
+
+	Convey("with a command which fails, and whose fallback succeeds", t, func() {
+		defer Flush()
+
+		result := DoWithResult("", func() error {
+			return fmt.Errorf("run_error")
+		}, func(err error) error {
+			return nil
+		})
+
+		Convey("the fallback is reported as used", func() {
+			So(withoutDurations(result), ShouldResemble, Result{FallbackUsed: true})
+		})
+	})
+
+	Convey("with a command whose circuit is open", t, func() {
+		defer Flush()
+
+		ForceOpen("")
+		fallback := func(err error) error {
+			return nil
+		}
+
+		Convey("a command with a fallback is short circuited to it", func() {
+			result := DoWithResult("", func() error { return nil }, fallback)
+			So(withoutDurations(result), ShouldResemble, Result{FallbackUsed: true, ShortCircuited: true})
+		})
+
+		Convey("a command without a fallback is short circuited", func() {
+			result := DoWithResult("", func() error { return nil }, nil)
+			So(withoutDurations(result), ShouldResemble, Result{Err: ErrCircuitOpen, ShortCircuited: true})
+		})
+
+		Convey("a registered fallback is reported as used", func() {
+			RegisterFallback("", fallback)
+			result := DoWithResult("", func() error { return nil }, nil)
+			So(withoutDurations(result), ShouldResemble, Result{FallbackUsed: true, ShortCircuited: true})
+		})
+	})
+
+	Convey("with a command which fails, and whose fallback serves stale data", t, func() {
+		defer Flush()
+
+		fail := func() error { return fmt.Errorf("run_error") }
+		stale := func(err error) error { return StaleResult }
+
+		Convey("the result is reported as stale", func() {
+			result := DoWithResult("stale", fail, stale)
+			So(withoutDurations(result), ShouldResemble, Result{FallbackUsed: true, Stale: true})
+		})
+
+		Convey("Do returns no error", func() {
+			So(Do("stale", fail, stale), ShouldBeNil)
+		})
+
+		Convey("a wrapped StaleResult is stale too", func() {
+			result := DoWithResult("stale", fail, func(err error) error {
+				return fmt.Errorf("cache expired: %w", StaleResult)
+			})
+			So(withoutDurations(result), ShouldResemble, Result{FallbackUsed: true, Stale: true})
+		})
+
+		Convey("a fallback chain stops at the stale fallback", func() {
+			called := false
+			err := <-GoMulti("stale", fail, stale, func(err error) error {
+				called = true
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(called, ShouldBeFalse)
+		})
+
+		Convey("the stale serve is counted as a fallback success", func() {
+			Do("stale", fail, stale)
+			Do("stale", fail, func(err error) error { return nil })
+			time.Sleep(10 * time.Millisecond)
+
+			m := GetMetrics("stale")
+			So(m.FallbackSuccesses, ShouldEqual, 2)
+			So(m.StaleFallbacks, ShouldEqual, 1)
+		})
+	})
+
+	Convey("with a stale fallback which FallbackTimeout gives up on", t, func() {
+		defer Flush()
+		ConfigureCommand("abandoned_fallback", CommandConfig{FallbackTimeout: 10})
+
+		returned := make(chan struct{})
+		result := DoWithResult("abandoned_fallback", func() error {
+			return fmt.Errorf("run_error")
+		}, func(err error) error {
+			defer close(returned)
+			time.Sleep(50 * time.Millisecond)
+			return StaleResult
+		})
+
+		Convey("the result reports the timeout, and is left alone once the fallback returns", func() {
+			<-returned
+			So(errors.Is(result.Err, ErrFallbackTimeout), ShouldBeTrue)
+			So(result.FallbackUsed, ShouldBeTrue)
+			So(result.Stale, ShouldBeFalse)
+		})
+	})
+
+	Convey("with a command which times its run and fallback", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: NoTimeout})
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+
+		Convey("a run which succeeds reports its duration", func() {
+			result := DoWithResult("", func() error {
+				clock.Advance(30 * time.Millisecond)
+				return nil
+			}, nil)
+			So(result.RunDuration, ShouldEqual, 30*time.Millisecond)
+			So(result.FallbackDuration, ShouldEqual, 0)
+		})
+
+		Convey("a run which fails reports how long it and its fallback took", func() {
+			result := DoWithResult("", func() error {
+				clock.Advance(30 * time.Millisecond)
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				clock.Advance(5 * time.Millisecond)
+				return nil
+			})
+			So(result.FallbackUsed, ShouldBeTrue)
+			So(result.RunDuration, ShouldEqual, 30*time.Millisecond)
+			So(result.FallbackDuration, ShouldEqual, 5*time.Millisecond)
+		})
+
+		Convey("a short-circuited command has no run duration", func() {
+			ForceOpen("")
+			result := DoWithResult("", func() error { return nil }, func(err error) error {
+				clock.Advance(5 * time.Millisecond)
+				return nil
+			})
+			So(result.RunDuration, ShouldEqual, 0)
+			So(result.FallbackDuration, ShouldEqual, 5*time.Millisecond)
+		})
+	})
+}
+
 func TestDoC(t *testing.T) {
 	Convey("with a command which succeeds", t, func() {
 		defer Flush()
@@ -566,3 +1730,234 @@ func TestDoC(t *testing.T) {
 		})
 	})
 }
+
+func BenchmarkGo(b *testing.B) {
+	defer Flush()
+	ConfigureCommand("benchmark", CommandConfig{Timeout: 1000})
+
+	run := func() error {
+		return nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		<-Go("benchmark", run, nil)
+	}
+}
+
+func BenchmarkGoNoTimeout(b *testing.B) {
+	defer Flush()
+	ConfigureCommand("benchmark", CommandConfig{Timeout: NoTimeout})
+
+	run := func() error {
+		return nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		<-Go("benchmark", run, nil)
+	}
+}
+
+func TestChannelContract(t *testing.T) {
+	Convey("with commands executed concurrently for every kind of outcome", t, func() {
+		defer Flush()
+		ConfigureCommand("contract_timeout", CommandConfig{Timeout: 1})
+		ConfigureCommand("contract_rejected", CommandConfig{MaxConcurrentRequests: 1})
+		ConfigureCommand("contract_rate_limited", CommandConfig{RateLimit: 1})
+		ForceOpen("contract_open")
+		SetEnabled("contract_disabled", false)
+
+		runErr := fmt.Errorf("run error")
+		slow := func(ctx context.Context) error {
+			time.Sleep(2 * time.Millisecond)
+			return nil
+		}
+		failing := func(ctx context.Context) error {
+			return runErr
+		}
+		succeeding := func(ctx context.Context, err error) error {
+			return nil
+		}
+		fallbackFailing := func(ctx context.Context, err error) error {
+			return fmt.Errorf("fallback error")
+		}
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		type execution struct {
+			ctx      context.Context
+			name     string
+			run      runFuncC
+			fallback fallbackFuncC
+		}
+		kinds := []execution{
+			{context.Background(), "contract_success", func(ctx context.Context) error { return nil }, nil},
+			{context.Background(), "contract_failure", failing, nil},
+			{context.Background(), "contract_fallback", failing, succeeding},
+			{context.Background(), "contract_fallback_failure", failing, fallbackFailing},
+			{context.Background(), "contract_business", func(ctx context.Context) error { return BusinessError(runErr) }, nil},
+			{context.Background(), "contract_panic", func(ctx context.Context) error { panic("boom") }, nil},
+			{context.Background(), "contract_timeout", slow, nil},
+			{context.Background(), "contract_timeout", slow, succeeding},
+			{context.Background(), "contract_rejected", slow, nil},
+			{context.Background(), "contract_rate_limited", slow, fallbackFailing},
+			{context.Background(), "contract_open", slow, nil},
+			{context.Background(), "contract_disabled", failing, nil},
+			{canceled, "contract_canceled", slow, nil},
+		}
+
+		const perKind = 200
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		var violations []string
+		for i := 0; i < perKind; i++ {
+			for _, kind := range kinds {
+				errChan := GoC(kind.ctx, kind.name, kind.run, kind.fallback)
+				wg.Add(1)
+				go func(name string) {
+					defer wg.Done()
+					received := 0
+					timeout := time.After(5 * time.Second)
+					for {
+						select {
+						case _, ok := <-errChan:
+							if !ok {
+								if received > 1 {
+									mutex.Lock()
+									violations = append(violations, fmt.Sprintf("%v received %d errors", name, received))
+									mutex.Unlock()
+								}
+								return
+							}
+							received++
+						case <-timeout:
+							mutex.Lock()
+							violations = append(violations, fmt.Sprintf("%v was never closed", name))
+							mutex.Unlock()
+							return
+						}
+					}
+				}(kind.name)
+			}
+		}
+		wg.Wait()
+
+		Convey("every channel receives at most one error and is closed", func() {
+			So(violations, ShouldBeEmpty)
+		})
+
+		Convey("and no command goroutine is left blocked", func() {
+			deadline := time.Now().Add(5 * time.Second)
+			remaining := func() int {
+				lifecycleMutex.Lock()
+				defer lifecycleMutex.Unlock()
+				return inFlight
+			}
+			for remaining() > 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			So(remaining(), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestAbandonedChannel(t *testing.T) {
+	Convey("with a caller which reads the first error and walks away", t, func() {
+		defer Flush()
+		ConfigureCommand("abandoned", CommandConfig{Timeout: 10})
+
+		release := make(chan struct{})
+		runReturned := make(chan struct{})
+		errChan := GoC(context.Background(), "abandoned", func(ctx context.Context) error {
+			defer close(runReturned)
+			// ignores ctx, so it returns its error well after the command timed out
+			<-release
+			return fmt.Errorf("late run error")
+		}, func(ctx context.Context, err error) error {
+			return fmt.Errorf("fallback error")
+		})
+
+		var first error
+		select {
+		case first = <-errChan:
+		case <-time.After(5 * time.Second):
+		}
+		So(first, ShouldNotBeNil)
+		So(first.Error(), ShouldContainSubstring, "fallback error")
+
+		close(release)
+		select {
+		case <-runReturned:
+		case <-time.After(5 * time.Second):
+		}
+
+		Convey("the late error is dropped rather than blocking the run goroutine", func() {
+			deadline := time.Now().Add(5 * time.Second)
+			remaining := func() int {
+				lifecycleMutex.Lock()
+				defer lifecycleMutex.Unlock()
+				return inFlight
+			}
+			for remaining() > 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			So(remaining(), ShouldEqual, 0)
+
+			_, ok := <-errChan
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("a second error sent on a full channel is dropped", t, func() {
+		cmd := &command{errChan: make(chan error, 1)}
+		first := fmt.Errorf("first")
+		cmd.sendErr(first)
+
+		sent := make(chan struct{})
+		go func() {
+			cmd.sendErr(fmt.Errorf("second"))
+			close(sent)
+		}()
+		select {
+		case <-sent:
+		case <-time.After(5 * time.Second):
+		}
+
+		So(cmd.returnErr, ShouldEqual, first)
+		So(<-cmd.errChan, ShouldEqual, first)
+	})
+}
+
+func BenchmarkDo(b *testing.B) {
+	defer Flush()
+	ConfigureCommand("benchmark", CommandConfig{Timeout: 1000})
+
+	run := func() error {
+		return nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Do("benchmark", run, nil)
+	}
+}
+
+// BenchmarkDoNoTimeout runs the command on the caller's goroutine, unlike BenchmarkDo.
+func BenchmarkDoNoTimeout(b *testing.B) {
+	defer Flush()
+	ConfigureCommand("benchmark", CommandConfig{Timeout: NoTimeout})
+
+	run := func() error {
+		return nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Do("benchmark", run, nil)
+	}
+}
@@ -0,0 +1,71 @@
+// Package hystrixtest helps test how commands' circuits are configured, by driving a command
+// through outcomes and asserting on the state of its circuit.
+//
+// Outcomes are reported with hystrix.ReportEvent, exactly as if the command had run, and each
+// helper waits for them to be recorded before returning, so tests need no sleeps:
+//
+//	hystrix.ConfigureCommand("my_command", hystrix.CommandConfig{RequestVolumeThreshold: 20, ErrorPercentThreshold: 60})
+//	hystrixtest.ForceSuccesses("my_command", 8)
+//	hystrixtest.ForceFailures("my_command", 12)
+//	hystrixtest.AssertState(t, "my_command", hystrix.CircuitOpen)
+//
+// Passing a Clock to hystrix.SetClock lets a test step through sleep windows as well.
+package hystrixtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+)
+
+// recordTimeout is how long the helpers wait for the outcomes they report to be recorded.
+const recordTimeout = time.Second
+
+// ForceFailures reports n failed executions of the named command. Once they are recorded, the
+// circuit is checked as the next execution would check it, so a circuit they make unhealthy is
+// open when ForceFailures returns.
+func ForceFailures(name string, n int) error {
+	return force(name, hystrix.OutcomeFailure, n)
+}
+
+// ForceSuccesses reports n successful executions of the named command, like ForceFailures. A
+// success reported while the circuit is half-open closes it.
+func ForceSuccesses(name string, n int) error {
+	return force(name, hystrix.OutcomeSuccess, n)
+}
+
+func force(name string, outcome hystrix.Outcome, n int) error {
+	before := hystrix.GetMetrics(name).Lifetime.Requests
+	for i := 0; i < n; i++ {
+		if err := hystrix.ReportEvent(name, outcome, 0); err != nil {
+			return err
+		}
+	}
+
+	// the metrics are recorded by another goroutine
+	want := before + uint64(n)
+	deadline := time.Now().Add(recordTimeout)
+	for hystrix.GetMetrics(name).Lifetime.Requests < want {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("hystrixtest: outcomes of %q were not recorded within %v", name, recordTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	hystrix.IsOpen(name)
+	return nil
+}
+
+// AssertState reports an error to t unless the named command's circuit is in the given state,
+// as decided by its health. It returns whether it was.
+func AssertState(t testing.TB, name string, want hystrix.CircuitState) bool {
+	t.Helper()
+
+	if got := hystrix.GetState(name); got != want {
+		t.Errorf("circuit of %q is %v, want %v", name, got, want)
+		return false
+	}
+	return true
+}
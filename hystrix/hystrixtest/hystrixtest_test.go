@@ -0,0 +1,68 @@
+package hystrixtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recorder is a testing.TB which keeps the errors reported to it.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestForceOutcomes(t *testing.T) {
+	Convey("with a command which opens at 60% errors over 20 requests", t, func() {
+		defer hystrix.Flush()
+		hystrix.ConfigureCommand("harness", hystrix.CommandConfig{
+			RequestVolumeThreshold: 20,
+			ErrorPercentThreshold:  60,
+			SleepWindow:            1000,
+		})
+
+		Convey("12 failures out of 20 open the circuit", func() {
+			So(ForceSuccesses("harness", 8), ShouldBeNil)
+			So(ForceFailures("harness", 12), ShouldBeNil)
+			So(AssertState(t, "harness", hystrix.CircuitOpen), ShouldBeTrue)
+		})
+
+		Convey("11 failures out of 20 leave it closed", func() {
+			So(ForceSuccesses("harness", 9), ShouldBeNil)
+			So(ForceFailures("harness", 11), ShouldBeNil)
+			So(AssertState(t, "harness", hystrix.CircuitClosed), ShouldBeTrue)
+		})
+
+		Convey("with a Clock, a success after the sleep window closes it again", func() {
+			clock := NewClock(time.Unix(1000000, 0))
+			hystrix.SetClock(clock)
+			defer hystrix.SetClock(nil)
+
+			So(ForceFailures("harness", 20), ShouldBeNil)
+			So(AssertState(t, "harness", hystrix.CircuitOpen), ShouldBeTrue)
+			So(hystrix.AllowRequest("harness"), ShouldBeFalse)
+
+			clock.Advance(2 * time.Second)
+			So(hystrix.AllowRequest("harness"), ShouldBeTrue)
+			So(ForceSuccesses("harness", 1), ShouldBeNil)
+			So(AssertState(t, "harness", hystrix.CircuitClosed), ShouldBeTrue)
+		})
+	})
+
+	Convey("AssertState reports a circuit in another state", t, func() {
+		defer hystrix.Flush()
+		r := &recorder{}
+
+		So(AssertState(r, "harness", hystrix.CircuitOpen), ShouldBeFalse)
+		So(r.errors, ShouldResemble, []string{`circuit of "harness" is closed, want open`})
+	})
+}
@@ -4,8 +4,42 @@ type logger interface {
 	Printf(format string, items ...interface{})
 }
 
+// LeveledLogger receives the package's log lines by level. Circuits opening, failed probe
+// requests, failed fallbacks and dropped events are warnings, and recovered panics are errors.
+type LeveledLogger interface {
+	Infof(format string, items ...interface{})
+	Warnf(format string, items ...interface{})
+	Errorf(format string, items ...interface{})
+}
+
 // NoopLogger does not log anything.
 type NoopLogger struct{}
 
 // Printf does nothing.
 func (l NoopLogger) Printf(format string, items ...interface{}) {}
+
+// Infof does nothing.
+func (l NoopLogger) Infof(format string, items ...interface{}) {}
+
+// Warnf does nothing.
+func (l NoopLogger) Warnf(format string, items ...interface{}) {}
+
+// Errorf does nothing.
+func (l NoopLogger) Errorf(format string, items ...interface{}) {}
+
+// printfLogger sends every level of log line to a logger set with SetLogger.
+type printfLogger struct {
+	logger
+}
+
+func (l printfLogger) Infof(format string, items ...interface{}) {
+	l.Printf(format, items...)
+}
+
+func (l printfLogger) Warnf(format string, items ...interface{}) {
+	l.Printf(format, items...)
+}
+
+func (l printfLogger) Errorf(format string, items ...interface{}) {
+	l.Printf(format, items...)
+}
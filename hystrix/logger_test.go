@@ -0,0 +1,99 @@
+package hystrix
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(level, format string, items ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, items...))
+}
+
+func (l *recordingLogger) Infof(format string, items ...interface{}) {
+	l.record("info", format, items...)
+}
+
+func (l *recordingLogger) Warnf(format string, items ...interface{}) {
+	l.record("warn", format, items...)
+}
+
+func (l *recordingLogger) Errorf(format string, items ...interface{}) {
+	l.record("error", format, items...)
+}
+
+func (l *recordingLogger) logged(prefix string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// printfRecorder only has Printf, like a *log.Logger.
+type printfRecorder struct {
+	recorded *recordingLogger
+}
+
+func (l printfRecorder) Printf(format string, items ...interface{}) {
+	l.recorded.record("printf", format, items...)
+}
+
+func TestLeveledLogger(t *testing.T) {
+	Convey("with a leveled logger set", t, func() {
+		defer Flush()
+		logger := &recordingLogger{}
+		SetLeveledLogger(logger)
+		defer SetLeveledLogger(DefaultLogger)
+
+		Convey("a circuit opening is a warning", func() {
+			cb, _, _ := GetCircuit("")
+			cb.setOpen()
+			So(logger.logged("warn hystrix-go: opening circuit"), ShouldBeTrue)
+		})
+
+		Convey("a failed fallback is a warning", func() {
+			Do("", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return fmt.Errorf("fallback_error")
+			})
+			So(logger.logged("warn hystrix-go: fallback for  failed: fallback_error"), ShouldBeTrue)
+		})
+
+		Convey("a recovered panic is an error", func() {
+			Do("", func() error {
+				panic("boom")
+			}, nil)
+			So(logger.logged("error hystrix-go: recovered from panic in run: boom"), ShouldBeTrue)
+		})
+	})
+
+	Convey("with a printf logger set", t, func() {
+		defer Flush()
+		logger := &recordingLogger{}
+		SetLogger(printfRecorder{logger})
+		defer SetLogger(DefaultLogger)
+
+		Convey("every level is logged with Printf", func() {
+			cb, _, _ := GetCircuit("")
+			cb.setOpen()
+			So(logger.logged("printf hystrix-go: opening circuit"), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package hystrix
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// recentCircuits orders the names of the circuits from most to least recently used, while
+	// SetMaxCommands has set a cap. It is only changed while holding circuitBreakersMutex, for
+	// reading or writing, and recentCircuitsMutex.
+	recentCircuitsMutex *sync.Mutex
+	recentCircuits      *list.List
+	recentElements      map[string]*list.Element
+	// maxCommands is the cap set by SetMaxCommands, or zero, so that looking up a circuit takes
+	// no lock of its own without one.
+	maxCommands int32
+)
+
+func init() {
+	recentCircuitsMutex = &sync.Mutex{}
+	recentCircuits = list.New()
+	recentElements = make(map[string]*list.Element)
+}
+
+// SetMaxCommands caps how many commands' circuits are kept at n, for commands named by tenant,
+// URL or the like, whose number has no bound and whose circuits would otherwise fill memory. Once
+// a new circuit takes the count past n, the circuits of the least recently executed commands are
+// evicted, along with their metrics, executors and any settings they were given by default on
+// first use. A command executed again after being evicted starts afresh, closed and with empty
+// metrics.
+//
+// The cost is that an evicted circuit forgets what it learned: a circuit which was open, or
+// forced open or closed, is closed again on next use, and must see its dependency fail anew
+// before it opens. Executions still running on an evicted circuit finish on it, but their
+// outcomes are not recorded, and their concurrency does not count against the new circuit's.
+// Settings given with ConfigureCommand or Configure are kept. n should therefore be well above
+// the number of commands used at once. A value of zero or less removes the cap, which is the
+// default. Lowering the cap evicts circuits straight away, and the cap is kept across Flush.
+func SetMaxCommands(n int) {
+	if n < 0 {
+		n = 0
+	}
+
+	circuitBreakersMutex.Lock()
+	recentCircuitsMutex.Lock()
+	atomic.StoreInt32(&maxCommands, int32(n))
+	var evicted []*CircuitBreaker
+	if n == 0 {
+		recentCircuits.Init()
+		recentElements = make(map[string]*list.Element)
+	} else {
+		// circuits created without a cap have no recorded use, so count as the least recent
+		names := make([]string, 0, len(circuitBreakers))
+		for name := range circuitBreakers {
+			if _, ok := recentElements[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			recentElements[name] = recentCircuits.PushBack(name)
+		}
+		evicted = evictCircuitsLocked(n)
+	}
+	recentCircuitsMutex.Unlock()
+	circuitBreakersMutex.Unlock()
+
+	stopCircuits(evicted)
+}
+
+// useCircuit records that the named command's circuit was used, and must be called while the
+// circuit is in circuitBreakers, holding circuitBreakersMutex for reading or writing.
+func useCircuit(name string) {
+	if atomic.LoadInt32(&maxCommands) == 0 {
+		return
+	}
+
+	recentCircuitsMutex.Lock()
+	defer recentCircuitsMutex.Unlock()
+
+	if e, ok := recentElements[name]; ok {
+		recentCircuits.MoveToFront(e)
+		return
+	}
+	recentElements[name] = recentCircuits.PushFront(name)
+}
+
+// evictNewCircuitLocked records the use of the named command's circuit, which has just been
+// created, and evicts circuits past the cap, returning them for stopCircuits. It must be called
+// holding circuitBreakersMutex for writing.
+func evictNewCircuitLocked(name string) []*CircuitBreaker {
+	max := int(atomic.LoadInt32(&maxCommands))
+	if max == 0 {
+		return nil
+	}
+
+	useCircuit(name)
+
+	recentCircuitsMutex.Lock()
+	defer recentCircuitsMutex.Unlock()
+
+	return evictCircuitsLocked(max)
+}
+
+// evictCircuitsLocked removes the least recently used circuits until at most max remain. It must
+// be called holding circuitBreakersMutex for writing and recentCircuitsMutex.
+func evictCircuitsLocked(max int) []*CircuitBreaker {
+	var evicted []*CircuitBreaker
+	for len(circuitBreakers) > max {
+		e := recentCircuits.Back()
+		if e == nil {
+			break
+		}
+		name := recentCircuits.Remove(e).(string)
+		delete(recentElements, name)
+
+		cb, ok := circuitBreakers[name]
+		if !ok {
+			continue
+		}
+		delete(circuitBreakers, name)
+		evicted = append(evicted, cb)
+		if !isGroupPool(cb.executorPool) {
+			cb.executorPool.Metrics.stop()
+		}
+
+		settingsMutex.Lock()
+		if s, ok := circuitSettings[name]; ok && s.defaulted {
+			delete(circuitSettings, name)
+		}
+		settingsMutex.Unlock()
+	}
+
+	return evicted
+}
+
+// isGroupPool reports whether pool is shared by a group's commands, and so outlives any one of
+// their circuits. It must be called holding circuitBreakersMutex.
+func isGroupPool(pool *executorPool) bool {
+	for _, p := range groupPools {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// stopCircuits stops the metrics of evicted circuits. It is called without holding any locks,
+// since stopping waits for the updates already queued to be recorded.
+func stopCircuits(evicted []*CircuitBreaker) {
+	for _, cb := range evicted {
+		cb.metrics.stop()
+	}
+}
+
+func flushRecentCircuits() {
+	recentCircuitsMutex.Lock()
+	defer recentCircuitsMutex.Unlock()
+
+	recentCircuits.Init()
+	recentElements = make(map[string]*list.Element)
+}
@@ -0,0 +1,120 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSetMaxCommands(t *testing.T) {
+	Convey("with at most three commands kept", t, func() {
+		defer Flush()
+		SetMaxCommands(3)
+		defer SetMaxCommands(0)
+
+		circuitCount := func() int {
+			circuitBreakersMutex.RLock()
+			defer circuitBreakersMutex.RUnlock()
+			return len(circuitBreakers)
+		}
+		for _, name := range []string{"tenant_a", "tenant_b", "tenant_c"} {
+			GetCircuit(name)
+		}
+
+		Convey("the least recently used circuit is evicted once a fourth is created", func() {
+			GetCircuit("tenant_a")
+			GetCircuit("tenant_d")
+			So(circuitCount(), ShouldEqual, 3)
+			_, ok := lookupCircuit("tenant_b")
+			So(ok, ShouldBeFalse)
+			_, ok = lookupCircuit("tenant_a")
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("an evicted command starts afresh", func() {
+			ForceOpen("tenant_a")
+			So(IsOpen("tenant_a"), ShouldBeTrue)
+			GetCircuit("tenant_b")
+			GetCircuit("tenant_c")
+			GetCircuit("tenant_d")
+
+			So(IsOpen("tenant_a"), ShouldBeFalse)
+			cb, created, _ := GetCircuit("tenant_a")
+			So(created, ShouldBeTrue)
+			So(cb.AllowRequest(), ShouldBeTrue)
+			So(GetMetrics("tenant_a").Lifetime.Requests, ShouldEqual, 0)
+		})
+
+		Convey("settings from ConfigureCommand survive eviction, but defaults given on first use do not", func() {
+			ConfigureCommand("tenant_e", CommandConfig{Timeout: 1234})
+			GetCircuit("tenant_e")
+			for i := 0; i < 3; i++ {
+				GetCircuit(fmt.Sprintf("other_%d", i))
+			}
+
+			So(isRegistered("tenant_e"), ShouldBeTrue)
+			So(getSettings("tenant_e").Timeout.Milliseconds(), ShouldEqual, 1234)
+			So(isRegistered("tenant_a"), ShouldBeFalse)
+		})
+
+		Convey("a group's executors are kept for its remaining commands", func() {
+			ConfigureCommand("grouped_a", CommandConfig{Group: "tenants"})
+			ConfigureCommand("grouped_b", CommandConfig{Group: "tenants"})
+			a, _, _ := GetCircuit("grouped_a")
+			b, _, _ := GetCircuit("grouped_b")
+			GetCircuit("tenant_d")
+			GetCircuit("tenant_e")
+
+			_, ok := lookupCircuit("grouped_a")
+			So(ok, ShouldBeFalse)
+			So(b.executorPool, ShouldEqual, a.executorPool)
+			So(IsOpen("grouped_b"), ShouldBeFalse)
+		})
+
+		Convey("lowering the cap evicts straight away", func() {
+			SetMaxCommands(1)
+			So(circuitCount(), ShouldEqual, 1)
+		})
+
+		Convey("removing the cap keeps every circuit", func() {
+			SetMaxCommands(0)
+			for i := 0; i < 10; i++ {
+				GetCircuit(fmt.Sprintf("other_%d", i))
+			}
+			So(circuitCount(), ShouldEqual, 13)
+		})
+
+		Convey("concurrent executions of many commands stay within the cap", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						Do(fmt.Sprintf("url_%d_%d", i, j), func() error { return nil }, nil)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			So(circuitCount(), ShouldEqual, 3)
+		})
+	})
+
+	Convey("circuits created before the cap count as the least recently used", t, func() {
+		defer Flush()
+		GetCircuit("before_a")
+		GetCircuit("before_b")
+		SetMaxCommands(2)
+		defer SetMaxCommands(0)
+
+		GetCircuit("after")
+		_, a := lookupCircuit("before_a")
+		_, b := lookupCircuit("before_b")
+		So(a != b, ShouldBeTrue)
+		_, ok := lookupCircuit("after")
+		So(ok, ShouldBeTrue)
+	})
+}
@@ -2,6 +2,7 @@ package metricCollector
 
 import (
 	"sync"
+	"time"
 
 	"github.com/afex/hystrix-go/hystrix/rolling"
 )
@@ -15,6 +16,9 @@ import (
 type DefaultMetricCollector struct {
 	mutex *sync.RWMutex
 
+	buckets        int
+	bucketDuration time.Duration
+
 	numRequests *rolling.Number
 	errors      *rolling.Number
 
@@ -25,9 +29,13 @@ type DefaultMetricCollector struct {
 	timeouts                *rolling.Number
 	contextCanceled         *rolling.Number
 	contextDeadlineExceeded *rolling.Number
+	rateLimited             *rolling.Number
+	slowCalls               *rolling.Number
 
 	fallbackSuccesses *rolling.Number
 	fallbackFailures  *rolling.Number
+	fallbackRejects   *rolling.Number
+	staleFallbacks    *rolling.Number
 	totalDuration     *rolling.Timing
 	runDuration       *rolling.Timing
 }
@@ -35,10 +43,29 @@ type DefaultMetricCollector struct {
 func newDefaultMetricCollector(name string) MetricCollector {
 	m := &DefaultMetricCollector{}
 	m.mutex = &sync.RWMutex{}
+	m.buckets = 10
+	m.bucketDuration = time.Second
 	m.Reset()
 	return m
 }
 
+// SetWindow sizes the rolling window the counters are measured over and resets them.
+func (d *DefaultMetricCollector) SetWindow(buckets int, bucketDuration time.Duration) {
+	d.mutex.Lock()
+	d.buckets = buckets
+	d.bucketDuration = bucketDuration
+	d.mutex.Unlock()
+
+	d.Reset()
+}
+
+// Window returns how long the rolling counters are measured over.
+func (d *DefaultMetricCollector) Window() time.Duration {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return time.Duration(d.buckets) * d.bucketDuration
+}
+
 // NumRequests returns the rolling number of requests
 func (d *DefaultMetricCollector) NumRequests() *rolling.Number {
 	d.mutex.RLock()
@@ -107,6 +134,20 @@ func (d *DefaultMetricCollector) ContextDeadlineExceeded() *rolling.Number {
 	return d.contextDeadlineExceeded
 }
 
+// RateLimited returns the rolling number of executions rejected by the command's RateLimit
+func (d *DefaultMetricCollector) RateLimited() *rolling.Number {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.rateLimited
+}
+
+// SlowCalls returns the rolling number of runs which took longer than the command's slow call duration
+func (d *DefaultMetricCollector) SlowCalls() *rolling.Number {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.slowCalls
+}
+
 // FallbackFailures returns the rolling number of fallback failures
 func (d *DefaultMetricCollector) FallbackFailures() *rolling.Number {
 	d.mutex.RLock()
@@ -114,6 +155,20 @@ func (d *DefaultMetricCollector) FallbackFailures() *rolling.Number {
 	return d.fallbackFailures
 }
 
+// FallbackRejections returns the rolling number of fallbacks skipped because too many were running
+func (d *DefaultMetricCollector) FallbackRejections() *rolling.Number {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.fallbackRejects
+}
+
+// StaleFallbacks returns the rolling number of fallbacks which served stale data
+func (d *DefaultMetricCollector) StaleFallbacks() *rolling.Number {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.staleFallbacks
+}
+
 // TotalDuration returns the rolling total duration
 func (d *DefaultMetricCollector) TotalDuration() *rolling.Timing {
 	d.mutex.RLock()
@@ -141,11 +196,18 @@ func (d *DefaultMetricCollector) Update(r MetricResult) {
 	d.timeouts.Increment(r.Timeouts)
 	d.fallbackSuccesses.Increment(r.FallbackSuccesses)
 	d.fallbackFailures.Increment(r.FallbackFailures)
+	d.fallbackRejects.Increment(r.FallbackRejections)
+	d.staleFallbacks.Increment(r.StaleFallbacks)
 	d.contextCanceled.Increment(r.ContextCanceled)
 	d.contextDeadlineExceeded.Increment(r.ContextDeadlineExceeded)
+	d.rateLimited.Increment(r.RateLimited)
+	d.slowCalls.Increment(r.SlowCalls)
 
 	d.totalDuration.Add(r.TotalDuration)
-	d.runDuration.Add(r.RunDuration)
+	if r.Successes > 0 || r.Failures > 0 {
+		// only executions which returned from run have a meaningful run duration
+		d.runDuration.Add(r.RunDuration)
+	}
 }
 
 // Reset resets all metrics in this collector to 0.
@@ -153,17 +215,27 @@ func (d *DefaultMetricCollector) Reset() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	d.numRequests = rolling.NewNumber()
-	d.errors = rolling.NewNumber()
-	d.successes = rolling.NewNumber()
-	d.rejects = rolling.NewNumber()
-	d.shortCircuits = rolling.NewNumber()
-	d.failures = rolling.NewNumber()
-	d.timeouts = rolling.NewNumber()
-	d.fallbackSuccesses = rolling.NewNumber()
-	d.fallbackFailures = rolling.NewNumber()
-	d.contextCanceled = rolling.NewNumber()
-	d.contextDeadlineExceeded = rolling.NewNumber()
+	d.numRequests = d.newNumber()
+	d.errors = d.newNumber()
+	d.successes = d.newNumber()
+	d.rejects = d.newNumber()
+	d.shortCircuits = d.newNumber()
+	d.failures = d.newNumber()
+	d.timeouts = d.newNumber()
+	d.fallbackSuccesses = d.newNumber()
+	d.fallbackFailures = d.newNumber()
+	d.fallbackRejects = d.newNumber()
+	d.staleFallbacks = d.newNumber()
+	d.contextCanceled = d.newNumber()
+	d.contextDeadlineExceeded = d.newNumber()
+	d.rateLimited = d.newNumber()
+	d.slowCalls = d.newNumber()
 	d.totalDuration = rolling.NewTiming()
 	d.runDuration = rolling.NewTiming()
 }
+
+// newNumber returns a counter for the collector's window. Every counter is a whole number, so
+// they are atomic, and reading them while Update runs takes no lock.
+func (d *DefaultMetricCollector) newNumber() *rolling.Number {
+	return rolling.NewAtomicNumberWithWindow(d.buckets, d.bucketDuration)
+}
@@ -49,11 +49,24 @@ type MetricResult struct {
 	Timeouts                float64
 	FallbackSuccesses       float64
 	FallbackFailures        float64
+	FallbackRejections      float64
+	StaleFallbacks          float64
 	ContextCanceled         float64
 	ContextDeadlineExceeded float64
+	RateLimited             float64
+	SlowCalls               float64
 	TotalDuration           time.Duration
 	RunDuration             time.Duration
 	ConcurrencyInUse        float64
+	// QueueDepth is how many commands were waiting for an executor under MaxQueueWait once the
+	// execution was reported.
+	QueueDepth float64
+	// Tags are the labels of the execution, from the command's configured Tags and any added
+	// with hystrix.WithTags. They must not be modified.
+	Tags map[string]string
+	// CircuitState is the state of the circuit once the execution was reported: "closed",
+	// "open" or "half-open".
+	CircuitState string
 }
 
 // MetricCollector represents the contract that all collectors must fulfill to gather circuit statistics.
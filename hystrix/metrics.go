@@ -9,10 +9,159 @@ import (
 )
 
 type commandExecution struct {
-	Types            []string      `json:"types"`
-	Start            time.Time     `json:"start_time"`
-	RunDuration      time.Duration `json:"run_duration"`
-	ConcurrencyInUse float64       `json:"concurrency_inuse"`
+	Types            []string          `json:"types"`
+	Start            time.Time         `json:"start_time"`
+	RunDuration      time.Duration     `json:"run_duration"`
+	ConcurrencyInUse float64           `json:"concurrency_inuse"`
+	QueueDepth       int               `json:"queue_depth"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	CircuitState     string            `json:"circuit_state"`
+}
+
+// Metrics is a snapshot of the counts recorded for a command. The embedded counts cover the
+// rolling window, which is what the circuit's health is measured over, while Lifetime covers
+// every execution since the circuit was created.
+type Metrics struct {
+	MetricCounts
+	// Window is how long the rolling counts cover, for turning them into rates.
+	Window time.Duration
+	// Lifetime is the counts since the command's circuit was created, when the command was first
+	// executed after start or Flush. Unlike the rolling counts, closing the circuit and
+	// ResetCircuit leave them alone.
+	Lifetime MetricCounts
+	// Concurrency shows how close the command runs to its MaxConcurrentRequests.
+	Concurrency ConcurrencyHistogram
+	// Queue shows how many commands wait for an executor under MaxQueueWait.
+	Queue QueueMetrics
+}
+
+// QueueMetrics describes the commands waiting for one of a command's executors under
+// MaxQueueWait. For commands in a Group it covers the group's shared executors.
+type QueueMetrics struct {
+	// Depth is how many commands are waiting right now.
+	Depth int
+	// Timeouts is how many gave up over the rolling window, having waited MaxQueueWait without
+	// getting an executor. They are also counted as Rejects.
+	Timeouts uint64
+}
+
+// MetricCounts are how many of a command's executions had each outcome.
+type MetricCounts struct {
+	Requests           uint64
+	Errors             uint64
+	Successes          uint64
+	Failures           uint64
+	Rejects            uint64
+	ShortCircuits      uint64
+	Timeouts           uint64
+	FallbackSuccesses  uint64
+	FallbackFailures   uint64
+	FallbackRejections uint64
+	// StaleFallbacks counts the fallbacks which returned StaleResult, which are also counted in
+	// FallbackSuccesses.
+	StaleFallbacks          uint64
+	ContextCanceled         uint64
+	ContextDeadlineExceeded uint64
+	RateLimited             uint64
+	SlowCalls               uint64
+}
+
+// ConcurrencyHistogram is the distribution of how many of a command's executors were in use over
+// its rolling window, sampled each time an execution took one. For commands in a Group it covers
+// the group's shared executors.
+type ConcurrencyHistogram struct {
+	// Max is the most executors seen in use at once.
+	Max int
+	// Counts[n] is how many executions found n executors in use, their own included, when they
+	// took one. It is Max+1 long, so Counts[0] is always zero.
+	Counts []uint64
+}
+
+// GetMetrics returns a snapshot of the rolling and lifetime metrics for the named command.
+// Every count is read at the same instant, so they always agree with each other.
+// Commands which have never been executed report all zeroes.
+func GetMetrics(name string) Metrics {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return Metrics{}
+	}
+
+	return cb.Metrics()
+}
+
+// HasExecuted reports whether the named command has been executed since start or Flush, which
+// helps find commands which are configured but never used. Like GetMetrics, it only sees an
+// execution once its outcome has been recorded. Unknown commands, and commands whose circuit has
+// only been looked up, report false.
+func HasExecuted(name string) bool {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return false
+	}
+
+	cb.metrics.lifetimeMutex.Lock()
+	defer cb.metrics.lifetimeMutex.Unlock()
+
+	return cb.metrics.lifetime.Requests > 0
+}
+
+// Latencies summarizes how long a command's run function has taken over the last 60 seconds.
+// Only executions which returned from run are included, so rejections, short circuits and
+// timeouts do not skew the figures.
+type Latencies struct {
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	P995 time.Duration
+}
+
+// GetLatencies returns the recent run latencies for the named command.
+// Commands which have never been executed report all zeroes.
+func GetLatencies(name string) Latencies {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return Latencies{}
+	}
+
+	return latenciesOf(cb.metrics.DefaultCollector().RunDuration())
+}
+
+func latenciesOf(runDuration *rolling.Timing) Latencies {
+	return Latencies{
+		Mean: runDuration.MeanDuration(),
+		P50:  runDuration.PercentileDuration(50),
+		P90:  runDuration.PercentileDuration(90),
+		P99:  runDuration.PercentileDuration(99),
+		P995: runDuration.PercentileDuration(99.5),
+	}
+}
+
+// HealthCounts are the rolling counts a circuit's health is judged on. A circuit opens once it
+// has seen enough requests and its ErrorPercentage reaches the command's ErrorPercentThreshold,
+// or its SlowCallPercentage reaches the command's SlowCallRateThreshold.
+type HealthCounts struct {
+	// Total is the number of requests in the rolling window, not counting commands whose
+	// caller's context was canceled or passed its deadline.
+	Total  uint64
+	Errors uint64
+	// ErrorPercentage is Errors as a rounded percentage of Total, or 0 when there were no requests.
+	ErrorPercentage int
+	// SlowCalls are the runs which took longer than the command's slow call duration.
+	SlowCalls uint64
+	// SlowCallPercentage is SlowCalls as a rounded percentage of Total, or 0 when there were no requests.
+	SlowCallPercentage int
+}
+
+// GetHealth returns the current health counts for the named command.
+// Commands which have never been executed report all zeroes.
+func GetHealth(name string) HealthCounts {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return HealthCounts{}
+	}
+
+	return cb.metrics.Health(getClock().Now())
 }
 
 type metricExchange struct {
@@ -21,6 +170,17 @@ type metricExchange struct {
 	Mutex   *sync.RWMutex
 
 	metricCollectors []metricCollector.MetricCollector
+	// lifetime is only changed by record, but is read while it runs, so has a mutex of its own.
+	lifetimeMutex sync.Mutex
+	lifetime      MetricCounts
+	// counts measures health over the last requests for CountBased windows, and is nil otherwise.
+	counts *countWindow
+	// advisory spaces out the checks of SetAdvisoryChecks.
+	advisory advisoryWatch
+
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
 }
 
 func newMetricExchange(name string) *metricExchange {
@@ -28,9 +188,18 @@ func newMetricExchange(name string) *metricExchange {
 	m.Name = name
 
 	m.Updates = make(chan *commandExecution, 2000)
+	m.done = make(chan struct{})
+	m.stopped = make(chan struct{})
 	m.Mutex = &sync.RWMutex{}
 	m.metricCollectors = metricCollector.Registry.InitializeMetricCollectors(name)
+	if settings := getSettings(name); settings.WindowType == CountBased {
+		m.counts = newCountWindow(settings.WindowSize)
+	}
 	m.Reset()
+	if d, ok := m.metricCollectors[0].(*metricCollector.DefaultMetricCollector); ok {
+		settings := getSettings(name)
+		d.SetWindow(rollingBuckets(settings.RollingWindow, settings.RollingBuckets))
+	}
 
 	go m.Monitor()
 
@@ -50,37 +219,86 @@ func (m *metricExchange) DefaultCollector() *metricCollector.DefaultMetricCollec
 }
 
 func (m *metricExchange) Monitor() {
-	for update := range m.Updates {
-		// we only grab a read lock to make sure Reset() isn't changing the numbers.
-		m.Mutex.RLock()
-
-		totalDuration := time.Since(update.Start)
-		wg := &sync.WaitGroup{}
-		for _, collector := range m.metricCollectors {
-			wg.Add(1)
-			go m.IncrementMetrics(wg, collector, update, totalDuration)
+	defer close(m.stopped)
+
+	for {
+		select {
+		case update := <-m.Updates:
+			m.record(update)
+		case <-m.done:
+			// record what was already queued, so stopping does not lose metrics
+			for {
+				select {
+				case update := <-m.Updates:
+					m.record(update)
+				default:
+					return
+				}
+			}
 		}
-		wg.Wait()
+	}
+}
+
+func (m *metricExchange) record(update *commandExecution) {
+	// we only grab a read lock to make sure Reset() isn't changing the numbers.
+	m.Mutex.RLock()
 
-		m.Mutex.RUnlock()
+	totalDuration := getClock().Now().Sub(update.Start)
+	if m.counts != nil {
+		m.recordCount(update)
 	}
+	m.lifetimeMutex.Lock()
+	m.lifetime.add(m.metricResult(update, totalDuration))
+	m.lifetimeMutex.Unlock()
+
+	wg := &sync.WaitGroup{}
+	for _, collector := range m.metricCollectors {
+		wg.Add(1)
+		go m.IncrementMetrics(wg, collector, update, totalDuration)
+	}
+	wg.Wait()
+
+	m.Mutex.RUnlock()
+
+	m.advise(getClock().Now())
+}
+
+// stop ends the Monitor goroutine, waiting for it to record the updates already queued.
+// Updates sent afterwards are no longer recorded.
+func (m *metricExchange) stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+	<-m.stopped
 }
 
 func (m *metricExchange) IncrementMetrics(wg *sync.WaitGroup, collector metricCollector.MetricCollector, update *commandExecution, totalDuration time.Duration) {
+	collector.Update(m.metricResult(update, totalDuration))
+
+	wg.Done()
+}
+
+// metricResult turns an execution into the counts reported to collectors.
+func (m *metricExchange) metricResult(update *commandExecution, totalDuration time.Duration) metricCollector.MetricResult {
 	// granular metrics
 	r := metricCollector.MetricResult{
 		Attempts:         1,
 		TotalDuration:    totalDuration,
 		RunDuration:      update.RunDuration,
 		ConcurrencyInUse: update.ConcurrencyInUse,
+		QueueDepth:       float64(update.QueueDepth),
+		Tags:             update.Tags,
+		CircuitState:     update.CircuitState,
 	}
 
 	switch update.Types[0] {
 	case "success":
 		r.Successes = 1
+		r.SlowCalls = m.slowCall(update.RunDuration)
 	case "failure":
 		r.Failures = 1
 		r.Errors = 1
+		r.SlowCalls = m.slowCall(update.RunDuration)
 	case "rejected":
 		r.Rejects = 1
 		r.Errors = 1
@@ -94,6 +312,8 @@ func (m *metricExchange) IncrementMetrics(wg *sync.WaitGroup, collector metricCo
 		r.ContextCanceled = 1
 	case "context_deadline_exceeded":
 		r.ContextDeadlineExceeded = 1
+	case "rate-limited":
+		r.RateLimited = 1
 	}
 
 	if len(update.Types) > 1 {
@@ -101,14 +321,46 @@ func (m *metricExchange) IncrementMetrics(wg *sync.WaitGroup, collector metricCo
 		if update.Types[1] == "fallback-success" {
 			r.FallbackSuccesses = 1
 		}
+		if update.Types[1] == "fallback-stale" {
+			r.FallbackSuccesses = 1
+			r.StaleFallbacks = 1
+		}
 		if update.Types[1] == "fallback-failure" {
 			r.FallbackFailures = 1
 		}
+		if update.Types[1] == "fallback-rejection" {
+			r.FallbackRejections = 1
+		}
 	}
 
-	collector.Update(r)
+	return r
+}
 
-	wg.Done()
+func (c *MetricCounts) add(r metricCollector.MetricResult) {
+	c.Requests += uint64(r.Attempts)
+	c.Errors += uint64(r.Errors)
+	c.Successes += uint64(r.Successes)
+	c.Failures += uint64(r.Failures)
+	c.Rejects += uint64(r.Rejects)
+	c.ShortCircuits += uint64(r.ShortCircuits)
+	c.Timeouts += uint64(r.Timeouts)
+	c.FallbackSuccesses += uint64(r.FallbackSuccesses)
+	c.FallbackFailures += uint64(r.FallbackFailures)
+	c.FallbackRejections += uint64(r.FallbackRejections)
+	c.StaleFallbacks += uint64(r.StaleFallbacks)
+	c.ContextCanceled += uint64(r.ContextCanceled)
+	c.ContextDeadlineExceeded += uint64(r.ContextDeadlineExceeded)
+	c.RateLimited += uint64(r.RateLimited)
+	c.SlowCalls += uint64(r.SlowCalls)
+}
+
+// slowCall returns 1 if a run which took runDuration counts as a slow call, and 0 otherwise.
+func (m *metricExchange) slowCall(runDuration time.Duration) float64 {
+	threshold := getSettings(m.Name).SlowCallDurationThreshold
+	if threshold > 0 && runDuration > threshold {
+		return 1
+	}
+	return 0
 }
 
 func (m *metricExchange) Reset() {
@@ -118,6 +370,55 @@ func (m *metricExchange) Reset() {
 	for _, collector := range m.metricCollectors {
 		collector.Reset()
 	}
+	if m.counts != nil {
+		m.counts.reset()
+	}
+}
+
+// Snapshot reads every count at the same instant, by keeping record from running until they
+// have all been read, so no execution is seen part way through being counted.
+func (m *metricExchange) Snapshot(now time.Time) Metrics {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+
+	return m.snapshotLocked(now)
+}
+
+// status reads the counts, health and latencies without any execution being recorded part way
+// through, by keeping record from running until they have all been read.
+func (m *metricExchange) status(now time.Time) (Metrics, HealthCounts, Latencies) {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+
+	return m.snapshotLocked(now), m.healthLocked(now), latenciesOf(m.DefaultCollector().RunDuration())
+}
+
+func (m *metricExchange) snapshotLocked(now time.Time) Metrics {
+	c := m.DefaultCollector()
+
+	m.lifetimeMutex.Lock()
+	lifetime := m.lifetime
+	m.lifetimeMutex.Unlock()
+
+	counts := MetricCounts{
+		Requests:                uint64(c.NumRequests().Sum(now)),
+		Errors:                  uint64(c.Errors().Sum(now)),
+		Successes:               uint64(c.Successes().Sum(now)),
+		Failures:                uint64(c.Failures().Sum(now)),
+		Rejects:                 uint64(c.Rejects().Sum(now)),
+		ShortCircuits:           uint64(c.ShortCircuits().Sum(now)),
+		Timeouts:                uint64(c.Timeouts().Sum(now)),
+		FallbackSuccesses:       uint64(c.FallbackSuccesses().Sum(now)),
+		FallbackFailures:        uint64(c.FallbackFailures().Sum(now)),
+		FallbackRejections:      uint64(c.FallbackRejections().Sum(now)),
+		StaleFallbacks:          uint64(c.StaleFallbacks().Sum(now)),
+		ContextCanceled:         uint64(c.ContextCanceled().Sum(now)),
+		ContextDeadlineExceeded: uint64(c.ContextDeadlineExceeded().Sum(now)),
+		RateLimited:             uint64(c.RateLimited().Sum(now)),
+		SlowCalls:               uint64(c.SlowCalls().Sum(now)),
+	}
+
+	return Metrics{MetricCounts: counts, Window: c.Window(), Lifetime: lifetime}
 }
 
 func (m *metricExchange) Requests() *rolling.Number {
@@ -130,21 +431,97 @@ func (m *metricExchange) requestsLocked() *rolling.Number {
 	return m.DefaultCollector().NumRequests()
 }
 
-func (m *metricExchange) ErrorPercent(now time.Time) int {
+// Health reads the request and error counts at the same instant.
+func (m *metricExchange) Health(now time.Time) HealthCounts {
 	m.Mutex.RLock()
 	defer m.Mutex.RUnlock()
 
-	var errPct float64
+	return m.healthLocked(now)
+}
+
+func (m *metricExchange) healthLocked(now time.Time) HealthCounts {
+	if m.counts != nil {
+		return healthOf(m.counts.counts())
+	}
+
 	reqs := m.requestsLocked().Sum(now)
-	errs := m.DefaultCollector().Errors().Sum(now)
+	// commands given up on by their caller, or held back by the rate limit, say nothing about
+	// the health of the dependency
+	c := m.DefaultCollector()
+	reqs -= c.ContextCanceled().Sum(now) + c.ContextDeadlineExceeded().Sum(now) + c.RateLimited().Sum(now)
+	if reqs < 0 {
+		reqs = 0
+	}
+	errs := c.Errors().Sum(now)
+	slow := c.SlowCalls().Sum(now)
+
+	return healthOf(uint64(reqs), uint64(errs), uint64(slow))
+}
 
+func healthOf(reqs, errs, slow uint64) HealthCounts {
+	var errPct, slowPct float64
 	if reqs > 0 {
 		errPct = (float64(errs) / float64(reqs)) * 100
+		slowPct = (float64(slow) / float64(reqs)) * 100
 	}
 
-	return int(errPct + 0.5)
+	return HealthCounts{
+		Total:              reqs,
+		Errors:             errs,
+		ErrorPercentage:    int(errPct + 0.5),
+		SlowCalls:          slow,
+		SlowCallPercentage: int(slowPct + 0.5),
+	}
+}
+
+// recordCount adds an execution to a CountBased window. Like the rolling counts, commands given
+// up on by their caller or held back by the rate limit are left out.
+func (m *metricExchange) recordCount(update *commandExecution) {
+	switch update.Types[0] {
+	case "success":
+		m.counts.add(requestOutcome{slow: m.slowCall(update.RunDuration) > 0})
+	case "failure":
+		m.counts.add(requestOutcome{err: true, slow: m.slowCall(update.RunDuration) > 0})
+	case "rejected", "short-circuit", "timeout":
+		m.counts.add(requestOutcome{err: true})
+	}
+}
+
+func (m *metricExchange) ErrorPercent(now time.Time) int {
+	return m.Health(now).ErrorPercentage
 }
 
 func (m *metricExchange) IsHealthy(now time.Time) bool {
-	return m.ErrorPercent(now) < getSettings(m.Name).ErrorPercentThreshold
+	settings := getSettings(m.Name)
+	health := m.Health(now)
+
+	// reaching a threshold is enough to be unhealthy, it does not need to be exceeded
+	if health.ErrorPercentage >= settings.ErrorPercentThreshold {
+		return false
+	}
+	if settings.SlowCallDurationThreshold > 0 && settings.SlowCallRateThreshold > 0 &&
+		health.SlowCallPercentage >= settings.SlowCallRateThreshold {
+		return false
+	}
+
+	return true
+}
+
+// rollingBuckets splits a rolling window into the given number of buckets, returning how many
+// buckets there are and how long each one is. With zero buckets, the window is split into buckets
+// of about a second. Windows which are not a whole number of seconds get slightly shorter
+// buckets, so the window is never truncated.
+func rollingBuckets(window time.Duration, buckets int) (int, time.Duration) {
+	if buckets <= 0 {
+		buckets = int((window + time.Second - 1) / time.Second)
+	}
+	if time.Duration(buckets) > window {
+		// every bucket must last at least a nanosecond
+		buckets = int(window)
+	}
+	if buckets <= 1 {
+		return 1, window
+	}
+
+	return buckets, window / time.Duration(buckets)
 }
@@ -1,6 +1,7 @@
 package hystrix
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -43,3 +44,295 @@ func TestErrorPercent(t *testing.T) {
 		})
 	})
 }
+
+func TestGetMetrics(t *testing.T) {
+	Convey("with a command which has succeeded once and failed once", t, func() {
+		defer Flush()
+
+		Do("metrics", func() error { return nil }, nil)
+		Do("metrics", func() error { return fmt.Errorf("fail") }, func(err error) error { return nil })
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("GetMetrics reports both outcomes", func() {
+			m := GetMetrics("metrics")
+			So(m.Requests, ShouldEqual, 2)
+			So(m.Successes, ShouldEqual, 1)
+			So(m.Failures, ShouldEqual, 1)
+			So(m.Errors, ShouldEqual, 1)
+			So(m.FallbackSuccesses, ShouldEqual, 1)
+		})
+
+		Convey("GetMetrics reports the lifetime counts and the window's length", func() {
+			m := GetMetrics("metrics")
+			So(m.Lifetime, ShouldResemble, m.MetricCounts)
+			So(m.Window, ShouldEqual, 10*time.Second)
+		})
+
+		Convey("resetting the circuit keeps the lifetime counts", func() {
+			ResetCircuit("metrics")
+			Do("metrics", func() error { return nil }, nil)
+			time.Sleep(10 * time.Millisecond)
+
+			m := GetMetrics("metrics")
+			So(m.Requests, ShouldEqual, 1)
+			So(m.Lifetime.Requests, ShouldEqual, 3)
+			So(m.Lifetime.Successes, ShouldEqual, 2)
+			So(m.Lifetime.Failures, ShouldEqual, 1)
+		})
+	})
+
+	Convey("with a command which is executing concurrently", t, func() {
+		defer Flush()
+		// keep the circuit closed, so every execution is a success or a failure
+		ConfigureCommand("concurrent", CommandConfig{ErrorPercentThreshold: 100})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				Do("concurrent", func() error {
+					if i%2 == 0 {
+						return fmt.Errorf("fail")
+					}
+					return nil
+				}, func(err error) error { return nil })
+			}
+		}()
+
+		Convey("GetMetrics never sees an execution half counted", func() {
+			consistent := true
+			for running := true; running; {
+				select {
+				case <-done:
+					running = false
+				default:
+				}
+
+				m := GetMetrics("concurrent")
+				if m.Requests != m.Successes+m.Failures || m.Errors != m.Failures {
+					consistent = false
+				}
+			}
+			So(consistent, ShouldBeTrue)
+		})
+	})
+
+	Convey("with a command which has never run", t, func() {
+		Convey("GetMetrics reports zeroes", func() {
+			So(GetMetrics("never-run"), ShouldResemble, Metrics{})
+		})
+	})
+}
+
+func TestRejectionReasons(t *testing.T) {
+	Convey("with commands which did not execute for different reasons", t, func() {
+		defer Flush()
+		run := func() error { return nil }
+
+		ForceOpen("open")
+		Do("open", run, nil)
+
+		ConfigureCommand("limited", CommandConfig{RateLimit: 1})
+		Do("limited", run, nil)
+		Do("limited", run, nil)
+
+		ConfigureCommand("full", CommandConfig{MaxConcurrentRequests: 1})
+		release := make(chan struct{})
+		running := make(chan struct{})
+		Go("full", func() error {
+			close(running)
+			<-release
+			return nil
+		}, nil)
+		<-running
+		Do("full", run, nil)
+		close(release)
+
+		ConfigureCommand("slow", CommandConfig{Timeout: 10})
+		Do("slow", func() error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, nil)
+		So(waitForCommands(time.Second), ShouldBeTrue)
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("each reason has a counter of its own", func() {
+			So(GetMetrics("open").ShortCircuits, ShouldEqual, 1)
+			So(GetMetrics("limited").RateLimited, ShouldEqual, 1)
+			So(GetMetrics("full").Rejects, ShouldEqual, 1)
+			So(GetMetrics("slow").Timeouts, ShouldEqual, 1)
+
+			So(GetMetrics("open").Rejects+GetMetrics("open").RateLimited+GetMetrics("open").Timeouts, ShouldEqual, 0)
+			So(GetMetrics("full").ShortCircuits+GetMetrics("full").RateLimited+GetMetrics("full").Timeouts, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestGetHealth(t *testing.T) {
+	Convey("with a command which has succeeded twice and failed once", t, func() {
+		defer Flush()
+
+		Do("health", func() error { return nil }, nil)
+		Do("health", func() error { return nil }, nil)
+		Do("health", func() error { return fmt.Errorf("fail") }, nil)
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("GetHealth reports the error percentage", func() {
+			So(GetHealth("health"), ShouldResemble, HealthCounts{Total: 3, Errors: 1, ErrorPercentage: 33})
+		})
+	})
+
+	Convey("with a command which has no requests", t, func() {
+		defer Flush()
+		GetCircuit("health")
+
+		Convey("GetHealth reports a zero error percentage", func() {
+			So(GetHealth("health"), ShouldResemble, HealthCounts{})
+			So(GetHealth("never-run"), ShouldResemble, HealthCounts{})
+		})
+	})
+}
+
+func TestHasExecuted(t *testing.T) {
+	Convey("with a configured command", t, func() {
+		defer Flush()
+		ConfigureCommand("executed", CommandConfig{})
+
+		Convey("it has not executed before it runs", func() {
+			So(HasExecuted("executed"), ShouldBeFalse)
+			GetCircuit("executed")
+			So(HasExecuted("executed"), ShouldBeFalse)
+		})
+
+		Convey("it has executed once it runs", func() {
+			Do("executed", func() error { return nil }, nil)
+			time.Sleep(10 * time.Millisecond)
+			So(HasExecuted("executed"), ShouldBeTrue)
+
+			Convey("even after its circuit is reset", func() {
+				ResetCircuit("executed")
+				So(HasExecuted("executed"), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("an unknown command has not executed", t, func() {
+		So(HasExecuted("unknown"), ShouldBeFalse)
+	})
+}
+
+func TestGetLatencies(t *testing.T) {
+	Convey("with a command whose runs took 1ms to 100ms", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+
+		for i := 1; i <= 100; i++ {
+			Do("latencies", func() error {
+				clock.Advance(time.Duration(i) * time.Millisecond)
+				return nil
+			}, nil)
+		}
+		ForceOpen("latencies")
+		Do("latencies", func() error { return nil }, func(err error) error { return nil })
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("GetLatencies reports percentiles of the runs only", func() {
+			l := GetLatencies("latencies")
+			So(l.Mean, ShouldEqual, 50500*time.Microsecond)
+			So(l.P50, ShouldEqual, 50*time.Millisecond)
+			So(l.P90, ShouldEqual, 90*time.Millisecond)
+			So(l.P99, ShouldEqual, 99*time.Millisecond)
+			So(l.P995, ShouldEqual, 100*time.Millisecond)
+		})
+	})
+
+	Convey("with a command which has never run", t, func() {
+		Convey("GetLatencies reports zeroes", func() {
+			So(GetLatencies("never-run"), ShouldResemble, Latencies{})
+		})
+	})
+}
+
+func TestRollingWindow(t *testing.T) {
+	Convey("with a command configured for a 2 second rolling window", t, func() {
+		defer Flush()
+		ConfigureCommand("window", CommandConfig{RollingWindow: 2000})
+
+		Do("window", func() error { return fmt.Errorf("fail") }, nil)
+		time.Sleep(10 * time.Millisecond)
+		So(GetMetrics("window").Failures, ShouldEqual, 1)
+
+		Convey("failures age out once the window has passed", func() {
+			time.Sleep(2100 * time.Millisecond)
+			So(GetMetrics("window").Failures, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestRollingBuckets(t *testing.T) {
+	Convey("rolling windows are split into buckets without being truncated", t, func() {
+		buckets, size := rollingBuckets(10*time.Second, 0)
+		So(buckets, ShouldEqual, 10)
+		So(size, ShouldEqual, time.Second)
+
+		buckets, size = rollingBuckets(1500*time.Millisecond, 0)
+		So(buckets, ShouldEqual, 2)
+		So(size, ShouldEqual, 750*time.Millisecond)
+
+		buckets, size = rollingBuckets(500*time.Millisecond, 0)
+		So(buckets, ShouldEqual, 1)
+		So(size, ShouldEqual, 500*time.Millisecond)
+	})
+
+	Convey("rolling windows can be split into a given number of buckets", t, func() {
+		buckets, size := rollingBuckets(60*time.Second, 6)
+		So(buckets, ShouldEqual, 6)
+		So(size, ShouldEqual, 10*time.Second)
+
+		buckets, size = rollingBuckets(time.Second, 4)
+		So(buckets, ShouldEqual, 4)
+		So(size, ShouldEqual, 250*time.Millisecond)
+	})
+}
+
+func TestCountBasedWindow(t *testing.T) {
+	Convey("with a command measuring health over its last 4 requests", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("counted", CommandConfig{WindowType: CountBased, WindowSize: 4, RequestVolumeThreshold: 4})
+
+		for i := 0; i < 4; i++ {
+			Do("counted", func() error { return fmt.Errorf("fail") }, nil)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("failures are kept however long ago they were", func() {
+			clock.Advance(time.Minute)
+			So(GetHealth("counted").Total, ShouldEqual, 4)
+			So(GetHealth("counted").ErrorPercentage, ShouldEqual, 100)
+			So(IsOpen("counted"), ShouldBeTrue)
+			So(GetMetrics("counted").Failures, ShouldEqual, 0)
+		})
+
+		Convey("newer requests push the oldest out of the window", func() {
+			cb, _, _ := GetCircuit("counted")
+			for i := 0; i < 3; i++ {
+				cb.metrics.Updates <- &commandExecution{Types: []string{"success"}}
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetHealth("counted").Total, ShouldEqual, 4)
+			So(GetHealth("counted").ErrorPercentage, ShouldEqual, 25)
+		})
+	})
+
+	Convey("count-based windows default to DefaultWindowSize requests", t, func() {
+		defer Flush()
+		ConfigureCommand("counted", CommandConfig{WindowType: CountBased})
+		So(getSettings("counted").WindowSize, ShouldEqual, DefaultWindowSize)
+	})
+}
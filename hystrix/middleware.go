@@ -0,0 +1,64 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	middlewaresMutex *sync.RWMutex
+	middlewares      map[string][]func(runFuncC) runFuncC
+)
+
+func init() {
+	middlewaresMutex = &sync.RWMutex{}
+	middlewares = make(map[string][]func(runFuncC) runFuncC)
+}
+
+// RegisterRunMiddleware adds middleware around the run function of every execution of the named
+// command, like HTTP middleware, for behavior such as logging or refreshing a token which should
+// not be repeated at every call site. The middleware is given the next function in the chain,
+// and returns a function which usually calls it.
+//
+// Middleware registered first is outermost. It wraps each attempt at run, so retried commands
+// pass through it again for each retry, and it runs inside the command's timeout and panic
+// recovery. Commands disabled with SetEnabled still pass through their middleware.
+func RegisterRunMiddleware(name string, middleware func(next runFunc) runFunc) {
+	RegisterRunMiddlewareC(name, func(next runFuncC) runFuncC {
+		return func(ctx context.Context) error {
+			return middleware(func() error {
+				return next(ctx)
+			})()
+		}
+	})
+}
+
+// RegisterRunMiddlewareC adds middleware around the run function of the named command like
+// RegisterRunMiddleware, for middleware which needs the run's context.
+func RegisterRunMiddlewareC(name string, middleware func(next runFuncC) runFuncC) {
+	name = canonicalName(name)
+	middlewaresMutex.Lock()
+	defer middlewaresMutex.Unlock()
+
+	middlewares[name] = append(middlewares[name], middleware)
+}
+
+// withMiddleware wraps run in the middleware registered for the named command, if any.
+func withMiddleware(name string, run runFuncC) runFuncC {
+	name = canonicalName(name)
+	middlewaresMutex.RLock()
+	chain := middlewares[name]
+	middlewaresMutex.RUnlock()
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		run = chain[i](run)
+	}
+	return run
+}
+
+func flushMiddlewares() {
+	middlewaresMutex.Lock()
+	defer middlewaresMutex.Unlock()
+
+	middlewares = make(map[string][]func(runFuncC) runFuncC)
+}
@@ -0,0 +1,69 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunMiddleware(t *testing.T) {
+	Convey("with middleware registered for a command", t, func() {
+		defer Flush()
+
+		var mutex sync.Mutex
+		var calls []string
+		record := func(call string) {
+			mutex.Lock()
+			calls = append(calls, call)
+			mutex.Unlock()
+		}
+
+		RegisterRunMiddleware("", func(next runFunc) runFunc {
+			return func() error {
+				record("outer")
+				return next()
+			}
+		})
+		RegisterRunMiddlewareC("", func(next runFuncC) runFuncC {
+			return func(ctx context.Context) error {
+				record("inner")
+				return next(ctx)
+			}
+		})
+
+		Convey("it wraps run in registration order", func() {
+			err := Do("", func() error {
+				record("run")
+				return nil
+			}, nil)
+			So(err, ShouldBeNil)
+			So(calls, ShouldResemble, []string{"outer", "inner", "run"})
+		})
+
+		Convey("it wraps each retry", func() {
+			ConfigureCommand("", CommandConfig{RetryAttempts: 1})
+			Do("", func() error {
+				record("run")
+				return fmt.Errorf("run_error")
+			}, func(err error) error { return nil })
+			So(calls, ShouldResemble, []string{"outer", "inner", "run", "outer", "inner", "run"})
+		})
+
+		Convey("it can change run's error", func() {
+			RegisterRunMiddleware("other", func(next runFunc) runFunc {
+				return func() error {
+					if err := next(); err != nil {
+						return fmt.Errorf("wrapped: %v", err)
+					}
+					return nil
+				}
+			})
+			err := Do("other", func() error { return fmt.Errorf("run_error") }, nil)
+			So(err.Error(), ShouldEqual, "wrapped: run_error")
+			So(calls, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package hystrix
+
+import (
+	"context"
+	"time"
+)
+
+// A CommandOption changes how a single execution of a command behaves, leaving the command's
+// settings, and so its other executions, as they are.
+type CommandOption func(*commandOptions)
+
+// commandOptions holds what the CommandOptions of an execution chose.
+type commandOptions struct {
+	// timeout replaces the command's timeout when it is set. A negative timeout means none.
+	timeout    time.Duration
+	hasTimeout bool
+	// fallback is used when the execution is given no fallback of its own.
+	fallback fallbackFuncC
+	// cacheKey and tags are applied to the execution's context when set.
+	cacheKey string
+	tags     map[string]string
+	// durations is filled in when the execution finishes, for DoWithResultC.
+	durations *commandDurations
+}
+
+// commandDurations are how long an execution's run and fallback took.
+type commandDurations struct {
+	run      time.Duration
+	fallback time.Duration
+}
+
+func newCommandOptions(opts []CommandOption) commandOptions {
+	var o commandOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTimeout gives the execution a timeout of d instead of the command's Timeout, such as for a
+// request known to be heavy, without configuring another command for it. A negative d runs it
+// with no timeout, and zero keeps the command's.
+//
+// The circuit, concurrency limit and every other setting are still the command's. A
+// DynamicTimeout is not applied to the execution, and the override does not change the dynamic
+// timeout of other executions, which is still derived from the latency of recent runs.
+func WithTimeout(d time.Duration) CommandOption {
+	return func(o *commandOptions) {
+		if d == 0 {
+			return
+		}
+		o.timeout = d
+		o.hasTimeout = true
+	}
+}
+
+// WithFallback gives the execution a fallback, for GoWithOptions, which has no fallback
+// parameter. Commands executed with a fallback of their own, such as GoC with a non-nil fallback,
+// ignore it. A nil fallback leaves any registered fallback to be used, as a nil fallback passed
+// to Go does.
+func WithFallback(fallback fallbackFunc) CommandOption {
+	return func(o *commandOptions) {
+		if fallback == nil {
+			o.fallback = nil
+			return
+		}
+		o.fallback = func(ctx context.Context, err error) error {
+			return fallback(err)
+		}
+	}
+}
+
+// WithCallCacheKey caches the result of the execution under key, as if it had been executed with
+// a context returned by WithCacheKey. Like that key, it is ignored unless the execution's context
+// carries a request cache added by WithRequestCache, so it has no effect on GoWithOptions, whose
+// context never does.
+func WithCallCacheKey(key string) CommandOption {
+	return func(o *commandOptions) {
+		o.cacheKey = key
+	}
+}
+
+// WithCallTags labels the execution with tags, as if it had been executed with a context
+// returned by WithTags, on top of the command's Tags and any tags of its context.
+func WithCallTags(tags map[string]string) CommandOption {
+	return func(o *commandOptions) {
+		o.tags = mergeTags(o.tags, tags)
+	}
+}
+
+// withDurations has the execution's run and fallback durations stored in d before its errChan
+// is closed.
+func withDurations(d *commandDurations) CommandOption {
+	return func(o *commandOptions) {
+		o.durations = d
+	}
+}
+
+// apply returns ctx carrying the cache key and tags chosen for the execution, and the fallback
+// to execute it with, which is the one set by WithFallback if fallback is nil.
+func (o commandOptions) apply(ctx context.Context, fallback fallbackFuncC) (context.Context, fallbackFuncC) {
+	if o.cacheKey != "" {
+		ctx = WithCacheKey(ctx, o.cacheKey)
+	}
+	if o.tags != nil {
+		ctx = WithTags(ctx, o.tags)
+	}
+	if fallback == nil {
+		fallback = o.fallback
+	}
+	return ctx, fallback
+}
+
+// timeoutFor returns the timeout of an execution of the named command starting now, or zero if
+// it has none.
+func (o commandOptions) timeoutFor(name string) time.Duration {
+	if !o.hasTimeout {
+		return timeoutForCommand(name)
+	}
+	if o.timeout < 0 {
+		return 0
+	}
+	return o.timeout
+}
@@ -0,0 +1,105 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithTimeout(t *testing.T) {
+	Convey("with a command which times out after 20ms", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 20})
+		slow := func(ctx context.Context) error {
+			select {
+			case <-time.After(60 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		Convey("a call with a longer timeout is allowed to finish", func() {
+			So(DoC(context.Background(), "", slow, nil, WithTimeout(time.Second)), ShouldBeNil)
+
+			Convey("while other calls keep the command's timeout", func() {
+				So(DoC(context.Background(), "", slow, nil), ShouldEqual, ErrTimeout)
+			})
+		})
+
+		Convey("a call with a shorter timeout times out sooner", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 1000})
+			start := time.Now()
+			err := <-GoC(context.Background(), "", slow, nil, WithTimeout(10*time.Millisecond))
+			So(err, ShouldEqual, ErrTimeout)
+			So(time.Since(start), ShouldBeLessThan, 500*time.Millisecond)
+		})
+
+		Convey("a negative timeout runs the call without one", func() {
+			So(DoC(context.Background(), "", slow, nil, WithTimeout(-1)), ShouldBeNil)
+		})
+
+		Convey("a zero timeout keeps the command's", func() {
+			So(DoC(context.Background(), "", slow, nil, WithTimeout(0)), ShouldEqual, ErrTimeout)
+		})
+	})
+}
+
+func TestGoWithOptions(t *testing.T) {
+	Convey("with an event listener registered", t, func() {
+		defer Flush()
+		events := make(chan Event, 10)
+		RegisterEventListener(EventListenerFunc(func(e Event) {
+			events <- e
+		}))
+		failing := func() error {
+			return fmt.Errorf("failure")
+		}
+
+		Convey("WithFallback sets the fallback", func() {
+			var reason error
+			err := <-GoWithOptions("", failing, WithFallback(func(err error) error {
+				reason = err
+				return nil
+			}))
+			So(err, ShouldBeNil)
+			So(reason, ShouldNotBeNil)
+		})
+
+		Convey("without WithFallback the registered fallback is used", func() {
+			RegisterFallback("", func(err error) error {
+				return nil
+			})
+			So(<-GoWithOptions("", failing), ShouldBeNil)
+		})
+
+		Convey("WithTimeout overrides the command's timeout", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 1000})
+			err := <-GoWithOptions("", func() error {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			}, WithTimeout(10*time.Millisecond))
+			So(err, ShouldEqual, ErrTimeout)
+		})
+
+		Convey("WithCallTags labels the execution's events", func() {
+			So(<-GoWithOptions("", func() error { return nil }, WithCallTags(map[string]string{"region": "eu"})), ShouldBeNil)
+			So((<-events).Tags, ShouldResemble, map[string]string{"region": "eu"})
+		})
+
+		Convey("WithCallCacheKey caches the result in the context's request cache", func() {
+			ctx := WithRequestCache(context.Background(), 0)
+			runs := 0
+			run := func(ctx context.Context) error {
+				runs++
+				return nil
+			}
+			So(DoC(ctx, "", run, nil, WithCallCacheKey("key")), ShouldBeNil)
+			So(DoC(ctx, "", run, nil, WithCallCacheKey("key")), ShouldBeNil)
+			So(runs, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PanicHandling says what happens when a run or fallback function panics.
+type PanicHandling int
+
+const (
+	// RecoverAsError recovers the panic, and the function fails with a PanicError. This is the
+	// default.
+	RecoverAsError PanicHandling = iota
+	// Propagate re-panics with the value the function panicked with, which suits development
+	// and tests, where a bug should not be hidden behind a fallback.
+	Propagate
+)
+
+var panicHandling PanicHandling
+var panicHandlingMutex *sync.RWMutex
+
+func init() {
+	panicHandlingMutex = &sync.RWMutex{}
+}
+
+// SetPanicHandling sets what happens when a run or fallback function of any command panics.
+//
+// Under Propagate, Do, DoC, DoWithResult and DoTyped re-panic on the caller's goroutine, so a
+// test sees the panic as if it had called the function itself. Commands executed with Go or
+// GoC, and panics in a command which has already finished, such as by timing out, are
+// re-panicked on the goroutine which ran the function, which ends the program like any other
+// panic nobody recovers. In both cases the command's executor is given back first.
+//
+// A propagated panic is not retried, does not run the next fallback, and is not recorded in the
+// circuit's metrics, so it cannot open the circuit. A fallback which panics after a run which
+// failed still has the run's failure recorded when it is re-panicked on the caller's goroutine.
+func SetPanicHandling(mode PanicHandling) {
+	panicHandlingMutex.Lock()
+	panicHandling = mode
+	panicHandlingMutex.Unlock()
+}
+
+func getPanicHandling() PanicHandling {
+	panicHandlingMutex.RLock()
+	defer panicHandlingMutex.RUnlock()
+
+	return panicHandling
+}
+
+// propagatedPanic carries a panic recovered under Propagate to wherever it is re-panicked. It
+// is never returned to callers.
+type propagatedPanic struct {
+	value interface{}
+}
+
+func (p propagatedPanic) Error() string {
+	return fmt.Sprintf("hystrix: propagating panic: %v", p.value)
+}
+
+// isPropagatedPanic reports whether err is a panic to be propagated.
+func isPropagatedPanic(err error) bool {
+	_, ok := err.(propagatedPanic)
+	return ok
+}
+
+// repanic re-panics err if it is a panic to be propagated.
+func repanic(err error) {
+	if p, ok := err.(propagatedPanic); ok {
+		panic(p.value)
+	}
+}
+
+// propagate finishes a command whose run or fallback panicked under Propagate. Commands whose
+// caller waits on its goroutine, like Do, send the panic on errChan for the caller to re-panic,
+// and other commands re-panic here.
+func (c *command) propagate(p propagatedPanic) {
+	if !c.inline {
+		panic(p.value)
+	}
+
+	c.sendErr(p)
+}
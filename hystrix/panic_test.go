@@ -0,0 +1,92 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPanicHandling(t *testing.T) {
+	Convey("with panics propagated", t, func() {
+		defer Flush()
+		SetPanicHandling(Propagate)
+		defer SetPanicHandling(RecoverAsError)
+
+		// recovered calls f, returning the value it panicked with
+		recovered := func(f func()) (value interface{}) {
+			defer func() {
+				value = recover()
+			}()
+			f()
+			return nil
+		}
+
+		Convey("Do re-panics with the value run panicked with", func() {
+			fallbackCalled := false
+			value := recovered(func() {
+				Do("", func() error {
+					panic("boom")
+				}, func(err error) error {
+					fallbackCalled = true
+					return nil
+				})
+			})
+			So(value, ShouldEqual, "boom")
+			So(fallbackCalled, ShouldBeFalse)
+
+			Convey("without recording it or holding on to its executor", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("").Requests, ShouldEqual, 0)
+				cb, _, _ := GetCircuit("")
+				So(cb.executorPool.ActiveCount(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("DoC re-panics when the command has a timeout to watch", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 1000})
+			value := recovered(func() {
+				DoC(context.Background(), "", func(ctx context.Context) error {
+					panic("boom")
+				}, nil)
+			})
+			So(value, ShouldEqual, "boom")
+		})
+
+		Convey("Do re-panics with the value the fallback panicked with", func() {
+			value := recovered(func() {
+				Do("", func() error {
+					return fmt.Errorf("failure")
+				}, func(err error) error {
+					panic("fallback boom")
+				})
+			})
+			So(value, ShouldEqual, "fallback boom")
+		})
+
+		Convey("a panic is not retried", func() {
+			ConfigureCommand("", CommandConfig{RetryAttempts: 2})
+			runs := 0
+			recovered(func() {
+				Do("", func() error {
+					runs++
+					panic("boom")
+				}, nil)
+			})
+			So(runs, ShouldEqual, 1)
+		})
+	})
+
+	Convey("by default a panic is returned as a PanicError", t, func() {
+		defer Flush()
+
+		err := Do("", func() error {
+			panic("boom")
+		}, nil)
+		panicErr, ok := err.(PanicError)
+		So(ok, ShouldBeTrue)
+		So(panicErr.Value, ShouldEqual, "boom")
+	})
+}
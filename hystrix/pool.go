@@ -1,10 +1,94 @@
 package hystrix
 
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	rejectedHookMutex *sync.RWMutex
+	rejectedHook      func(name string)
+)
+
+func init() {
+	rejectedHookMutex = &sync.RWMutex{}
+}
+
+// OnRejected sets a function to be called with a command's name each time it finds every one of
+// its executors busy, as soon as it does. It suits counting saturation or signalling an
+// autoscaler without listening to every event. A command which then waits for an executor under
+// MaxQueueWait has already been reported, whether or not one frees up in time. Commands
+// configured with UnlimitedConcurrency are never rejected. Setting nil removes it.
+//
+// The function is called synchronously by the executing goroutine on every rejection, so it
+// must be cheap and must not block.
+func OnRejected(hook func(name string)) {
+	rejectedHookMutex.Lock()
+	defer rejectedHookMutex.Unlock()
+
+	rejectedHook = hook
+}
+
+// reportRejected calls the OnRejected function, if any, for the named command.
+func reportRejected(name string) {
+	rejectedHookMutex.RLock()
+	hook := rejectedHook
+	rejectedHookMutex.RUnlock()
+
+	if hook != nil {
+		hook(name)
+	}
+}
+
+func flushRejectedHook() {
+	rejectedHookMutex.Lock()
+	defer rejectedHookMutex.Unlock()
+
+	rejectedHook = nil
+}
+
 type executorPool struct {
+	// active is how many executions hold the unlimited ticket of a pool whose Max is
+	// UnlimitedConcurrency. It is only used by such pools, which have no Tickets.
+	active int32
+	// queued is how many commands are waiting for a ticket under MaxQueueWait.
+	queued int32
+
 	Name    string
 	Metrics *poolMetrics
 	Max     int
 	Tickets chan *struct{}
+
+	// FallbackTickets limits concurrent fallbacks. It is nil when fallbacks are unlimited.
+	FallbackTickets chan *struct{}
+
+	// adaptive moves the concurrency limit below Max. It is nil unless AdaptiveConcurrency is set.
+	adaptive *adaptiveLimiter
+	// fair queues the commands waiting for a ticket. It is nil unless FairQueue is set.
+	fair *fairQueue
+}
+
+// groupPools holds the executor pools shared by the commands of each group. It is guarded by
+// circuitBreakersMutex, since pools are only created along with circuits.
+var groupPools = make(map[string]*executorPool)
+
+// executorPoolForCommand returns the executor pool for a new circuit of the named command. Commands
+// in a group share the group's pool, which is created by the first of them. The caller must hold
+// circuitBreakersMutex for writing.
+func executorPoolForCommand(name string) *executorPool {
+	group := getSettings(name).Group
+	if group == "" {
+		return newExecutorPool(name)
+	}
+
+	pool, ok := groupPools[group]
+	if !ok {
+		pool = newExecutorPool(group)
+		groupPools[group] = pool
+	}
+	return pool
 }
 
 func newExecutorPool(name string) *executorPool {
@@ -13,25 +97,186 @@ func newExecutorPool(name string) *executorPool {
 	p.Metrics = newPoolMetrics(name)
 	p.Max = getSettings(name).MaxConcurrentRequests
 
-	p.Tickets = make(chan *struct{}, p.Max)
-	for i := 0; i < p.Max; i++ {
-		p.Tickets <- &struct{}{}
+	if p.Max != UnlimitedConcurrency {
+		p.Tickets = make(chan *struct{}, p.Max)
+		for i := 0; i < p.Max; i++ {
+			p.Tickets <- &struct{}{}
+		}
+		if getSettings(name).AdaptiveConcurrency {
+			p.adaptive = newAdaptiveLimiter(p.Max)
+		}
+		if getSettings(name).FairQueue {
+			p.fair = &fairQueue{}
+		}
+	}
+
+	if fallbackMax := getSettings(name).FallbackMaxConcurrent; fallbackMax > 0 {
+		p.FallbackTickets = make(chan *struct{}, fallbackMax)
+		for i := 0; i < fallbackMax; i++ {
+			p.FallbackTickets <- &struct{}{}
+		}
 	}
 
 	return p
 }
 
+// ConcurrencyInUse returns how many executions of the named command currently hold one of its
+// executors, including those of other commands in its group. An execution gives up its
+// executor when it finishes, panics or times out. Commands which have never been executed
+// report zero.
+func ConcurrencyInUse(name string) int {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		return 0
+	}
+
+	return cb.executorPool.ActiveCount()
+}
+
+// MaxConcurrency returns how many executions of the named command may hold an executor at once.
+// For commands in a group, this is shared with the rest of the group. With AdaptiveConcurrency,
+// it is the limit currently chosen, which is at most MaxConcurrentRequests. Commands configured
+// with UnlimitedConcurrency report UnlimitedConcurrency.
+func MaxConcurrency(name string) int {
+	cb, ok := lookupCircuit(name)
+	if !ok {
+		if group := getSettings(name).Group; group != "" {
+			return getSettings(group).MaxConcurrentRequests
+		}
+		return getSettings(name).MaxConcurrentRequests
+	}
+
+	return cb.executorPool.limit()
+}
+
+// unlimitedTicket is handed to every execution of a pool whose Max is UnlimitedConcurrency.
+var unlimitedTicket = &struct{}{}
+
 func (p *executorPool) Return(ticket *struct{}) {
 	if ticket == nil {
 		return
 	}
+	if p.Max == UnlimitedConcurrency {
+		atomic.AddInt32(&p.active, -1)
+	}
 
-	p.Metrics.Updates <- poolMetricsUpdate{
+	select {
+	case p.Metrics.Updates <- poolMetricsUpdate{
 		activeCount: p.ActiveCount(),
+	}:
+	case <-p.Metrics.done:
+		// the monitor has been stopped by Shutdown or Flush, so nothing will receive the update
+	}
+	if p.Max == UnlimitedConcurrency || p.park() {
+		return
+	}
+	p.put(ticket)
+}
+
+// put makes a ticket available, giving it to the longest waiting command of a fair pool.
+func (p *executorPool) put(ticket *struct{}) {
+	if p.fair != nil {
+		p.fair.put(p.Tickets, ticket)
+		return
 	}
 	p.Tickets <- ticket
 }
 
+// acquire takes a ticket for the named command, waiting up to maxWait for one to be returned. It
+// returns nil if no ticket became free in time, or if ctx is done first.
+func (p *executorPool) acquire(ctx context.Context, name string, maxWait time.Duration) *struct{} {
+	var ticket *struct{}
+	if p.Max == UnlimitedConcurrency {
+		atomic.AddInt32(&p.active, 1)
+		ticket = unlimitedTicket
+	} else if p.fair != nil {
+		ticket = p.acquireFair(ctx, name, maxWait)
+	} else {
+		ticket = p.take(ctx, name, maxWait)
+	}
+
+	if ticket != nil {
+		p.Metrics.sampleConcurrency(p.ActiveCount())
+	}
+	return ticket
+}
+
+// take takes a ticket for acquire, from whichever waiting command the runtime picks.
+func (p *executorPool) take(ctx context.Context, name string, maxWait time.Duration) *struct{} {
+
+	select {
+	case ticket := <-p.Tickets:
+		return ticket
+	default:
+		reportRejected(name)
+	}
+
+	if maxWait <= 0 {
+		p.Metrics.rejected()
+		return nil
+	}
+
+	atomic.AddInt32(&p.queued, 1)
+	defer atomic.AddInt32(&p.queued, -1)
+	timer := getClock().NewTimer(maxWait)
+	defer timer.Stop()
+
+	select {
+	case ticket := <-p.Tickets:
+		return ticket
+	case <-timer.C():
+		p.Metrics.queueTimedOut()
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// QueueDepth returns how many commands are waiting for a ticket under MaxQueueWait.
+func (p *executorPool) QueueDepth() int {
+	return int(atomic.LoadInt32(&p.queued))
+}
+
+// queue summarizes the pool's queue at now.
+func (p *executorPool) queue(now time.Time) QueueMetrics {
+	return QueueMetrics{
+		Depth:    p.QueueDepth(),
+		Timeouts: p.Metrics.queueTimeouts(now),
+	}
+}
+
+// acquireFallback takes a ticket to run a fallback, reporting false if none are available.
+// Unlimited pools hand out nil tickets.
+func (p *executorPool) acquireFallback() (*struct{}, bool) {
+	if p.FallbackTickets == nil {
+		return nil, true
+	}
+
+	select {
+	case ticket := <-p.FallbackTickets:
+		return ticket, true
+	default:
+		return nil, false
+	}
+}
+
+func (p *executorPool) returnFallback(ticket *struct{}) {
+	if ticket == nil {
+		return
+	}
+
+	p.FallbackTickets <- ticket
+}
+
 func (p *executorPool) ActiveCount() int {
+	if p.Max == UnlimitedConcurrency {
+		return int(atomic.LoadInt32(&p.active))
+	}
+	if p.adaptive != nil {
+		p.adaptive.mutex.Lock()
+		defer p.adaptive.mutex.Unlock()
+
+		return p.Max - len(p.Tickets) - p.adaptive.parked
+	}
 	return p.Max - len(p.Tickets)
 }
@@ -2,6 +2,7 @@ package hystrix
 
 import (
 	"sync"
+	"time"
 
 	"github.com/afex/hystrix-go/hystrix/rolling"
 )
@@ -13,6 +14,15 @@ type poolMetrics struct {
 	Name              string
 	MaxActiveRequests *rolling.Number
 	Executed          *rolling.Number
+	// Concurrency counts how many executors were in use each time one was taken.
+	Concurrency *rolling.Histogram
+	// QueueTimeouts counts the commands which gave up waiting for an executor under MaxQueueWait.
+	QueueTimeouts *rolling.Number
+	// Rejections counts the commands which got no executor, including those in QueueTimeouts.
+	Rejections *rolling.Number
+
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 type poolMetricsUpdate struct {
@@ -23,6 +33,7 @@ func newPoolMetrics(name string) *poolMetrics {
 	m := &poolMetrics{}
 	m.Name = name
 	m.Updates = make(chan poolMetricsUpdate)
+	m.done = make(chan struct{})
 	m.Mutex = &sync.RWMutex{}
 
 	m.Reset()
@@ -36,12 +47,80 @@ func (m *poolMetrics) Reset() {
 	m.Mutex.Lock()
 	defer m.Mutex.Unlock()
 
-	m.MaxActiveRequests = rolling.NewNumber()
-	m.Executed = rolling.NewNumber()
+	settings := getSettings(m.Name)
+	buckets, bucketDuration := rollingBuckets(settings.RollingWindow, settings.RollingBuckets)
+	m.MaxActiveRequests = rolling.NewNumberWithWindow(buckets, bucketDuration)
+	m.Executed = rolling.NewNumberWithWindow(buckets, bucketDuration)
+	m.Concurrency = rolling.NewHistogramWithWindow(buckets, bucketDuration)
+	m.QueueTimeouts = rolling.NewNumberWithWindow(buckets, bucketDuration)
+	m.Rejections = rolling.NewNumberWithWindow(buckets, bucketDuration)
+}
+
+// queueTimedOut records a command which waited MaxQueueWait without getting an executor.
+func (m *poolMetrics) queueTimedOut() {
+	m.Mutex.RLock()
+	defer m.Mutex.RUnlock()
+
+	m.QueueTimeouts.Increment(1)
+	m.Rejections.Increment(1)
+}
+
+// rejected records a command which found every executor busy and did not wait for one.
+func (m *poolMetrics) rejected() {
+	m.Mutex.RLock()
+	defer m.Mutex.RUnlock()
+
+	m.Rejections.Increment(1)
+}
+
+// rejections returns how many commands got no executor over the rolling window.
+func (m *poolMetrics) rejections(now time.Time) uint64 {
+	m.Mutex.RLock()
+	defer m.Mutex.RUnlock()
+
+	return uint64(m.Rejections.Sum(now))
+}
+
+// queueTimeouts returns how many commands gave up waiting over the rolling window.
+func (m *poolMetrics) queueTimeouts(now time.Time) uint64 {
+	m.Mutex.RLock()
+	defer m.Mutex.RUnlock()
+
+	return uint64(m.QueueTimeouts.Sum(now))
+}
+
+// sampleConcurrency records how many executors are in use as one is taken, including it.
+func (m *poolMetrics) sampleConcurrency(inUse int) {
+	m.Mutex.RLock()
+	defer m.Mutex.RUnlock()
+
+	m.Concurrency.Add(inUse)
+}
+
+// concurrency summarizes the samples taken over the rolling window.
+func (m *poolMetrics) concurrency(now time.Time) ConcurrencyHistogram {
+	m.Mutex.RLock()
+	counts := m.Concurrency.Counts(now)
+	m.Mutex.RUnlock()
+
+	h := ConcurrencyHistogram{Counts: counts}
+	for n, count := range counts {
+		if count > 0 {
+			h.Max = n
+		}
+	}
+	return h
 }
 
 func (m *poolMetrics) Monitor() {
-	for u := range m.Updates {
+	for {
+		var u poolMetricsUpdate
+		select {
+		case u = <-m.Updates:
+		case <-m.done:
+			return
+		}
+
 		m.Mutex.RLock()
 
 		m.Executed.Increment(1)
@@ -50,3 +129,11 @@ func (m *poolMetrics) Monitor() {
 		m.Mutex.RUnlock()
 	}
 }
+
+// stop ends the Monitor goroutine. Senders on Updates must also select on done, since nothing
+// receives their updates afterwards.
+func (m *poolMetrics) stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+}
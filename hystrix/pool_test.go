@@ -1,6 +1,8 @@
 package hystrix
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,3 +46,294 @@ func TestActiveCount(t *testing.T) {
 		})
 	})
 }
+
+func TestConcurrencyInUse(t *testing.T) {
+	Convey("given a command limited to 5 concurrent requests", t, func() {
+		defer Flush()
+		ConfigureCommand("pool", CommandConfig{MaxConcurrentRequests: 5})
+
+		Convey("before it has run, nothing is in use", func() {
+			So(ConcurrencyInUse("pool"), ShouldEqual, 0)
+			So(MaxConcurrency("pool"), ShouldEqual, 5)
+		})
+
+		Convey("while 2 executions are running", func() {
+			release := make(chan struct{})
+			started := make(chan struct{}, 2)
+			var errChans []chan error
+			for i := 0; i < 2; i++ {
+				errChans = append(errChans, Go("pool", func() error {
+					started <- struct{}{}
+					<-release
+					return nil
+				}, nil))
+			}
+			<-started
+			<-started
+
+			Convey("2 executors are in use until they finish", func() {
+				So(ConcurrencyInUse("pool"), ShouldEqual, 2)
+				So(MaxConcurrency("pool"), ShouldEqual, 5)
+
+				close(release)
+				for _, errChan := range errChans {
+					<-errChan
+				}
+				So(ConcurrencyInUse("pool"), ShouldEqual, 0)
+			})
+		})
+
+		Convey("an execution which panics gives its executor back", func() {
+			<-Go("pool", func() error {
+				panic("boom")
+			}, nil)
+			So(ConcurrencyInUse("pool"), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestUnlimitedConcurrency(t *testing.T) {
+	Convey("given a command with unlimited concurrency", t, func() {
+		defer Flush()
+		So(ConfigureCommand("pool", CommandConfig{MaxConcurrentRequests: UnlimitedConcurrency}), ShouldBeNil)
+
+		Convey("far more executions than the default limit run at once", func() {
+			release := make(chan struct{})
+			started := make(chan struct{}, 50)
+			var errChans []chan error
+			for i := 0; i < 50; i++ {
+				errChans = append(errChans, Go("pool", func() error {
+					started <- struct{}{}
+					<-release
+					return nil
+				}, nil))
+			}
+			for i := 0; i < 50; i++ {
+				<-started
+			}
+			So(ConcurrencyInUse("pool"), ShouldEqual, 50)
+			So(MaxConcurrency("pool"), ShouldEqual, UnlimitedConcurrency)
+
+			close(release)
+			for _, errChan := range errChans {
+				So(<-errChan, ShouldBeNil)
+			}
+			So(ConcurrencyInUse("pool"), ShouldEqual, 0)
+
+			Convey("and none were rejected", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("pool").Successes, ShouldEqual, 50)
+				So(GetMetrics("pool").Rejects, ShouldEqual, 0)
+			})
+		})
+
+		Convey("the circuit and timeout still apply", func() {
+			ConfigureCommand("pool", CommandConfig{MaxConcurrentRequests: UnlimitedConcurrency, Timeout: 10})
+			So(Do("pool", func() error {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			}, nil), ShouldEqual, ErrTimeout)
+
+			ForceOpen("pool")
+			So(Do("pool", func() error { return nil }, nil), ShouldEqual, ErrCircuitOpen)
+		})
+	})
+}
+
+func TestConcurrencyHistogram(t *testing.T) {
+	Convey("given a command which ran 3 executions at once, then one more", t, func() {
+		defer Flush()
+		ConfigureCommand("pool", CommandConfig{MaxConcurrentRequests: 5})
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		var errChans []chan error
+		for i := 0; i < 3; i++ {
+			errChans = append(errChans, Go("pool", func() error {
+				started <- struct{}{}
+				<-release
+				return nil
+			}, nil))
+			// start them one at a time, so each sees the ones before it
+			<-started
+		}
+		close(release)
+		for _, errChan := range errChans {
+			<-errChan
+		}
+		Do("pool", func() error { return nil }, nil)
+
+		Convey("GetMetrics reports how many executors each execution found in use", func() {
+			So(GetMetrics("pool").Concurrency, ShouldResemble, ConcurrencyHistogram{
+				Max:    3,
+				Counts: []uint64{0, 2, 1, 1},
+			})
+		})
+	})
+}
+
+func TestConcurrentFirstUse(t *testing.T) {
+	Convey("when 100 goroutines run a brand new command at once", t, func() {
+		defer Flush()
+		ConfigureCommand("first-use", CommandConfig{MaxConcurrentRequests: 10})
+
+		release := make(chan struct{})
+		var running int32
+		results := make(chan error, 100)
+		for i := 0; i < 100; i++ {
+			go func() {
+				results <- <-Go("first-use", func() error {
+					atomic.AddInt32(&running, 1)
+					<-release
+					return nil
+				}, nil)
+			}()
+		}
+
+		// the running commands cannot finish until they are released, so the first
+		// 90 results are all the commands which did not get an executor
+		var early []error
+		for i := 0; i < 90; i++ {
+			early = append(early, <-results)
+		}
+		close(release)
+
+		Convey("only one executor pool enforces the limit", func() {
+			for _, err := range early {
+				So(err, ShouldResemble, ErrMaxConcurrency)
+			}
+			for i := 0; i < 10; i++ {
+				So(<-results, ShouldBeNil)
+			}
+			So(atomic.LoadInt32(&running), ShouldEqual, 10)
+		})
+	})
+}
+
+func TestGroupPool(t *testing.T) {
+	Convey("with two commands in a group limited to one executor", t, func() {
+		defer Flush()
+		ConfigureCommand("users", CommandConfig{MaxConcurrentRequests: 1})
+		ConfigureCommand("get_user", CommandConfig{Group: "users", MaxConcurrentRequests: 10})
+		ConfigureCommand("list_users", CommandConfig{Group: "users", MaxConcurrentRequests: 10})
+		ConfigureCommand("other", CommandConfig{MaxConcurrentRequests: 1})
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		errChan := Go("get_user", func() error {
+			close(started)
+			<-release
+			return nil
+		}, nil)
+		defer func() {
+			close(release)
+			<-errChan
+		}()
+		<-started
+
+		Convey("the other command in the group is rejected", func() {
+			So(MaxConcurrency("list_users"), ShouldEqual, 1)
+			So(Do("list_users", func() error { return nil }, nil), ShouldResemble, ErrMaxConcurrency)
+			So(ConcurrencyInUse("list_users"), ShouldEqual, 1)
+		})
+
+		Convey("commands outside the group are not affected", func() {
+			So(Do("other", func() error { return nil }, nil), ShouldBeNil)
+		})
+
+		Convey("each command keeps its own circuit", func() {
+			getUser, _, _ := GetCircuit("get_user")
+			listUsers, _, _ := GetCircuit("list_users")
+			So(getUser, ShouldNotEqual, listUsers)
+			So(getUser.executorPool, ShouldEqual, listUsers.executorPool)
+		})
+	})
+}
+
+func TestOnRejected(t *testing.T) {
+	Convey("given a command with one executor and a rejection hook", t, func() {
+		defer Flush()
+		ConfigureCommand("pool", CommandConfig{MaxConcurrentRequests: 1})
+		var mutex sync.Mutex
+		var rejected []string
+		OnRejected(func(name string) {
+			mutex.Lock()
+			rejected = append(rejected, name)
+			mutex.Unlock()
+		})
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		errChan := Go("pool", func() error {
+			close(started)
+			<-release
+			return nil
+		}, nil)
+		<-started
+
+		Convey("an execution which finds the executor busy is reported before its fallback runs", func() {
+			var reportedFirst bool
+			err := Do("pool", func() error {
+				return nil
+			}, func(err error) error {
+				mutex.Lock()
+				reportedFirst = len(rejected) == 1
+				mutex.Unlock()
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(reportedFirst, ShouldBeTrue)
+			So(rejected, ShouldResemble, []string{"pool"})
+
+			close(release)
+			So(<-errChan, ShouldBeNil)
+		})
+
+		Convey("an execution which gets the executor is not reported", func() {
+			close(release)
+			So(<-errChan, ShouldBeNil)
+			So(Do("pool", func() error { return nil }, nil), ShouldBeNil)
+			So(rejected, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestQueueMetrics(t *testing.T) {
+	Convey("given a command with one executor which queues for 50ms", t, func() {
+		defer Flush()
+		ConfigureCommand("queue", CommandConfig{MaxConcurrentRequests: 1, MaxQueueWait: 50})
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		holder := Go("queue", func() error {
+			close(started)
+			<-release
+			return nil
+		}, nil)
+		<-started
+
+		Convey("a command waiting for the executor is counted in the depth", func() {
+			waiter := Go("queue", func() error { return nil }, nil)
+			for GetMetrics("queue").Queue.Depth == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			So(GetMetrics("queue").Queue.Depth, ShouldEqual, 1)
+
+			close(release)
+			So(<-holder, ShouldBeNil)
+			So(<-waiter, ShouldBeNil)
+			So(GetMetrics("queue").Queue, ShouldResemble, QueueMetrics{})
+		})
+
+		Convey("a command which gives up waiting is counted as a queue timeout", func() {
+			So(Do("queue", func() error { return nil }, nil), ShouldEqual, ErrMaxConcurrency)
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("queue").Queue.Timeouts, ShouldEqual, 1)
+			So(GetMetrics("queue").Queue.Depth, ShouldEqual, 0)
+			So(GetMetrics("queue").Rejects, ShouldEqual, 1)
+
+			close(release)
+			So(<-holder, ShouldBeNil)
+		})
+	})
+}
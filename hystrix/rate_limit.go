@@ -0,0 +1,43 @@
+package hystrix
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket holding up to a second's worth of a command's RateLimit. It
+// reads the limit on every call, so reconfiguring it takes effect straight away.
+type rateLimiter struct {
+	mutex  sync.Mutex
+	tokens float64
+	// last is when tokens was last refilled, or zero if the bucket has never been used.
+	last time.Time
+}
+
+// allow takes a token from the bucket, reporting false if none is left. A rate of zero or
+// less always allows.
+func (l *rateLimiter) allow(rate int) bool {
+	if rate <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := getClock().Now()
+	if l.last.IsZero() {
+		l.tokens = float64(rate)
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+		if l.tokens > float64(rate) {
+			l.tokens = float64(rate)
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,71 @@
+package hystrix
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimit(t *testing.T) {
+	Convey("with a command limited to 5 executions a second", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RateLimit: 5, Timeout: NoTimeout})
+
+		run := func() error { return nil }
+		for i := 0; i < 5; i++ {
+			So(Do("", run, nil), ShouldBeNil)
+		}
+
+		Convey("the next execution goes to its fallback without running", func() {
+			ran := false
+			var fallbackErr error
+			err := Do("", func() error {
+				ran = true
+				return nil
+			}, func(err error) error {
+				fallbackErr = err
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(ran, ShouldBeFalse)
+			So(fallbackErr, ShouldResemble, &CommandError{Kind: KindRateLimited, Err: ErrRateLimited})
+
+			So(Do("", run, nil), ShouldResemble, ErrRateLimited)
+		})
+
+		Convey("executions are counted as rate limited but not against its health", func() {
+			So(Do("", run, nil), ShouldResemble, ErrRateLimited)
+			time.Sleep(10 * time.Millisecond)
+
+			So(GetMetrics("").RateLimited, ShouldEqual, 1)
+			So(GetMetrics("").Errors, ShouldEqual, 0)
+			So(GetHealth("").Total, ShouldEqual, 5)
+		})
+
+		Convey("tokens are refilled as the clock moves on", func() {
+			clock.Advance(200 * time.Millisecond)
+			So(Do("", run, nil), ShouldBeNil)
+			So(Do("", run, nil), ShouldResemble, ErrRateLimited)
+		})
+
+		Convey("at most a second's worth build up while idle", func() {
+			clock.Advance(time.Minute)
+			for i := 0; i < 5; i++ {
+				So(Do("", run, nil), ShouldBeNil)
+			}
+			So(Do("", run, nil), ShouldResemble, ErrRateLimited)
+		})
+	})
+
+	Convey("a command without a rate limit is never rate limited", t, func() {
+		defer Flush()
+		So(getSettings("").RateLimit, ShouldEqual, 0)
+		for i := 0; i < 100; i++ {
+			So(Do("", func() error { return nil }, nil), ShouldBeNil)
+		}
+	})
+}
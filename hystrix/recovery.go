@@ -0,0 +1,118 @@
+package hystrix
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthRecovery describes a closed circuit whose rolling error percentage dropped back below its
+// ErrorPercentThreshold after reaching it, without the circuit opening. This happens while the
+// circuit is warming up or has seen fewer than RequestVolumeThreshold requests.
+type HealthRecovery struct {
+	Name string
+	// Time is when the error percentage was seen below the threshold.
+	Time time.Time
+	// PeakErrorPercent is the highest error percentage seen since it reached the threshold, and
+	// ErrorPercent the one it dropped back to.
+	PeakErrorPercent int
+	ErrorPercent     int
+}
+
+var (
+	recoveryHooksMutex *sync.RWMutex
+	recoveryHooks      map[string][]func(HealthRecovery)
+)
+
+func init() {
+	recoveryHooksMutex = &sync.RWMutex{}
+	recoveryHooks = make(map[string][]func(HealthRecovery))
+}
+
+// RegisterRecoveryHook registers a function to be called each time the named command's error
+// percentage recovers while its circuit is closed. A "health-recovered" event is sent to event
+// listeners at the same time. Recoveries are reported at most once per RollingWindow, so an error
+// percentage hovering around the threshold does not report one on every request. A circuit
+// which opened reports its recovery as a StateChange instead.
+//
+// Like state change hooks, recovery hooks are called synchronously by the goroutine which saw the
+// recovery, without holding any of the circuit's locks.
+func RegisterRecoveryHook(name string, hook func(HealthRecovery)) {
+	name = canonicalName(name)
+	recoveryHooksMutex.Lock()
+	defer recoveryHooksMutex.Unlock()
+
+	recoveryHooks[name] = append(recoveryHooks[name], hook)
+}
+
+func flushRecoveryHooks() {
+	recoveryHooksMutex.Lock()
+	defer recoveryHooksMutex.Unlock()
+
+	recoveryHooks = make(map[string][]func(HealthRecovery))
+}
+
+// recoveryWatch follows whether a closed circuit's error percentage is at or above its threshold.
+type recoveryWatch struct {
+	mutex    sync.Mutex
+	elevated bool
+	peak     int
+	// last is when a recovery was last reported, or zero if none has been.
+	last time.Time
+}
+
+// observe records the circuit's error percentage, reporting whether it has recovered along with
+// the peak it recovered from. Once elevated, the circuit stays so until a recovery is reported,
+// which is at least interval after the last one.
+func (w *recoveryWatch) observe(errorPercent, threshold int, now time.Time, interval time.Duration) (int, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if errorPercent >= threshold {
+		if !w.elevated || errorPercent > w.peak {
+			w.peak = errorPercent
+		}
+		w.elevated = true
+		return 0, false
+	}
+	if !w.elevated || (!w.last.IsZero() && now.Sub(w.last) < interval) {
+		return 0, false
+	}
+
+	w.elevated = false
+	w.last = now
+	return w.peak, true
+}
+
+// reset forgets an elevated error percentage, once the circuit has opened because of it.
+func (w *recoveryWatch) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.elevated = false
+}
+
+// watchRecovery reports a recovery of the circuit's error percentage, if there has been one. It
+// must not be called while holding the circuit's mutex.
+func (circuit *CircuitBreaker) watchRecovery(errorPercent int, settings *Settings, now time.Time) {
+	peak, recovered := circuit.recovery.observe(errorPercent, settings.ErrorPercentThreshold, now, settings.RollingWindow)
+	if !recovered {
+		return
+	}
+
+	log.Infof("hystrix-go: error percentage of %v recovered from %v%% to %v%%", circuit.Name, peak, errorPercent)
+	emitEvent(Event{Name: circuit.Name, Type: "health-recovered", Tags: settings.Tags})
+
+	recoveryHooksMutex.RLock()
+	hooks := recoveryHooks[circuit.Name]
+	recoveryHooksMutex.RUnlock()
+
+	recovery := HealthRecovery{
+		Name:             circuit.Name,
+		Time:             now,
+		PeakErrorPercent: peak,
+		ErrorPercent:     errorPercent,
+	}
+	for _, hook := range hooks {
+		hook(recovery)
+	}
+}
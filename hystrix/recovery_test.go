@@ -0,0 +1,78 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecoveryHook(t *testing.T) {
+	Convey("with a closed circuit whose error percentage reached the threshold", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 100, RollingWindow: 1000})
+
+		mutex := &sync.Mutex{}
+		var recoveries []HealthRecovery
+		RegisterRecoveryHook("", func(r HealthRecovery) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			recoveries = append(recoveries, r)
+		})
+		events := make(chan Event, 10)
+		RegisterEventListener(EventListenerFunc(func(e Event) {
+			if e.Type == "health-recovered" {
+				events <- e
+			}
+		}))
+		recovered := func() []HealthRecovery {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return append([]HealthRecovery(nil), recoveries...)
+		}
+
+		run := func(err error) {
+			Do("", func() error { return err }, nil)
+			time.Sleep(10 * time.Millisecond)
+		}
+		run(fmt.Errorf("failure"))
+		run(fmt.Errorf("failure"))
+		So(IsOpen(""), ShouldBeFalse)
+
+		Convey("nothing is reported while it stays elevated", func() {
+			So(recovered(), ShouldBeEmpty)
+		})
+
+		Convey("dropping below the threshold reports a recovery", func() {
+			clock.Advance(2 * time.Second)
+			So(IsOpen(""), ShouldBeFalse)
+
+			So(recovered(), ShouldResemble, []HealthRecovery{{
+				Name:             "",
+				Time:             clock.Now(),
+				PeakErrorPercent: 100,
+				ErrorPercent:     0,
+			}})
+			So((<-events).Name, ShouldEqual, "")
+
+			Convey("another recovery within the rolling window is held back", func() {
+				run(fmt.Errorf("failure"))
+				run(nil)
+				run(nil)
+				IsOpen("")
+				So(recovered(), ShouldHaveLength, 1)
+
+				Convey("until the rolling window has passed", func() {
+					clock.Advance(time.Second)
+					IsOpen("")
+					So(recovered(), ShouldHaveLength, 2)
+				})
+			})
+		})
+	})
+}
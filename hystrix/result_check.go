@@ -0,0 +1,51 @@
+package hystrix
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUnsuccessfulResult is what run of a typed command fails with when it returned a value
+// which the check registered with RegisterResultCheck rejected, such as a response whose body
+// reports an error. It counts against the circuit like any other failure, unless IsFailure or
+// IgnoredErrors say otherwise.
+var ErrUnsuccessfulResult = errors.New("hystrix: unsuccessful result")
+
+var (
+	resultChecksMutex *sync.RWMutex
+	// resultChecks holds a func(T) bool for each command with a registered check.
+	resultChecks map[string]interface{}
+)
+
+func init() {
+	resultChecksMutex = &sync.RWMutex{}
+	resultChecks = make(map[string]interface{})
+}
+
+// resultCheck returns the check registered for the named command, or nil.
+func resultCheck(name string) interface{} {
+	name = canonicalName(name)
+	resultChecksMutex.RLock()
+	defer resultChecksMutex.RUnlock()
+
+	return resultChecks[name]
+}
+
+func setResultCheck(name string, check interface{}) {
+	name = canonicalName(name)
+	resultChecksMutex.Lock()
+	defer resultChecksMutex.Unlock()
+
+	if check == nil {
+		delete(resultChecks, name)
+		return
+	}
+	resultChecks[name] = check
+}
+
+func flushResultChecks() {
+	resultChecksMutex.Lock()
+	defer resultChecksMutex.Unlock()
+
+	resultChecks = make(map[string]interface{})
+}
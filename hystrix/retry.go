@@ -0,0 +1,64 @@
+package hystrix
+
+import (
+	"context"
+	"time"
+)
+
+// callRunWithRetries calls run like callRun, retrying failures as configured for the named
+// command. ctx is the context passed to run, which is canceled once the command times out.
+func callRunWithRetries(ctx context.Context, name string, run runFuncC) error {
+	settings := getSettings(name)
+	err := callRun(ctx, run)
+
+	backoff := settings.RetryBackoff
+	for attempt := 1; attempt <= settings.RetryAttempts && err != nil; attempt++ {
+		if isPropagatedPanic(err) || !isFailure(name, err) || !isRetryable(settings, err) {
+			return err
+		}
+		if !waitForRetry(ctx, backoff) {
+			// the command timed out or was canceled, so there is no time left to retry
+			return err
+		}
+		backoff *= 2
+
+		emitEvent(Event{Name: name, Type: "retry", Err: err, Tags: tagsFor(ctx, name)})
+		err = callRun(ctx, run)
+	}
+
+	return err
+}
+
+// waitForRetry waits for backoff to pass, reporting false if ctx is done first.
+func waitForRetry(ctx context.Context, backoff time.Duration) bool {
+	if backoff <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := getClock().NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isRetryable reports whether a failure may be retried. A classifier which panics is treated as
+// having rejected the error.
+func isRetryable(settings *Settings, err error) (retryable bool) {
+	if settings.IsRetryable == nil {
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("hystrix-go: recovered from panic in IsRetryable: %v", r)
+			retryable = false
+		}
+	}()
+
+	return settings.IsRetryable(err)
+}
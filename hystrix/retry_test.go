@@ -0,0 +1,89 @@
+package hystrix
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetry(t *testing.T) {
+	Convey("with a command configured to retry twice", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RetryAttempts: 2, RetryBackoff: 1})
+
+		var runs int32
+		failTimes := func(n int32) func() error {
+			return func() error {
+				if atomic.AddInt32(&runs, 1) <= n {
+					return fmt.Errorf("run_error")
+				}
+				return nil
+			}
+		}
+
+		Convey("a run which succeeds on a retry succeeds without the fallback", func() {
+			fallbackCalled := false
+			err := Do("", failTimes(2), func(err error) error {
+				fallbackCalled = true
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(fallbackCalled, ShouldBeFalse)
+			So(atomic.LoadInt32(&runs), ShouldEqual, 3)
+
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Successes, ShouldEqual, 1)
+			So(GetMetrics("").Failures, ShouldEqual, 0)
+		})
+
+		Convey("a run which keeps failing counts as one failure once retries are exhausted", func() {
+			err := Do("", failTimes(10), nil)
+			So(err.Error(), ShouldEqual, "run_error")
+			So(atomic.LoadInt32(&runs), ShouldEqual, 3)
+
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 1)
+		})
+	})
+
+	Convey("with a command which only retries some errors", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{
+			RetryAttempts: 2,
+			IsRetryable: func(err error) bool {
+				return err.Error() == "retryable"
+			},
+		})
+
+		var runs int32
+		err := Do("", func() error {
+			atomic.AddInt32(&runs, 1)
+			return fmt.Errorf("permanent")
+		}, nil)
+
+		Convey("other errors are not retried", func() {
+			So(err.Error(), ShouldEqual, "permanent")
+			So(atomic.LoadInt32(&runs), ShouldEqual, 1)
+		})
+	})
+
+	Convey("with a command whose backoff is longer than its timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: 20, RetryAttempts: 2, RetryBackoff: 1000})
+
+		var runs int32
+		err := Do("", func() error {
+			atomic.AddInt32(&runs, 1)
+			return fmt.Errorf("run_error")
+		}, nil)
+
+		Convey("the command times out without retrying", func() {
+			So(err, ShouldResemble, ErrTimeout)
+			time.Sleep(10 * time.Millisecond)
+			So(atomic.LoadInt32(&runs), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package rolling
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type nowFunc func() time.Time
+
+var clock atomic.Value
+
+func init() {
+	clock.Store(nowFunc(time.Now))
+}
+
+// SetNow replaces the function used to tell the current time when recording values into
+// buckets. It is intended for tests which need to control time; passing nil restores time.Now.
+func SetNow(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	clock.Store(nowFunc(now))
+}
+
+func currentTime() time.Time {
+	return clock.Load().(nowFunc)()
+}
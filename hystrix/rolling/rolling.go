@@ -2,31 +2,84 @@ package rolling
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Number tracks a numberBucket over a bounded number of
-// time buckets. Currently the buckets are one second long and only the last 10 seconds are kept.
+// time buckets. By default the buckets are one second long and only the last 10 seconds are kept.
 type Number struct {
 	Buckets map[int64]*numberBucket
 	Mutex   *sync.RWMutex
+
+	numBuckets     int64
+	bucketDuration time.Duration
+
+	// ring holds the buckets of a Number created by NewAtomicNumberWithWindow, which keeps them
+	// here instead of in Buckets.
+	ring []atomicBucket
 }
 
 type numberBucket struct {
 	Value float64
 }
 
+// atomicBucket is a bucket of an atomic Number. It is reused for every numBuckets'th
+// bucketKey, and key says which one it currently holds.
+type atomicBucket struct {
+	key   int64
+	value int64
+}
+
 // NewNumber initializes a RollingNumber struct.
 func NewNumber() *Number {
+	return NewNumberWithWindow(10, time.Second)
+}
+
+// NewNumberWithWindow initializes a RollingNumber struct which keeps the given number
+// of buckets, each covering bucketDuration of time.
+func NewNumberWithWindow(buckets int, bucketDuration time.Duration) *Number {
+	if buckets < 1 {
+		buckets = 1
+	}
+	if bucketDuration <= 0 {
+		bucketDuration = time.Second
+	}
+
 	r := &Number{
-		Buckets: make(map[int64]*numberBucket),
-		Mutex:   &sync.RWMutex{},
+		Buckets:        make(map[int64]*numberBucket),
+		Mutex:          &sync.RWMutex{},
+		numBuckets:     int64(buckets),
+		bucketDuration: bucketDuration,
 	}
 	return r
 }
 
+// NewAtomicNumberWithWindow initializes a Number like NewNumberWithWindow, for counting whole
+// numbers without taking its Mutex. Increment and UpdateMax only lock when they are the first to
+// use a bucket in a new period of time, so concurrent counts of a hot counter do not contend on
+// a lock. Values are truncated to whole numbers.
+//
+// Sums stay exact across bucket boundaries, except that an Increment held up for a whole window
+// between reading the time and counting may be counted in the newer bucket which replaced the
+// one it meant.
+func NewAtomicNumberWithWindow(buckets int, bucketDuration time.Duration) *Number {
+	r := NewNumberWithWindow(buckets, bucketDuration)
+	r.ring = make([]atomicBucket, r.numBuckets)
+	return r
+}
+
+// Window returns the length of time the buckets cover.
+func (r *Number) Window() time.Duration {
+	return time.Duration(r.numBuckets) * r.bucketDuration
+}
+
+func (r *Number) bucketKey(t time.Time) int64 {
+	return t.UnixNano() / int64(r.bucketDuration)
+}
+
 func (r *Number) getCurrentBucket() *numberBucket {
-	now := time.Now().Unix()
+	now := r.bucketKey(currentTime())
 	var bucket *numberBucket
 	var ok bool
 
@@ -38,12 +91,42 @@ func (r *Number) getCurrentBucket() *numberBucket {
 	return bucket
 }
 
+// atomicBucket returns the bucket of an atomic Number for key, taking it over from an older key
+// if need be. It returns nil when the bucket already holds a newer key, which means key has left
+// the window.
+func (r *Number) atomicBucket(key int64) *atomicBucket {
+	i := key % r.numBuckets
+	if i < 0 {
+		i += r.numBuckets
+	}
+	b := &r.ring[i]
+	if held := atomic.LoadInt64(&b.key); held == key {
+		return b
+	} else if held > key {
+		return nil
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	held := atomic.LoadInt64(&b.key)
+	if held > key {
+		return nil
+	}
+	if held < key {
+		// The value is cleared before the key moves on, so a reader which sees the new key
+		// never sees the old key's value.
+		atomic.StoreInt64(&b.value, 0)
+		atomic.StoreInt64(&b.key, key)
+	}
+	return b
+}
+
 func (r *Number) removeOldBuckets() {
-	now := time.Now().Unix() - 10
+	oldest := r.bucketKey(currentTime()) - r.numBuckets
 
 	for timestamp := range r.Buckets {
-		// TODO: configurable rolling window
-		if timestamp <= now {
+		if timestamp <= oldest {
 			delete(r.Buckets, timestamp)
 		}
 	}
@@ -54,6 +137,12 @@ func (r *Number) Increment(i float64) {
 	if i == 0 {
 		return
 	}
+	if r.ring != nil {
+		if b := r.atomicBucket(r.bucketKey(currentTime())); b != nil {
+			atomic.AddInt64(&b.value, int64(i))
+		}
+		return
+	}
 
 	r.Mutex.Lock()
 	defer r.Mutex.Unlock()
@@ -65,6 +154,17 @@ func (r *Number) Increment(i float64) {
 
 // UpdateMax updates the maximum value in the current bucket.
 func (r *Number) UpdateMax(n float64) {
+	if r.ring != nil {
+		b := r.atomicBucket(r.bucketKey(currentTime()))
+		for b != nil {
+			value := atomic.LoadInt64(&b.value)
+			if int64(n) <= value || atomic.CompareAndSwapInt64(&b.value, value, int64(n)) {
+				return
+			}
+		}
+		return
+	}
+
 	r.Mutex.Lock()
 	defer r.Mutex.Unlock()
 
@@ -75,16 +175,25 @@ func (r *Number) UpdateMax(n float64) {
 	r.removeOldBuckets()
 }
 
-// Sum sums the values over the buckets in the last 10 seconds.
+// Sum sums the values over the buckets in the window.
 func (r *Number) Sum(now time.Time) float64 {
 	sum := float64(0)
+	oldest := r.bucketKey(now) - r.numBuckets
+
+	if r.ring != nil {
+		for i := range r.ring {
+			if b := &r.ring[i]; atomic.LoadInt64(&b.key) > oldest {
+				sum += float64(atomic.LoadInt64(&b.value))
+			}
+		}
+		return sum
+	}
 
 	r.Mutex.RLock()
 	defer r.Mutex.RUnlock()
 
 	for timestamp, bucket := range r.Buckets {
-		// TODO: configurable rolling window
-		if timestamp >= now.Unix()-10 {
+		if timestamp > oldest {
 			sum += bucket.Value
 		}
 	}
@@ -92,16 +201,27 @@ func (r *Number) Sum(now time.Time) float64 {
 	return sum
 }
 
-// Max returns the maximum value seen in the last 10 seconds.
+// Max returns the maximum value seen in the window.
 func (r *Number) Max(now time.Time) float64 {
 	var max float64
+	oldest := r.bucketKey(now) - r.numBuckets
+
+	if r.ring != nil {
+		for i := range r.ring {
+			if b := &r.ring[i]; atomic.LoadInt64(&b.key) > oldest {
+				if value := float64(atomic.LoadInt64(&b.value)); value > max {
+					max = value
+				}
+			}
+		}
+		return max
+	}
 
 	r.Mutex.RLock()
 	defer r.Mutex.RUnlock()
 
 	for timestamp, bucket := range r.Buckets {
-		// TODO: configurable rolling window
-		if timestamp >= now.Unix()-10 {
+		if timestamp > oldest {
 			if bucket.Value > max {
 				max = bucket.Value
 			}
@@ -111,6 +231,7 @@ func (r *Number) Max(now time.Time) float64 {
 	return max
 }
 
+// Avg returns the average value per bucket over the window.
 func (r *Number) Avg(now time.Time) float64 {
-	return r.Sum(now) / 10
+	return r.Sum(now) / float64(r.numBuckets)
 }
@@ -0,0 +1,95 @@
+package rolling
+
+import (
+	"sync"
+	"time"
+)
+
+// Histogram counts how many times each whole number has been seen over a bounded number of time
+// buckets, like Number.
+type Histogram struct {
+	Buckets map[int64]*histogramBucket
+	Mutex   *sync.RWMutex
+
+	numBuckets     int64
+	bucketDuration time.Duration
+}
+
+type histogramBucket struct {
+	// Counts[n] is how many times n was seen.
+	Counts []uint64
+}
+
+// NewHistogramWithWindow initializes a Histogram which keeps the given number of buckets, each
+// covering bucketDuration of time.
+func NewHistogramWithWindow(buckets int, bucketDuration time.Duration) *Histogram {
+	if buckets < 1 {
+		buckets = 1
+	}
+	if bucketDuration <= 0 {
+		bucketDuration = time.Second
+	}
+
+	return &Histogram{
+		Buckets:        make(map[int64]*histogramBucket),
+		Mutex:          &sync.RWMutex{},
+		numBuckets:     int64(buckets),
+		bucketDuration: bucketDuration,
+	}
+}
+
+func (r *Histogram) bucketKey(t time.Time) int64 {
+	return t.UnixNano() / int64(r.bucketDuration)
+}
+
+// Add counts n in the current bucket. Negative numbers are ignored.
+func (r *Histogram) Add(n int) {
+	if n < 0 {
+		return
+	}
+
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	now := r.bucketKey(currentTime())
+	bucket, ok := r.Buckets[now]
+	if !ok {
+		bucket = &histogramBucket{}
+		r.Buckets[now] = bucket
+	}
+	for len(bucket.Counts) <= n {
+		bucket.Counts = append(bucket.Counts, 0)
+	}
+	bucket.Counts[n]++
+
+	oldest := now - r.numBuckets
+	for timestamp := range r.Buckets {
+		if timestamp <= oldest {
+			delete(r.Buckets, timestamp)
+		}
+	}
+}
+
+// Counts returns how many times each number was seen over the window, indexed by the number. It
+// is as long as the largest number seen requires, and empty if nothing was seen.
+func (r *Histogram) Counts(now time.Time) []uint64 {
+	var counts []uint64
+	oldest := r.bucketKey(now) - r.numBuckets
+
+	r.Mutex.RLock()
+	defer r.Mutex.RUnlock()
+
+	for timestamp, bucket := range r.Buckets {
+		if timestamp <= oldest {
+			continue
+		}
+		for len(counts) < len(bucket.Counts) {
+			counts = append(counts, 0)
+		}
+		for n, count := range bucket.Counts {
+			counts[n] += count
+		}
+	}
+
+	return counts
+}
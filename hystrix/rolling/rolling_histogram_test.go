@@ -0,0 +1,32 @@
+package rolling
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHistogram(t *testing.T) {
+	Convey("when numbers are added to a histogram with a 2 bucket, 1 second window", t, func() {
+		now := time.Unix(1000, 0)
+		SetNow(func() time.Time { return now })
+		defer SetNow(nil)
+
+		h := NewHistogramWithWindow(2, 500*time.Millisecond)
+		h.Add(1)
+		h.Add(3)
+		h.Add(3)
+		now = now.Add(500 * time.Millisecond)
+		h.Add(1)
+
+		Convey("each number is counted", func() {
+			So(h.Counts(now), ShouldResemble, []uint64{0, 2, 0, 2})
+		})
+
+		Convey("the oldest bucket ages out after the window has passed", func() {
+			So(h.Counts(now.Add(500*time.Millisecond)), ShouldResemble, []uint64{0, 1})
+			So(h.Counts(now.Add(time.Second)), ShouldBeEmpty)
+		})
+	})
+}
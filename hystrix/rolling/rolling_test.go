@@ -1,6 +1,7 @@
 package rolling
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -36,6 +37,45 @@ func TestAvg(t *testing.T) {
 	})
 }
 
+func TestWindow(t *testing.T) {
+	Convey("when adding values to a rolling number with a 2 bucket, 100ms window", t, func() {
+		n := NewNumberWithWindow(2, 100*time.Millisecond)
+		n.Increment(1)
+
+		Convey("the window should be 200ms", func() {
+			So(n.Window(), ShouldEqual, 200*time.Millisecond)
+		})
+
+		Convey("the value should be counted right away", func() {
+			So(n.Sum(time.Now()), ShouldEqual, 1)
+		})
+
+		Convey("the value should age out after the window has passed", func() {
+			time.Sleep(250 * time.Millisecond)
+			So(n.Sum(time.Now()), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSetNow(t *testing.T) {
+	Convey("when values are added while the time source is replaced", t, func() {
+		now := time.Unix(1000, 0)
+		SetNow(func() time.Time { return now })
+		defer SetNow(nil)
+
+		n := NewNumber()
+		n.Increment(1)
+		now = now.Add(5 * time.Second)
+		n.Increment(2)
+
+		Convey("they are bucketed by the replaced time", func() {
+			So(n.Sum(now), ShouldEqual, 3)
+			So(n.Sum(now.Add(6*time.Second)), ShouldEqual, 2)
+			So(n.Sum(now.Add(10*time.Second)), ShouldEqual, 0)
+		})
+	})
+}
+
 func BenchmarkRollingNumberIncrement(b *testing.B) {
 	n := NewNumber()
 
@@ -55,3 +95,90 @@ func BenchmarkRollingNumberUpdateMax(b *testing.B) {
 		n.UpdateMax(float64(i))
 	}
 }
+
+func TestAtomicNumber(t *testing.T) {
+	Convey("with an atomic rolling number over a 10 bucket window", t, func() {
+		now := time.Unix(1000, 0)
+		var mutex sync.Mutex
+		SetNow(func() time.Time {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return now
+		})
+		defer SetNow(nil)
+		advance := func(d time.Duration) {
+			mutex.Lock()
+			now = now.Add(d)
+			mutex.Unlock()
+		}
+
+		n := NewAtomicNumberWithWindow(10, time.Second)
+
+		Convey("concurrent increments are all counted", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						n.Increment(1)
+					}
+				}()
+			}
+			wg.Wait()
+			So(n.Sum(now), ShouldEqual, 5000)
+		})
+
+		Convey("sums are kept across bucket boundaries", func() {
+			for i := 0; i < 15; i++ {
+				n.Increment(float64(i))
+				advance(time.Second)
+			}
+			// buckets 5 to 14 are the last 10 seconds
+			So(n.Sum(now.Add(-time.Second)), ShouldEqual, 95)
+			So(n.Max(now.Add(-time.Second)), ShouldEqual, 14)
+			So(n.Sum(now.Add(9*time.Second)), ShouldEqual, 0)
+		})
+
+		Convey("a reused bucket starts again from zero", func() {
+			n.Increment(3)
+			advance(10 * time.Second)
+			n.Increment(1)
+			So(n.Sum(now), ShouldEqual, 1)
+		})
+
+		Convey("UpdateMax keeps the largest value of each bucket", func() {
+			n.UpdateMax(5)
+			n.UpdateMax(2)
+			advance(time.Second)
+			n.UpdateMax(3)
+			So(n.Max(now), ShouldEqual, 5)
+		})
+	})
+}
+
+// BenchmarkRollingNumberIncrementParallel contrasts with BenchmarkAtomicNumberIncrementParallel,
+// showing what the mutex costs a counter incremented from many goroutines.
+func BenchmarkRollingNumberIncrementParallel(b *testing.B) {
+	n := NewNumber()
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n.Increment(1)
+		}
+	})
+}
+
+func BenchmarkAtomicNumberIncrementParallel(b *testing.B) {
+	n := NewAtomicNumberWithWindow(10, time.Second)
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n.Increment(1)
+		}
+	})
+}
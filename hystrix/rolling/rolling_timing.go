@@ -44,13 +44,13 @@ func (r *Timing) SortedDurations() []time.Duration {
 	t := r.LastCachedTime
 	r.Mutex.RUnlock()
 
-	if t+time.Duration(1*time.Second).Nanoseconds() > time.Now().UnixNano() {
+	if t+time.Duration(1*time.Second).Nanoseconds() > currentTime().UnixNano() {
 		// don't recalculate if current cache is still fresh
 		return r.CachedSortedDurations
 	}
 
 	var durations byDuration
-	now := time.Now()
+	now := currentTime()
 
 	r.Mutex.Lock()
 	defer r.Mutex.Unlock()
@@ -67,14 +67,14 @@ func (r *Timing) SortedDurations() []time.Duration {
 	sort.Sort(durations)
 
 	r.CachedSortedDurations = durations
-	r.LastCachedTime = time.Now().UnixNano()
+	r.LastCachedTime = currentTime().UnixNano()
 
 	return r.CachedSortedDurations
 }
 
 func (r *Timing) getCurrentBucket() *timingBucket {
 	r.Mutex.RLock()
-	now := time.Now()
+	now := currentTime()
 	bucket, exists := r.Buckets[now.Unix()]
 	r.Mutex.RUnlock()
 
@@ -90,7 +90,7 @@ func (r *Timing) getCurrentBucket() *timingBucket {
 }
 
 func (r *Timing) removeOldBuckets() {
-	now := time.Now()
+	now := currentTime()
 
 	for timestamp := range r.Buckets {
 		// TODO: configurable rolling window
@@ -111,8 +111,13 @@ func (r *Timing) Add(duration time.Duration) {
 	r.removeOldBuckets()
 }
 
-// Percentile computes the percentile given with a linear interpolation.
+// Percentile computes the percentile given with a linear interpolation, in milliseconds.
 func (r *Timing) Percentile(p float64) uint32 {
+	return uint32(r.PercentileDuration(p).Nanoseconds() / 1000000)
+}
+
+// PercentileDuration computes the percentile given with a linear interpolation.
+func (r *Timing) PercentileDuration(p float64) time.Duration {
 	sortedDurations := r.SortedDurations()
 	length := len(sortedDurations)
 	if length <= 0 {
@@ -120,7 +125,7 @@ func (r *Timing) Percentile(p float64) uint32 {
 	}
 
 	pos := r.ordinal(len(sortedDurations), p) - 1
-	return uint32(sortedDurations[pos].Nanoseconds() / 1000000)
+	return sortedDurations[pos]
 }
 
 func (r *Timing) ordinal(length int, percentile float64) int64 {
@@ -131,8 +136,13 @@ func (r *Timing) ordinal(length int, percentile float64) int64 {
 	return int64(math.Ceil((percentile / float64(100)) * float64(length)))
 }
 
-// Mean computes the average timing in the last 60 seconds.
+// Mean computes the average timing in the last 60 seconds, in milliseconds.
 func (r *Timing) Mean() uint32 {
+	return uint32(r.MeanDuration().Nanoseconds() / 1000000)
+}
+
+// MeanDuration computes the average timing in the last 60 seconds.
+func (r *Timing) MeanDuration() time.Duration {
 	sortedDurations := r.SortedDurations()
 	var sum time.Duration
 	for _, d := range sortedDurations {
@@ -144,5 +154,5 @@ func (r *Timing) Mean() uint32 {
 		return 0
 	}
 
-	return uint32(sum.Nanoseconds()/length) / 1000000
+	return time.Duration(sum.Nanoseconds() / length)
 }
@@ -0,0 +1,165 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// A StatusError is what run of a command created by NewRoundTripper fails with for a response
+// whose status counts as a failure. Fallbacks can get at it with errors.As, and its Response is
+// still unread.
+type StatusError struct {
+	Response *http.Response
+}
+
+func (e *StatusError) Error() string {
+	return "hystrix: response status " + e.Response.Status
+}
+
+type roundTripper struct {
+	name     string
+	next     http.RoundTripper
+	fallback func(*http.Request, error) (*http.Response, error)
+}
+
+// NewRoundTripper returns an http.RoundTripper which sends each request through next as the
+// named command, executed with GoC using the request's context, so canceling the request
+// cancels the command. A nil next means http.DefaultTransport. Set it as the Transport of an
+// http.Client to protect every request the client makes.
+//
+// Responses with a 5xx status count as failures. If the command has an IsFailure, it decides
+// instead for every response whose status is not 2xx, being passed a *StatusError. Either way,
+// a response which failed is still returned to the caller unless a fallback replaces it, so
+// callers see the same responses as they would without the command.
+//
+// fallback is called with the request and the reason the command failed, which is a
+// *CommandError, and returns the response to use instead. A nil fallback leaves the command's
+// registered fallback, if any, to return an error. The body of any response which is not
+// returned is closed.
+func NewRoundTripper(name string, next http.RoundTripper, fallback func(*http.Request, error) (*http.Response, error)) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &roundTripper{name: name, next: next, fallback: fallback}
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// run's context ends as soon as run returns, which would cut off reading the body, so the
+	// request has a context of its own which ends when run is given up on or the body is closed.
+	ctx, cancel := context.WithCancel(req.Context())
+
+	// run keeps going after a timeout, so it must close a response which came too late.
+	var mutex sync.Mutex
+	var resp, fallbackResp *http.Response
+	returned := false
+	abandoned := false
+
+	run := func(runCtx context.Context) error {
+		stop := make(chan struct{})
+		go func() {
+			select {
+			case <-runCtx.Done():
+				mutex.Lock()
+				if !returned {
+					cancel()
+				}
+				mutex.Unlock()
+			case <-stop:
+			}
+		}()
+
+		r, err := t.next.RoundTrip(req.WithContext(ctx))
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		returned = true
+		close(stop)
+
+		if err != nil {
+			return err
+		}
+		if abandoned {
+			r.Body.Close()
+			return nil
+		}
+		resp = r
+		return statusError(t.name, r)
+	}
+
+	var f fallbackFuncC
+	if t.fallback != nil {
+		f = func(ctx context.Context, err error) error {
+			r, err := t.fallback(req, err)
+
+			mutex.Lock()
+			fallbackResp = r
+			mutex.Unlock()
+
+			return err
+		}
+	}
+
+	err := <-GoC(req.Context(), t.name, run, f)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	abandoned = true
+
+	// keep is the response to return, and the other one is closed
+	var keep *http.Response
+	var statusErr *StatusError
+	switch {
+	case err == nil && fallbackResp != nil:
+		keep = fallbackResp
+	case err == nil && resp != nil:
+		keep = resp
+	case errors.As(err, &statusErr) && statusErr.Response == resp:
+		// the response failed, and no fallback replaced it
+		keep, err = resp, nil
+	case err == nil:
+		// a registered fallback succeeded, but it has no response to give
+		err = fmt.Errorf("hystrix: fallback for %v returned no response", t.name)
+	}
+
+	for _, r := range []*http.Response{resp, fallbackResp} {
+		if r != nil && r != keep {
+			r.Body.Close()
+		}
+	}
+	if keep == nil {
+		cancel()
+		return nil, err
+	}
+	keep.Body = &cancelBody{ReadCloser: keep.Body, cancel: cancel}
+	return keep, nil
+}
+
+// statusError returns the error run of the named command fails with for resp, or nil if resp
+// does not count as a failure.
+func statusError(name string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode < 500 && getSettings(name).IsFailure == nil {
+		return nil
+	}
+
+	return &StatusError{Response: resp}
+}
+
+// cancelBody ends the context of the request a response answered once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,141 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoundTripper(t *testing.T) {
+	Convey("with a server which responds with the status it is asked for", t, func() {
+		defer Flush()
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if delay := req.URL.Query().Get("delay"); delay != "" {
+				d, _ := time.ParseDuration(delay)
+				select {
+				case <-time.After(d):
+				case <-req.Context().Done():
+					return
+				}
+			}
+			status := http.StatusOK
+			switch req.URL.Path {
+			case "/404":
+				status = http.StatusNotFound
+			case "/503":
+				status = http.StatusServiceUnavailable
+			}
+			rw.WriteHeader(status)
+			rw.Write([]byte("from server"))
+		}))
+		defer server.Close()
+
+		client := &http.Client{Transport: NewRoundTripper("", nil, nil)}
+		get := func(path string) (int, string, error) {
+			resp, err := client.Get(server.URL + path)
+			if err != nil {
+				return 0, "", err
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, string(body), err
+		}
+
+		Convey("a successful response is returned with its body", func() {
+			status, body, err := get("/")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusOK)
+			So(body, ShouldEqual, "from server")
+		})
+
+		Convey("a 5xx response is returned, but counts as a failure", func() {
+			status, body, err := get("/503")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusServiceUnavailable)
+			So(body, ShouldEqual, "from server")
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 1)
+		})
+
+		Convey("a 4xx response counts as a success", func() {
+			status, _, err := get("/404")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusNotFound)
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Successes, ShouldEqual, 1)
+		})
+
+		Convey("IsFailure decides for any status which is not 2xx", func() {
+			ConfigureCommand("", CommandConfig{IsFailure: func(err error) bool {
+				var statusErr *StatusError
+				return errors.As(err, &statusErr) && statusErr.Response.StatusCode == http.StatusNotFound
+			}})
+			get("/404")
+			get("/503")
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").Failures, ShouldEqual, 1)
+			So(GetMetrics("").Successes, ShouldEqual, 1)
+		})
+
+		Convey("a command which times out fails with ErrTimeout", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 20})
+			_, _, err := get("/?delay=1s")
+			So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+		})
+
+		Convey("canceling the request cancels the command", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequest("GET", server.URL+"/?delay=1s", nil)
+			time.AfterFunc(20*time.Millisecond, cancel)
+			_, err := client.Do(req.WithContext(ctx))
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+			time.Sleep(10 * time.Millisecond)
+			So(GetMetrics("").ContextCanceled, ShouldEqual, 1)
+		})
+
+		Convey("with a fallback", func() {
+			var reason error
+			client.Transport = NewRoundTripper("", nil, func(req *http.Request, err error) (*http.Response, error) {
+				reason = err
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(strings.NewReader("from fallback")),
+					Request:    req,
+				}, nil
+			})
+
+			Convey("it replaces a failed response", func() {
+				status, body, err := get("/503")
+				So(err, ShouldBeNil)
+				So(status, ShouldEqual, http.StatusOK)
+				So(body, ShouldEqual, "from fallback")
+
+				var statusErr *StatusError
+				So(errors.As(reason, &statusErr), ShouldBeTrue)
+				So(statusErr.Response.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("it replaces a request which timed out", func() {
+				ConfigureCommand("", CommandConfig{Timeout: 20})
+				_, body, err := get("/?delay=1s")
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, "from fallback")
+				So(errors.Is(reason, ErrTimeout), ShouldBeTrue)
+			})
+
+			Convey("it is not used for a successful response", func() {
+				_, body, err := get("/")
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, "from server")
+				So(reason, ShouldBeNil)
+			})
+		})
+	})
+}
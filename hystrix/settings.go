@@ -1,10 +1,16 @@
 package hystrix
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// The default settings below apply to any setting a command does not override. They are read when
+// ConfigureCommand is called, or when an unconfigured command is first used, so changing them
+// afterwards does not affect commands which already have settings.
 var (
 	// DefaultTimeout is how long to wait for command to complete, in milliseconds
 	DefaultTimeout = 1000
@@ -14,32 +20,273 @@ var (
 	DefaultVolumeThreshold = 20
 	// DefaultSleepWindow is how long, in milliseconds, to wait after a circuit opens before testing for recovery
 	DefaultSleepWindow = 5000
-	// DefaultErrorPercentThreshold causes circuits to open once the rolling measure of errors exceeds this percent of requests
+	// DefaultErrorPercentThreshold causes circuits to open once the rolling measure of errors reaches this percent of requests
 	DefaultErrorPercentThreshold = 50
+	// DefaultRollingWindow is how long, in milliseconds, the rolling metrics used for circuit health are measured over
+	DefaultRollingWindow = 10000
+	// DefaultWindowSize is how many of the latest requests circuit health is measured over, for commands with a CountBased window
+	DefaultWindowSize = 100
 	// DefaultLogger is the default logger that will be used in the Hystrix package. By default prints nothing.
 	DefaultLogger = NoopLogger{}
 )
 
+// SetDefaultTimeout sets DefaultTimeout, in milliseconds. It is safe to call while commands are
+// being configured, but like assigning DefaultTimeout it does not change commands which already
+// have settings.
+func SetDefaultTimeout(ms int) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	DefaultTimeout = ms
+}
+
+// SetDefaultMaxConcurrent sets DefaultMaxConcurrent like SetDefaultTimeout.
+func SetDefaultMaxConcurrent(max int) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	DefaultMaxConcurrent = max
+}
+
+// SetDefaultErrorPercentThreshold sets DefaultErrorPercentThreshold like SetDefaultTimeout.
+func SetDefaultErrorPercentThreshold(percent int) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	DefaultErrorPercentThreshold = percent
+}
+
+// RequireRegistration makes executing a command which was never configured fail with
+// ErrUnknownCommand, rather than configuring it with the default settings, so that a misspelt
+// command name is caught. It should be set before any commands run.
+var RequireRegistration = false
+
+// WindowType chooses what a command's circuit health is measured over.
+type WindowType string
+
+const (
+	// TimeBased windows measure health over the requests of the last RollingWindow. It is the
+	// default.
+	TimeBased WindowType = "time"
+	// CountBased windows measure health over the last WindowSize requests, however long ago they
+	// were, which suits commands which run only a few times a minute.
+	CountBased WindowType = "count"
+)
+
+// NoTimeout can be used as a command's Timeout so that it never times out, which suits
+// long-running commands such as streams.
+const NoTimeout = -1
+
+// UnlimitedConcurrency can be used as a command's MaxConcurrentRequests so that its executions
+// are never limited or rejected for concurrency, which suits cheap in-memory commands. Like
+// NoTimeout, it is needed because a zero MaxConcurrentRequests takes the default. The circuit,
+// timeout and fallbacks still apply, and the command's executions are still counted in
+// ConcurrencyInUse. AdaptiveConcurrency, FairQueue and MaxQueueWait have no effect on it.
+const UnlimitedConcurrency = -1
+
 type Settings struct {
-	Timeout                time.Duration
-	MaxConcurrentRequests  int
-	RequestVolumeThreshold uint64
-	SleepWindow            time.Duration
-	ErrorPercentThreshold  int
+	Timeout                       time.Duration
+	MaxConcurrentRequests         int
+	RequestVolumeThreshold        uint64
+	SleepWindow                   time.Duration
+	ErrorPercentThreshold         int
+	RollingWindow                 time.Duration
+	RollingBuckets                int
+	IsFailure                     func(err error) bool `json:"-"`
+	IgnoredErrors                 []error              `json:"-"`
+	FallbackMaxConcurrent         int
+	MaxQueueWait                  time.Duration
+	Warmup                        time.Duration
+	SlowCallDurationThreshold     time.Duration
+	SlowCallRateThreshold         int
+	Group                         string
+	RetryAttempts                 int
+	RetryBackoff                  time.Duration
+	IsRetryable                   func(err error) bool `json:"-"`
+	AdaptiveConcurrency           bool
+	FallbackTimeout               time.Duration
+	WindowType                    WindowType
+	WindowSize                    int
+	Tags                          map[string]string
+	DynamicTimeout                DynamicTimeout
+	HalfOpenMaxRequests           int
+	HalfOpenMinSamples            int
+	RateLimit                     int
+	Breaker                       Breaker `json:"-"`
+	ConsecutiveFailureThreshold   int
+	ConsecutiveSuccessThreshold   int
+	FairQueue                     bool
+	CountFallbackSuccessAsSuccess bool
+	SleepWindowJitter             time.Duration
+
+	// defaulted is set on the settings useSettings gives a command on first use, which are
+	// dropped along with its circuit when SetMaxCommands evicts it.
+	defaulted bool
 }
 
 // CommandConfig is used to tune circuit settings at runtime
+//
+// Fields left at zero take their Default value. A Timeout of NoTimeout means the command never
+// times out, and a MaxConcurrentRequests of UnlimitedConcurrency means its executions are never
+// limited. Other negative values, and percentages above 100, are rejected by ConfigureCommand.
+//
+// Once the rolling window holds at least RequestVolumeThreshold requests, the circuit opens as
+// soon as its error percentage reaches ErrorPercentThreshold. With the default of 50, a command
+// failing exactly half its requests is tripped.
+//
+// SleepWindow milliseconds after opening, the circuit goes half-open and lets up to
+// HalfOpenMaxRequests requests through, one by default. It re-opens once ErrorPercentThreshold
+// percent of them have failed, and closes once enough have succeeded that it cannot. Closing
+// clears the rolling window, so failures from before the circuit opened cannot trip it again.
+// A HalfOpenMinSamples above one lets at least that many probes through, re-opens the circuit
+// on the first of them to fail, and only closes it once that many have succeeded, so a single
+// lucky success cannot close it.
+//
+// SleepWindowJitter adds a random wait of up to that many milliseconds to the sleep window,
+// drawn again each time the circuit opens or is restored by ImportState, so that instances
+// whose circuits opened together in the same outage do not all probe the dependency at the same
+// moment. Zero, the default, adds none, and the circuit then probes exactly SleepWindow after
+// opening.
+//
+// With a ConsecutiveFailureThreshold, the circuit opens once that many executions have failed
+// in a row instead, whatever its error percentage, warmup and RequestVolumeThreshold. A success
+// starts the count again. Once half-open, it lets enough probes through to close, re-opens on
+// any failure, and closes after ConsecutiveSuccessThreshold successes, one by default. Timeouts
+// and rejections count as failures.
+//
+// RollingWindow is split into RollingBuckets buckets of equal length, and the oldest bucket is
+// dropped as each new one starts. Without RollingBuckets the window is split into buckets of
+// about a second, so a window of 1500 milliseconds is kept as two buckets of 750 milliseconds.
+// Both are read when a command's circuit is first created, so they should be configured before
+// the command is first executed. Reconfiguring them afterwards has no effect until Flush.
+//
+// WindowType chooses whether circuit health is measured over the time of RollingWindow, or over
+// the last WindowSize requests with CountBased. A CountBased window still needs
+// RequestVolumeThreshold requests before the circuit can open, so the threshold should be no
+// larger than WindowSize. Only health uses the count-based window. The rolling counts reported
+// by GetMetrics and the stream are always time-based. The window type is read when the circuit
+// is created, like RollingWindow.
+//
+// IsFailure decides whether an error returned by run counts as a failure. Errors it rejects are
+// returned to the caller without running the fallback, and are recorded as successes in the
+// circuit's metrics. If it is nil, every error counts as a failure. If it panics, the error
+// counts as a failure. Because it is a func, CommandConfig and Settings values cannot be
+// compared with ==.
+//
+// IgnoredErrors lists errors, such as context.Canceled or io.EOF, which are expected control
+// flow rather than failures. An error returned by run which matches one of them with errors.Is is
+// treated like one IsFailure rejects, and IsFailure is not asked about it.
+//
+// FallbackMaxConcurrent limits how many fallbacks of a command can run at the same time. Zero
+// means fallbacks are not limited.
+//
+// FallbackTimeout is how long, in milliseconds, a fallback may run before the command gives up
+// on it with ErrFallbackTimeout. It is separate from Timeout, and covers every fallback of a
+// chain. Zero means fallbacks have no timeout. Timeout covers waiting for an executor and run
+// only: a run which times out goes straight to the fallback, and a run which fails in time
+// gets its fallback's result however long the fallback takes, unless FallbackTimeout ends it.
+// The fallback's context is the one the command was executed with, so a fallback of a run which
+// timed out still has the caller's deadline and values. With a FallbackTimeout its Deadline
+// reports when the fallback will be given up on, if that comes first, and it is canceled then.
+//
+// MaxQueueWait is how long, in milliseconds, a command waits for one of its executors to be free
+// before being rejected. The wait counts towards the command's timeout. Zero rejects immediately.
+// A command whose context ends while it waits fails with the context's error rather than
+// ErrMaxConcurrency, and is counted as canceled or past its deadline rather than rejected.
+//
+// Warmup is how long, in milliseconds, after a command's circuit is created that its health is
+// ignored, so a few early failures cannot trip it. The warmup is kept per circuit rather than
+// from process start: a circuit is created the first time its command is executed, so a command
+// first used long after start still gets its own warmup, and Flush starts it again. Once the
+// warmup has passed the circuit still needs RequestVolumeThreshold requests in its rolling window
+// before it can open. Zero disables the warmup.
+//
+// SlowCallDurationThreshold is how long, in milliseconds, a run may take before it counts as a
+// slow call, even if it succeeds. Once SlowCallRateThreshold percent of the requests in the
+// rolling window are slow calls, the circuit opens just as it would for too many errors. Both
+// must be set for slow calls to open the circuit.
+//
+// Commands with the same Group share one executor pool, so that calls to a single dependency
+// compete for the same executors whichever command makes them. Each command keeps its own
+// circuit. The shared pool, including its fallback limit, is sized by the settings of the group's
+// own name, so configure it with ConfigureCommand(group, ...) before any of its commands run.
+//
+// RetryAttempts is how many times run is retried after a failure before the command fails and
+// its fallback is run. The first retry waits RetryBackoff milliseconds, with the wait doubling
+// for each retry after it. Retries share the command's executor and timeout, and stop as soon as
+// the command times out or its context is done. Only errors counting as failures are retried,
+// and of those only the ones IsRetryable accepts, if it is set.
+//
+// DynamicTimeout lets the timeout follow a multiple of the recent 99th percentile run duration,
+// read as each execution starts. Timeout is used until enough runs have been seen, and a command
+// with NoTimeout never times out whatever its DynamicTimeout.
+//
+// Tags label every event and metric of the command, such as the region of the backend it
+// calls. WithTags adds tags for a single execution.
+//
+// RateLimit caps how many times a second the command may execute, whatever its concurrency.
+// Executions beyond it go straight to their fallback with ErrRateLimited, and count neither for
+// nor against the circuit's health. Up to a second's worth of executions may run in a burst
+// after the command has been idle. Zero means no limit.
+//
+// Breaker replaces the error rate which trips the command's circuit with an algorithm of your
+// own. Each command needs its own Breaker, since it keeps the state of one circuit.
+//
+// AdaptiveConcurrency lets the command's concurrency limit move between 1 and
+// MaxConcurrentRequests. The limit grows while runs finish in their usual time, and backs off
+// when a run times out or takes much longer than usual. Executions the pool rejects for lack of
+// an executor do not make it back off, since the limit itself causes them, and backing off would
+// only cause more. MaxConcurrency reports the current limit.
+//
+// FairQueue hands executors to commands waiting under MaxQueueWait in the order they started
+// waiting, so none of them can be starved by later arrivals. Without it a freed executor goes to
+// whichever waiting command the runtime picks. Like Group, it is read when the command's
+// executor pool is created.
+//
+// A run which fails counts against the circuit even when its fallback succeeds, since the
+// dependency still failed. CountFallbackSuccessAsSuccess records such executions as successes
+// instead, for fallbacks which reach a replica of the same dependency and so show it to be
+// healthy. Timeouts, rejections and short-circuits still count, and event listeners still
+// receive the run's failure.
 type CommandConfig struct {
-	Timeout                int `json:"timeout"`
-	MaxConcurrentRequests  int `json:"max_concurrent_requests"`
-	RequestVolumeThreshold int `json:"request_volume_threshold"`
-	SleepWindow            int `json:"sleep_window"`
-	ErrorPercentThreshold  int `json:"error_percent_threshold"`
+	Timeout                       int                  `json:"timeout"`
+	MaxConcurrentRequests         int                  `json:"max_concurrent_requests"`
+	RequestVolumeThreshold        int                  `json:"request_volume_threshold"`
+	SleepWindow                   int                  `json:"sleep_window"`
+	ErrorPercentThreshold         int                  `json:"error_percent_threshold"`
+	RollingWindow                 int                  `json:"rolling_window"`
+	RollingBuckets                int                  `json:"rolling_buckets"`
+	IsFailure                     func(err error) bool `json:"-"`
+	IgnoredErrors                 []error              `json:"-"`
+	FallbackMaxConcurrent         int                  `json:"fallback_max_concurrent"`
+	MaxQueueWait                  int                  `json:"max_queue_wait"`
+	Warmup                        int                  `json:"warmup"`
+	SlowCallDurationThreshold     int                  `json:"slow_call_duration_threshold"`
+	SlowCallRateThreshold         int                  `json:"slow_call_rate_threshold"`
+	Group                         string               `json:"group"`
+	RetryAttempts                 int                  `json:"retry_attempts"`
+	RetryBackoff                  int                  `json:"retry_backoff"`
+	IsRetryable                   func(err error) bool `json:"-"`
+	AdaptiveConcurrency           bool                 `json:"adaptive_concurrency"`
+	FallbackTimeout               int                  `json:"fallback_timeout"`
+	WindowType                    WindowType           `json:"window_type"`
+	WindowSize                    int                  `json:"window_size"`
+	Tags                          map[string]string    `json:"tags"`
+	DynamicTimeout                DynamicTimeout       `json:"dynamic_timeout"`
+	HalfOpenMaxRequests           int                  `json:"half_open_max_requests"`
+	HalfOpenMinSamples            int                  `json:"half_open_min_samples"`
+	RateLimit                     int                  `json:"rate_limit"`
+	Breaker                       Breaker              `json:"-"`
+	ConsecutiveFailureThreshold   int                  `json:"consecutive_failure_threshold"`
+	ConsecutiveSuccessThreshold   int                  `json:"consecutive_success_threshold"`
+	FairQueue                     bool                 `json:"fair_queue"`
+	CountFallbackSuccessAsSuccess bool                 `json:"count_fallback_success_as_success"`
+	SleepWindowJitter             int                  `json:"sleep_window_jitter"`
 }
 
 var circuitSettings map[string]*Settings
 var settingsMutex *sync.RWMutex
-var log logger
+var log LeveledLogger
 
 func init() {
 	circuitSettings = make(map[string]*Settings)
@@ -47,23 +294,122 @@ func init() {
 	log = DefaultLogger
 }
 
-// Configure applies settings for a set of circuits
-func Configure(cmds map[string]CommandConfig) {
-	for k, v := range cmds {
-		ConfigureCommand(k, v)
+// ConfigError is returned by ConfigureCommand for a CommandConfig holding values which would
+// leave the command's circuit broken, such as a negative SleepWindow, and by Configure within a
+// ConfigErrors. Problems describes each of them.
+type ConfigError struct {
+	Name     string
+	Problems []string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("hystrix: invalid config for command %q: %s", e.Name, strings.Join(e.Problems, "; "))
+}
+
+// ConfigErrors is returned by Configure when any of its configs is invalid. It holds a
+// ConfigError for each offending command, sorted by name.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	commands := make([]string, len(e))
+	for i, err := range e {
+		commands[i] = fmt.Sprintf("%q (%s)", err.Name, strings.Join(err.Problems, "; "))
 	}
+	return "hystrix: invalid config for commands " + strings.Join(commands, ", ")
 }
 
-// ConfigureCommand applies settings for a circuit
-func ConfigureCommand(name string, config CommandConfig) {
+// Configure applies settings for a set of circuits, such as a map unmarshalled from a config
+// file. Every config is validated before any is applied, so if one is invalid none of them are,
+// and the error is a ConfigErrors naming each offending command. The configs are applied
+// together, so commands never see some of them without the others.
+func Configure(cmds map[string]CommandConfig) error {
+	var errs ConfigErrors
+	for name, config := range cmds {
+		if err := validateConfig(name, config); err != nil {
+			errs = append(errs, err.(ConfigError))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Name < errs[j].Name
+		})
+		return errs
+	}
+
 	settingsMutex.Lock()
 	defer settingsMutex.Unlock()
 
-	timeout := DefaultTimeout
-	if config.Timeout != 0 {
-		timeout = config.Timeout
+	for name, config := range cmds {
+		circuitSettings[canonicalName(name)] = newSettings(config)
+	}
+	return nil
+}
+
+// ConfigureCommand applies settings for a circuit. It may be called from several goroutines at
+// once, including while commands are running. A config with invalid values is rejected with a
+// ConfigError, and the command keeps the settings it had.
+func ConfigureCommand(name string, config CommandConfig) error {
+	if err := validateConfig(name, config); err != nil {
+		return err
+	}
+
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	circuitSettings[canonicalName(name)] = newSettings(config)
+	return nil
+}
+
+// validateConfig returns a ConfigError listing every invalid value in config, or nil.
+func validateConfig(name string, config CommandConfig) error {
+	var problems []string
+	nonNegative := func(field string, value int) {
+		if value < 0 {
+			problems = append(problems, fmt.Sprintf("%v must not be negative, got %v", field, value))
+		}
+	}
+	percent := func(field string, value int) {
+		if value < 0 || value > 100 {
+			problems = append(problems, fmt.Sprintf("%v must be between 0 and 100, got %v", field, value))
+		}
+	}
+
+	if config.Timeout != NoTimeout {
+		nonNegative("timeout", config.Timeout)
+	}
+	if config.MaxConcurrentRequests != UnlimitedConcurrency {
+		nonNegative("max_concurrent_requests", config.MaxConcurrentRequests)
+	}
+	nonNegative("request_volume_threshold", config.RequestVolumeThreshold)
+	nonNegative("sleep_window", config.SleepWindow)
+	nonNegative("sleep_window_jitter", config.SleepWindowJitter)
+	percent("error_percent_threshold", config.ErrorPercentThreshold)
+	nonNegative("rolling_window", config.RollingWindow)
+	nonNegative("rolling_buckets", config.RollingBuckets)
+	nonNegative("fallback_max_concurrent", config.FallbackMaxConcurrent)
+	nonNegative("fallback_timeout", config.FallbackTimeout)
+	nonNegative("max_queue_wait", config.MaxQueueWait)
+	nonNegative("warmup", config.Warmup)
+	nonNegative("slow_call_duration_threshold", config.SlowCallDurationThreshold)
+	percent("slow_call_rate_threshold", config.SlowCallRateThreshold)
+	nonNegative("retry_attempts", config.RetryAttempts)
+	nonNegative("retry_backoff", config.RetryBackoff)
+	nonNegative("window_size", config.WindowSize)
+	nonNegative("half_open_max_requests", config.HalfOpenMaxRequests)
+	nonNegative("half_open_min_samples", config.HalfOpenMinSamples)
+	nonNegative("rate_limit", config.RateLimit)
+	nonNegative("consecutive_failure_threshold", config.ConsecutiveFailureThreshold)
+	nonNegative("consecutive_success_threshold", config.ConsecutiveSuccessThreshold)
+
+	if len(problems) > 0 {
+		return ConfigError{Name: name, Problems: problems}
 	}
+	return nil
+}
 
+// newSettings applies the defaults to config. The caller must hold settingsMutex, which guards
+// the defaults.
+func newSettings(config CommandConfig) *Settings {
 	max := DefaultMaxConcurrent
 	if config.MaxConcurrentRequests != 0 {
 		max = config.MaxConcurrentRequests
@@ -84,34 +430,155 @@ func ConfigureCommand(name string, config CommandConfig) {
 		errorPercent = config.ErrorPercentThreshold
 	}
 
-	circuitSettings[name] = &Settings{
-		Timeout:                time.Duration(timeout) * time.Millisecond,
-		MaxConcurrentRequests:  max,
-		RequestVolumeThreshold: uint64(volume),
-		SleepWindow:            time.Duration(sleep) * time.Millisecond,
-		ErrorPercentThreshold:  errorPercent,
+	window := DefaultRollingWindow
+	if config.RollingWindow != 0 {
+		window = config.RollingWindow
+	}
+	buckets, _ := rollingBuckets(time.Duration(window)*time.Millisecond, config.RollingBuckets)
+
+	windowType := TimeBased
+	windowSize := 0
+	if config.WindowType == CountBased {
+		windowType = CountBased
+		windowSize = DefaultWindowSize
+		if config.WindowSize > 0 {
+			windowSize = config.WindowSize
+		}
+	}
+
+	consecutiveSuccesses := 1
+	if config.ConsecutiveSuccessThreshold > 0 {
+		consecutiveSuccesses = config.ConsecutiveSuccessThreshold
+	}
+
+	probes := 1
+	if config.HalfOpenMaxRequests > 0 {
+		probes = config.HalfOpenMaxRequests
+	}
+
+	minSamples := 1
+	if config.HalfOpenMinSamples > 0 {
+		minSamples = config.HalfOpenMinSamples
+	}
+
+	dynamicTimeout := config.DynamicTimeout
+	if dynamicTimeout.MinSamples == 0 {
+		dynamicTimeout.MinSamples = DefaultDynamicTimeoutSamples
+	}
+
+	timeout := DefaultTimeout
+	if config.Timeout < 0 {
+		timeout = 0
+	} else if config.Timeout != 0 {
+		timeout = config.Timeout
+	}
+
+	return &Settings{
+		Timeout:                       time.Duration(timeout) * time.Millisecond,
+		MaxConcurrentRequests:         max,
+		RequestVolumeThreshold:        uint64(volume),
+		SleepWindow:                   time.Duration(sleep) * time.Millisecond,
+		ErrorPercentThreshold:         errorPercent,
+		RollingWindow:                 time.Duration(window) * time.Millisecond,
+		RollingBuckets:                buckets,
+		IsFailure:                     config.IsFailure,
+		IgnoredErrors:                 append([]error(nil), config.IgnoredErrors...),
+		FallbackMaxConcurrent:         config.FallbackMaxConcurrent,
+		MaxQueueWait:                  time.Duration(config.MaxQueueWait) * time.Millisecond,
+		Warmup:                        time.Duration(config.Warmup) * time.Millisecond,
+		SlowCallDurationThreshold:     time.Duration(config.SlowCallDurationThreshold) * time.Millisecond,
+		SlowCallRateThreshold:         config.SlowCallRateThreshold,
+		Group:                         config.Group,
+		RetryAttempts:                 config.RetryAttempts,
+		RetryBackoff:                  time.Duration(config.RetryBackoff) * time.Millisecond,
+		IsRetryable:                   config.IsRetryable,
+		AdaptiveConcurrency:           config.AdaptiveConcurrency,
+		FallbackTimeout:               time.Duration(config.FallbackTimeout) * time.Millisecond,
+		WindowType:                    windowType,
+		WindowSize:                    windowSize,
+		Tags:                          mergeTags(nil, config.Tags),
+		DynamicTimeout:                dynamicTimeout,
+		HalfOpenMaxRequests:           probes,
+		HalfOpenMinSamples:            minSamples,
+		RateLimit:                     config.RateLimit,
+		Breaker:                       config.Breaker,
+		ConsecutiveFailureThreshold:   config.ConsecutiveFailureThreshold,
+		ConsecutiveSuccessThreshold:   consecutiveSuccesses,
+		FairQueue:                     config.FairQueue,
+		CountFallbackSuccessAsSuccess: config.CountFallbackSuccessAsSuccess,
+		SleepWindowJitter:             time.Duration(config.SleepWindowJitter) * time.Millisecond,
 	}
 }
 
+// getSettings returns the named command's settings, or the defaults for a command which has
+// none, without giving it any, so that looking a command up does not register it.
 func getSettings(name string) *Settings {
+	name = canonicalName(name)
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	if s, exists := circuitSettings[name]; exists {
+		return s
+	}
+	return newSettings(CommandConfig{})
+}
+
+// useSettings returns the named command's settings like getSettings, but gives a command which
+// has none the defaults, as its first use does unless RequireRegistration is set.
+func useSettings(name string) *Settings {
+	name = canonicalName(name)
 	settingsMutex.RLock()
 	s, exists := circuitSettings[name]
 	settingsMutex.RUnlock()
-
-	if !exists {
-		ConfigureCommand(name, CommandConfig{})
-		s = getSettings(name)
+	if exists {
+		return s
 	}
 
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+
+	// the command may have been configured since the read lock was released, and those settings
+	// must not be replaced by the defaults
+	if s, exists = circuitSettings[name]; !exists {
+		s = newSettings(CommandConfig{})
+		s.defaulted = true
+		circuitSettings[name] = s
+	}
 	return s
 }
 
+// RegisteredCommands returns the sorted names of every configured command. Unless
+// RequireRegistration is set, it includes commands configured with the defaults on first use.
+func RegisteredCommands() []string {
+	settingsMutex.RLock()
+	names := make([]string, 0, len(circuitSettings))
+	for name := range circuitSettings {
+		names = append(names, name)
+	}
+	settingsMutex.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// isRegistered reports whether the named command has settings, without configuring it.
+func isRegistered(name string) bool {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	_, ok := circuitSettings[canonicalName(name)]
+	return ok
+}
+
+// GetCircuitSettings returns the settings in use by every configured command, after defaults
+// have been applied. The settings are copies, so changing them has no effect on the commands.
 func GetCircuitSettings() map[string]*Settings {
 	copy := make(map[string]*Settings)
 
 	settingsMutex.RLock()
 	for key, val := range circuitSettings {
-		copy[key] = val
+		s := *val
+		copy[key] = &s
 	}
 	settingsMutex.RUnlock()
 
@@ -119,6 +586,17 @@ func GetCircuitSettings() map[string]*Settings {
 }
 
 // SetLogger configures the logger that will be used. This only applies to the hystrix package.
+// Every level is logged with Printf. Use SetLeveledLogger to tell them apart.
 func SetLogger(l logger) {
+	if leveled, ok := l.(LeveledLogger); ok {
+		log = leveled
+		return
+	}
+	log = printfLogger{l}
+}
+
+// SetLeveledLogger configures a logger which receives each log line at its level, like
+// SetLogger.
+func SetLeveledLogger(l LeveledLogger) {
 	log = l
 }
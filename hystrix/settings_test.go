@@ -1,6 +1,9 @@
 package hystrix
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +30,26 @@ func TestConfigureTimeout(t *testing.T) {
 	})
 }
 
+func TestConfigureNoTimeout(t *testing.T) {
+	Convey("given a command configured without a timeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{MaxConcurrentRequests: 100})
+
+		Convey("the timeout should be the default", func() {
+			So(getSettings("").Timeout, ShouldEqual, time.Duration(DefaultTimeout)*time.Millisecond)
+		})
+	})
+
+	Convey("given a command configured with NoTimeout", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{Timeout: NoTimeout})
+
+		Convey("the timeout should be zero", func() {
+			So(getSettings("").Timeout, ShouldEqual, time.Duration(0))
+		})
+	})
+}
+
 func TestConfigureRVT(t *testing.T) {
 	Convey("given a command configured to need 30 requests before tripping the circuit", t, func() {
 		ConfigureCommand("", CommandConfig{RequestVolumeThreshold: 30})
@@ -37,6 +60,29 @@ func TestConfigureRVT(t *testing.T) {
 	})
 }
 
+func TestConfigureRollingBuckets(t *testing.T) {
+	Convey("given a command configured for a 60 second window of 6 buckets", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{RollingWindow: 60000, RollingBuckets: 6})
+
+		Convey("the circuit's metrics cover the whole window", func() {
+			So(getSettings("").RollingBuckets, ShouldEqual, 6)
+			cb, _, _ := GetCircuit("")
+			So(cb.metrics.DefaultCollector().NumRequests().Window(), ShouldEqual, 60*time.Second)
+			So(cb.executorPool.Metrics.Executed.Window(), ShouldEqual, 60*time.Second)
+		})
+	})
+
+	Convey("given default settings", t, func() {
+		defer Flush()
+		ConfigureCommand("", CommandConfig{})
+
+		Convey("the window is split into buckets of a second", func() {
+			So(getSettings("").RollingBuckets, ShouldEqual, 10)
+		})
+	})
+}
+
 func TestSleepWindowDefault(t *testing.T) {
 	Convey("given default settings", t, func() {
 		ConfigureCommand("", CommandConfig{})
@@ -52,8 +98,256 @@ func TestGetCircuitSettings(t *testing.T) {
 		ConfigureCommand("test", CommandConfig{Timeout: 30000})
 
 		Convey("should read the same setting just added", func() {
-			So(GetCircuitSettings()["test"], ShouldEqual, getSettings("test"))
+			So(GetCircuitSettings()["test"], ShouldResemble, getSettings("test"))
 			So(GetCircuitSettings()["test"].Timeout, ShouldEqual, time.Duration(30*time.Second))
 		})
+
+		Convey("changing the returned settings should not affect the command", func() {
+			GetCircuitSettings()["test"].Timeout = time.Second
+			So(getSettings("test").Timeout, ShouldEqual, time.Duration(30*time.Second))
+		})
+	})
+}
+
+func TestUnconfiguredDefaults(t *testing.T) {
+	Convey("given a command which was never configured", t, func() {
+		defer Flush()
+		s := getSettings("never-configured")
+
+		Convey("the default settings should be used", func() {
+			So(s.Timeout, ShouldEqual, time.Duration(DefaultTimeout)*time.Millisecond)
+			So(s.MaxConcurrentRequests, ShouldEqual, DefaultMaxConcurrent)
+			So(s.RequestVolumeThreshold, ShouldEqual, uint64(DefaultVolumeThreshold))
+			So(s.SleepWindow, ShouldEqual, time.Duration(DefaultSleepWindow)*time.Millisecond)
+			So(s.ErrorPercentThreshold, ShouldEqual, DefaultErrorPercentThreshold)
+		})
+	})
+}
+
+func TestFlushSettings(t *testing.T) {
+	Convey("given a configured command", t, func() {
+		ConfigureCommand("flushed", CommandConfig{Timeout: 30000})
+
+		Convey("flushing drops its settings, so it reverts to the defaults", func() {
+			Flush()
+			_, exists := GetCircuitSettings()["flushed"]
+			So(exists, ShouldBeFalse)
+			So(getSettings("flushed").Timeout, ShouldEqual, time.Duration(DefaultTimeout)*time.Millisecond)
+		})
+	})
+}
+
+func TestRequireRegistration(t *testing.T) {
+	Convey("with registration required and one command configured", t, func() {
+		defer Flush()
+		RequireRegistration = true
+		defer func() { RequireRegistration = false }()
+		ConfigureCommand("userService", CommandConfig{})
+
+		Convey("a configured command runs", func() {
+			So(Do("userService", func() error { return nil }, nil), ShouldBeNil)
+		})
+
+		Convey("a misspelt command goes to its fallback without being configured", func() {
+			var fallbackErr error
+			err := Do("usreService", func() error { return nil }, func(err error) error {
+				fallbackErr = err
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(fallbackErr, ShouldResemble, &CommandError{Name: "usreService", Kind: KindRejected, Err: ErrUnknownCommand})
+			So(<-Go("usreService", func() error { return nil }, nil), ShouldResemble, ErrUnknownCommand)
+			So(RegisteredCommands(), ShouldResemble, []string{"userService"})
+		})
+
+		Convey("looking a misspelt command up does not register it", func() {
+			So(MaxConcurrency("usreService"), ShouldEqual, DefaultMaxConcurrent)
+			So(timeoutForCommand("usreService"), ShouldEqual, time.Duration(DefaultTimeout)*time.Millisecond)
+			So(Do("usreService", func() error { return nil }, nil), ShouldEqual, ErrUnknownCommand)
+			So(RegisteredCommands(), ShouldResemble, []string{"userService"})
+		})
+	})
+
+	Convey("without registration required", t, func() {
+		defer Flush()
+		ConfigureCommand("b", CommandConfig{})
+		ConfigureCommand("a", CommandConfig{})
+
+		Convey("unconfigured commands are configured on first use", func() {
+			So(Do("c", func() error { return nil }, nil), ShouldBeNil)
+			So(RegisteredCommands(), ShouldResemble, []string{"a", "b", "c"})
+		})
+	})
+}
+
+func TestSetDefaults(t *testing.T) {
+	Convey("with a command configured before the defaults are changed", t, func() {
+		defer Flush()
+		ConfigureCommand("before", CommandConfig{})
+
+		SetDefaultTimeout(250)
+		SetDefaultMaxConcurrent(3)
+		SetDefaultErrorPercentThreshold(20)
+		defer func() {
+			SetDefaultTimeout(1000)
+			SetDefaultMaxConcurrent(10)
+			SetDefaultErrorPercentThreshold(50)
+		}()
+
+		Convey("commands configured afterwards use the new defaults", func() {
+			settings := getSettings("after")
+			So(settings.Timeout, ShouldEqual, 250*time.Millisecond)
+			So(settings.MaxConcurrentRequests, ShouldEqual, 3)
+			So(settings.ErrorPercentThreshold, ShouldEqual, 20)
+		})
+
+		Convey("commands which already have settings keep the old ones", func() {
+			settings := getSettings("before")
+			So(settings.Timeout, ShouldEqual, time.Second)
+			So(settings.MaxConcurrentRequests, ShouldEqual, 10)
+			So(settings.ErrorPercentThreshold, ShouldEqual, 50)
+		})
+	})
+}
+
+func TestConfigureValidation(t *testing.T) {
+	Convey("with a command configured with invalid values", t, func() {
+		defer Flush()
+		ConfigureCommand("invalid", CommandConfig{Timeout: 500})
+
+		err := ConfigureCommand("invalid", CommandConfig{
+			Timeout:               -5,
+			MaxConcurrentRequests: -2,
+			ErrorPercentThreshold: 101,
+		})
+
+		Convey("a ConfigError lists every problem", func() {
+			var configErr ConfigError
+			So(errors.As(err, &configErr), ShouldBeTrue)
+			So(configErr.Name, ShouldEqual, "invalid")
+			So(configErr.Problems, ShouldResemble, []string{
+				"timeout must not be negative, got -5",
+				"max_concurrent_requests must not be negative, got -2",
+				"error_percent_threshold must be between 0 and 100, got 101",
+			})
+			So(err.Error(), ShouldStartWith, `hystrix: invalid config for command "invalid": timeout`)
+		})
+
+		Convey("the command keeps its settings", func() {
+			So(getSettings("invalid").Timeout, ShouldEqual, 500*time.Millisecond)
+		})
+	})
+
+	Convey("with a command configured with NoTimeout and zeroes", t, func() {
+		defer Flush()
+
+		Convey("the config is accepted", func() {
+			So(ConfigureCommand("valid", CommandConfig{Timeout: NoTimeout}), ShouldBeNil)
+		})
+	})
+
+	Convey("with invalid configs passed to Configure", t, func() {
+		defer Flush()
+		err := Configure(map[string]CommandConfig{
+			"valid":     {Timeout: 500},
+			"invalid":   {SleepWindow: -1},
+			"also_bad":  {ErrorPercentThreshold: 101, RollingWindow: -1},
+			"also_good": {Timeout: NoTimeout},
+		})
+
+		Convey("it names every offending command", func() {
+			So(err, ShouldResemble, ConfigErrors{
+				{Name: "also_bad", Problems: []string{
+					"error_percent_threshold must be between 0 and 100, got 101",
+					"rolling_window must not be negative, got -1",
+				}},
+				{Name: "invalid", Problems: []string{"sleep_window must not be negative, got -1"}},
+			})
+			So(err.Error(), ShouldEqual, `hystrix: invalid config for commands "also_bad" (error_percent_threshold must be between 0 and 100, got 101; rolling_window must not be negative, got -1), "invalid" (sleep_window must not be negative, got -1)`)
+		})
+
+		Convey("none of the commands are configured", func() {
+			So(isRegistered("valid"), ShouldBeFalse)
+			So(isRegistered("also_good"), ShouldBeFalse)
+			So(isRegistered("invalid"), ShouldBeFalse)
+		})
+	})
+
+	Convey("with valid configs passed to Configure", t, func() {
+		defer Flush()
+		err := Configure(map[string]CommandConfig{
+			"first":  {Timeout: 500},
+			"second": {Timeout: 600},
+		})
+
+		Convey("all of the commands are configured", func() {
+			So(err, ShouldBeNil)
+			So(getSettings("first").Timeout, ShouldEqual, 500*time.Millisecond)
+			So(getSettings("second").Timeout, ShouldEqual, 600*time.Millisecond)
+		})
+	})
+}
+
+func TestConfigureConcurrently(t *testing.T) {
+	Convey("with commands configured while they are being executed", t, func() {
+		defer Flush()
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("concurrent-%v", i)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				Do(name, func() error { return nil }, nil)
+			}()
+			go func() {
+				defer wg.Done()
+				ConfigureCommand(name, CommandConfig{Timeout: 1234})
+			}()
+		}
+		wg.Wait()
+
+		Convey("no configuration is replaced by the defaults", func() {
+			for i := 0; i < 20; i++ {
+				So(getSettings(fmt.Sprintf("concurrent-%v", i)).Timeout, ShouldEqual, 1234*time.Millisecond)
+			}
+		})
+	})
+}
+
+func TestReconfigureWhileExecuting(t *testing.T) {
+	Convey("with a command reconfigured over and over while it is executed and inspected", t, func() {
+		defer Flush()
+
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					Do("reconfigured", func() error { return nil }, nil)
+				}
+			}()
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					ConfigureCommand("reconfigured", CommandConfig{Timeout: 1000 + i, MaxConcurrentRequests: 100})
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					GetCircuitSettings()
+					RegisteredCommands()
+					MaxConcurrency("reconfigured")
+				}
+			}()
+		}
+		wg.Wait()
+
+		Convey("the last configuration is in use", func() {
+			So(getSettings("reconfigured").Timeout, ShouldBeGreaterThanOrEqualTo, 1000*time.Millisecond)
+			So(getSettings("reconfigured").MaxConcurrentRequests, ShouldEqual, 100)
+		})
 	})
 }
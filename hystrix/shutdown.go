@@ -0,0 +1,139 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ErrShuttingDown is passed to the fallback of commands executed after Shutdown has been called.
+var ErrShuttingDown = CircuitError{Message: "shutting down"}
+
+// A ShutdownError is returned by Shutdown when commands were still running once its context ended.
+type ShutdownError struct {
+	// Remaining is how many command goroutines had not finished.
+	Remaining int
+	// Err is the error of the context passed to Shutdown.
+	Err error
+}
+
+func (e ShutdownError) Error() string {
+	return fmt.Sprintf("hystrix: %d command goroutines still running at shutdown: %v", e.Remaining, e.Err)
+}
+
+// Unwrap returns the context's error, so errors.Is can match context.DeadlineExceeded.
+func (e ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	lifecycleMutex *sync.Mutex
+	shuttingDown   bool
+	inFlight       int
+	// generation changes with every Flush, which forgets about commands which were running.
+	generation int
+	// drained is closed once inFlight reaches zero during a shutdown.
+	drained chan struct{}
+)
+
+func init() {
+	lifecycleMutex = &sync.Mutex{}
+}
+
+// Shutdown stops new commands from running and waits for running commands and their fallbacks
+// to finish, or for ctx to end. Commands executed after Shutdown go straight to their fallback
+// with ErrShuttingDown.
+//
+// Once every command has finished, the goroutines which collect metrics, deliver events and
+// publish to stream handlers are stopped. If ctx ends first, a ShutdownError reports how many
+// command goroutines were still running, and the background goroutines are left running so
+// those commands can still record their results.
+func Shutdown(ctx context.Context) error {
+	lifecycleMutex.Lock()
+	shuttingDown = true
+	lifecycleMutex.Unlock()
+
+	// No command can create a circuit once shuttingDown is set, so these are the circuits to
+	// stop. Circuits created by a later Flush and new commands are left alone.
+	circuitBreakersMutex.RLock()
+	circuits := make([]*CircuitBreaker, 0, len(circuitBreakers))
+	for _, cb := range circuitBreakers {
+		circuits = append(circuits, cb)
+	}
+	circuitBreakersMutex.RUnlock()
+
+	lifecycleMutex.Lock()
+	done := drained
+	if done == nil {
+		done = make(chan struct{})
+		if inFlight == 0 {
+			close(done)
+		} else {
+			drained = done
+		}
+	}
+	lifecycleMutex.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		lifecycleMutex.Lock()
+		remaining := inFlight
+		lifecycleMutex.Unlock()
+		if remaining > 0 {
+			return ShutdownError{Remaining: remaining, Err: ctx.Err()}
+		}
+	}
+
+	for _, cb := range circuits {
+		cb.metrics.stop()
+		cb.executorPool.Metrics.stop()
+	}
+	stopStreamHandlers()
+	flushEventListeners()
+
+	return nil
+}
+
+// beginCommand reserves n in-flight goroutines for a command, returning the generation to pass
+// to endCommand. It returns false once Shutdown has been called.
+func beginCommand(n int) (int, bool) {
+	lifecycleMutex.Lock()
+	defer lifecycleMutex.Unlock()
+
+	if shuttingDown {
+		return 0, false
+	}
+	inFlight += n
+	return generation, true
+}
+
+// endCommand releases a goroutine reserved by beginCommand.
+func endCommand(gen int) {
+	lifecycleMutex.Lock()
+	defer lifecycleMutex.Unlock()
+
+	if gen != generation {
+		return
+	}
+	inFlight--
+	if inFlight == 0 && drained != nil {
+		close(drained)
+		drained = nil
+	}
+}
+
+// resetShutdown lets commands run again after Shutdown, and forgets about any commands which
+// are still running. It is used by Flush.
+func resetShutdown() {
+	lifecycleMutex.Lock()
+	defer lifecycleMutex.Unlock()
+
+	shuttingDown = false
+	generation++
+	inFlight = 0
+	if drained != nil {
+		close(drained)
+		drained = nil
+	}
+}
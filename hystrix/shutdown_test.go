@@ -0,0 +1,106 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShutdown(t *testing.T) {
+	Convey("with a command which is still running", t, func() {
+		Flush()
+		defer Flush()
+
+		release := make(chan struct{})
+		var releaseOnce sync.Once
+		releaseRun := func() {
+			releaseOnce.Do(func() { close(release) })
+		}
+		// release the command even when an assertion fails, so it cannot hold up other tests
+		defer releaseRun()
+		started := make(chan struct{})
+		running := Go("", func() error {
+			close(started)
+			<-release
+			return nil
+		}, nil)
+		<-started
+
+		Convey("Shutdown waits for it to finish", func() {
+			shutdown := make(chan error, 1)
+			go func() {
+				shutdown <- Shutdown(context.Background())
+			}()
+			// wait for Shutdown to start refusing commands
+			for beganShutdown := false; !beganShutdown; time.Sleep(time.Millisecond) {
+				lifecycleMutex.Lock()
+				beganShutdown = shuttingDown
+				lifecycleMutex.Unlock()
+			}
+
+			Convey("while new commands go to their fallback with ErrShuttingDown", func() {
+				var fallbackErr error
+				err := Do("new", func() error {
+					return nil
+				}, func(err error) error {
+					fallbackErr = err
+					return nil
+				})
+				So(err, ShouldBeNil)
+				So(errors.Is(fallbackErr, ErrShuttingDown), ShouldBeTrue)
+				_, created := lookupCircuit("new")
+				So(created, ShouldBeFalse)
+
+				select {
+				case <-shutdown:
+					t.Fatal("Shutdown returned before the running command finished")
+				case <-time.After(20 * time.Millisecond):
+				}
+
+				releaseRun()
+				So(<-running, ShouldBeNil)
+				So(<-shutdown, ShouldBeNil)
+			})
+		})
+
+		Convey("Shutdown gives up once its context ends, reporting what was still running", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err := Shutdown(ctx)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			// the run goroutine, and the goroutine watching for its timeout
+			So(err.(ShutdownError).Remaining, ShouldEqual, 2)
+
+			releaseRun()
+			So(<-running, ShouldBeNil)
+		})
+	})
+
+	Convey("after everything has finished", t, func() {
+		Flush()
+		defer Flush()
+
+		So(Do("", func() error { return nil }, nil), ShouldBeNil)
+		handler := NewStreamHandler()
+		handler.Start()
+
+		Convey("Shutdown returns straight away, and can be called again", func() {
+			So(Shutdown(context.Background()), ShouldBeNil)
+			So(Shutdown(context.Background()), ShouldBeNil)
+		})
+
+		Convey("Shutdown stops stream handlers", func() {
+			So(Shutdown(context.Background()), ShouldBeNil)
+
+			streamHandlersMutex.Lock()
+			_, running := streamHandlers[handler]
+			streamHandlersMutex.Unlock()
+			So(running, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package hystrix
+
+import (
+	"math"
+	"time"
+
+	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
+)
+
+// CommandState is what ExportState saves of a command's circuit, for ImportState to restore in
+// the next process, such as across a deploy, so that a dependency known to be down is not
+// hammered while the new process learns so again. It encodes as JSON.
+type CommandState struct {
+	Name string `json:"name"`
+	// State is the circuit's state as decided by its health, and Since is when it moved into it.
+	State CircuitState `json:"state"`
+	Since time.Time    `json:"since"`
+	// Requests and ErrorPercentage are the circuit's health over its rolling window.
+	Requests        uint64 `json:"requests"`
+	ErrorPercentage int    `json:"error_percentage"`
+}
+
+// ExportState returns the state of every command which has a circuit, sorted by name. Commands
+// with their own Breaker are left out, since their state is the Breaker's.
+func ExportState() []CommandState {
+	now := getClock().Now()
+	var states []CommandState
+	for _, cb := range sortedCircuits() {
+		if cb.breaker() != nil {
+			continue
+		}
+		state, since := cb.StateSince()
+		health := cb.metrics.Health(now)
+		states = append(states, CommandState{
+			Name:            cb.Name,
+			State:           state,
+			Since:           since,
+			Requests:        health.Total,
+			ErrorPercentage: health.ErrorPercentage,
+		})
+	}
+
+	return states
+}
+
+// ImportState restores the circuits saved by ExportState, creating them if need be, and is meant
+// to be called during boot, after the commands have been configured. Each circuit's state is
+// restored, and its rolling counts are replaced by the saved requests and errors, counted as if
+// they had just happened, so its health is judged as before until they age out of the window.
+// Lifetime counts, metric collectors other than the default, and ForceOpen and ForceClose are
+// left alone, and state change hooks are not called.
+//
+// An open circuit keeps the time it opened, so its sleep window carries on where it left off. A
+// half-open circuit's probes were lost with the old process, so it is restored as open with its
+// sleep window over, and the next execution tests it.
+//
+// Saved states may come from anywhere, so they are clamped into range: an unknown State is taken
+// as closed, an ErrorPercentage as lying between 0 and 100, and a Since which is unset or in the
+// future as now. Commands with their own Breaker, and under RequireRegistration commands which
+// are not registered, are skipped.
+func ImportState(states []CommandState) {
+	now := getClock().Now()
+	for _, s := range states {
+		if RequireRegistration && !isRegistered(s.Name) {
+			continue
+		}
+		cb, _, err := GetCircuit(s.Name)
+		if err != nil || cb.breaker() != nil {
+			continue
+		}
+
+		if s.State != CircuitOpen && s.State != CircuitHalfOpen {
+			s.State = CircuitClosed
+		}
+		if s.ErrorPercentage < 0 {
+			s.ErrorPercentage = 0
+		} else if s.ErrorPercentage > 100 {
+			s.ErrorPercentage = 100
+		}
+		if s.Requests > math.MaxInt32 {
+			s.Requests = math.MaxInt32
+		}
+		if s.Since.IsZero() || s.Since.After(now) {
+			s.Since = now
+		}
+
+		cb.restore(s)
+	}
+}
+
+// restore puts the circuit in the state s, which ImportState has checked.
+func (circuit *CircuitBreaker) restore(s CommandState) {
+	circuit.mutex.Lock()
+	circuit.open = s.State != CircuitClosed
+	circuit.halfOpen = false
+	circuit.halfOpenProbes = 0
+	circuit.halfOpenSuccesses = 0
+	circuit.halfOpenFailures = 0
+	circuit.consecutiveFailures = 0
+	circuit.openedOrLastTestedTime = s.Since.UnixNano()
+	circuit.sleepJitter = sleepJitter(getSettings(circuit.Name).SleepWindowJitter)
+	if s.State == CircuitHalfOpen {
+		// any time at all is past the sleep window
+		circuit.openedOrLastTestedTime = 0
+	}
+	circuit.stateSince = s.Since
+	circuit.mutex.Unlock()
+	circuit.recovery.reset()
+
+	log.Infof("hystrix-go: restoring circuit %v as %v", circuit.Name, s.State)
+	errs := uint64(math.Round(float64(s.Requests) * float64(s.ErrorPercentage) / 100))
+	circuit.metrics.restore(s.Requests, errs)
+}
+
+// restore replaces the rolling counts with requests executions, errs of which failed.
+func (m *metricExchange) restore(requests, errs uint64) {
+	m.Reset()
+
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+
+	if m.counts != nil {
+		if size := uint64(len(m.counts.outcomes)); requests > size {
+			errs = errs * size / requests
+			requests = size
+		}
+		for i := uint64(0); i < requests; i++ {
+			m.counts.add(requestOutcome{err: i < errs})
+		}
+		return
+	}
+
+	m.DefaultCollector().Update(metricCollector.MetricResult{
+		Attempts:  float64(requests),
+		Errors:    float64(errs),
+		Successes: float64(requests - errs),
+		Failures:  float64(errs),
+	})
+}
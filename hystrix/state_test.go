@@ -0,0 +1,93 @@
+package hystrix
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExportImportState(t *testing.T) {
+	Convey("with a circuit opened by failures", t, func() {
+		defer Flush()
+		clock := newFakeClock()
+		SetClock(clock)
+		defer SetClock(nil)
+
+		ConfigureCommand("state", CommandConfig{RequestVolumeThreshold: 10, SleepWindow: 5000})
+		for i := 0; i < 10; i++ {
+			ReportEvent("state", OutcomeFailure, time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		So(IsOpen("state"), ShouldBeTrue)
+		opened := clock.Now()
+
+		Convey("ExportState saves its state and health", func() {
+			states := ExportState()
+			So(states, ShouldResemble, []CommandState{{
+				Name:            "state",
+				State:           CircuitOpen,
+				Since:           opened,
+				Requests:        10,
+				ErrorPercentage: 100,
+			}})
+
+			Convey("which ImportState restores after a restart", func() {
+				body, err := json.Marshal(states)
+				So(err, ShouldBeNil)
+				Flush()
+				ConfigureCommand("state", CommandConfig{RequestVolumeThreshold: 10, SleepWindow: 5000})
+				clock.Advance(time.Second)
+
+				var restored []CommandState
+				So(json.Unmarshal(body, &restored), ShouldBeNil)
+				ImportState(restored)
+				So(GetState("state"), ShouldEqual, CircuitOpen)
+				So(GetHealth("state").ErrorPercentage, ShouldEqual, 100)
+				So(GetHealth("state").Total, ShouldEqual, 10)
+
+				Convey("with the sleep window carrying on from when it opened", func() {
+					cb, _, _ := GetCircuit("state")
+					So(cb.AllowRequest(), ShouldBeFalse)
+					clock.Advance(4500 * time.Millisecond)
+					So(cb.AllowRequest(), ShouldBeTrue)
+				})
+			})
+		})
+	})
+
+	Convey("ImportState", t, func() {
+		defer Flush()
+
+		Convey("restores a half-open circuit as open, testing it on the next execution", func() {
+			ImportState([]CommandState{{Name: "state", State: CircuitHalfOpen, Since: time.Now()}})
+			cb, _, _ := GetCircuit("state")
+			So(cb.State(), ShouldEqual, CircuitOpen)
+			So(cb.AllowRequest(), ShouldBeTrue)
+			So(cb.AllowRequest(), ShouldBeFalse)
+		})
+
+		Convey("restores a closed circuit with its error rate", func() {
+			ImportState([]CommandState{{Name: "state", State: CircuitClosed, Requests: 20, ErrorPercentage: 25}})
+			So(GetState("state"), ShouldEqual, CircuitClosed)
+			So(GetHealth("state").Errors, ShouldEqual, 5)
+		})
+
+		Convey("clamps out of range values", func() {
+			future := time.Now().Add(time.Hour)
+			ImportState([]CommandState{{Name: "state", State: CircuitState(7), Since: future, Requests: 10, ErrorPercentage: 250}})
+			state, since := GetCircuitStatuses()[0].State, GetCircuitStatuses()[0].StateSince
+			So(state, ShouldEqual, "closed")
+			So(since.Before(future), ShouldBeTrue)
+			So(GetHealth("state").ErrorPercentage, ShouldEqual, 100)
+		})
+
+		Convey("skips commands which are not registered under RequireRegistration", func() {
+			RequireRegistration = true
+			defer func() { RequireRegistration = false }()
+			ImportState([]CommandState{{Name: "unregistered", State: CircuitOpen}})
+			So(GetCircuitStatuses(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package hystrix
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// CircuitStatus is a snapshot of a single command's circuit. Durations are in nanoseconds when
+// encoded as JSON.
+type CircuitStatus struct {
+	Name string
+	// State is "closed", "open" or "half-open", as decided by the circuit's health, and
+	// StateSince is when the circuit moved into it.
+	State       string
+	StateSince  time.Time
+	ForceOpen   bool
+	ForceClosed bool
+	// Enabled is false for a command disabled with SetEnabled, whose executions bypass the
+	// circuit.
+	Enabled bool
+
+	Health           HealthCounts
+	Metrics          Metrics
+	Latencies        Latencies
+	ConcurrencyInUse int
+	MaxConcurrency   int
+
+	// Settings are the command's settings after defaults have been applied.
+	Settings Settings
+}
+
+// GetCircuitStatuses returns the status of every command which has a circuit, sorted by name.
+// The counts, health and latencies of each command are read at the same instant.
+func GetCircuitStatuses() []CircuitStatus {
+	circuits := sortedCircuits()
+	now := getClock().Now()
+	statuses := make([]CircuitStatus, 0, len(circuits))
+	for _, cb := range circuits {
+		status := CircuitStatus{
+			Name:             cb.Name,
+			ConcurrencyInUse: cb.executorPool.ActiveCount(),
+			MaxConcurrency:   cb.executorPool.limit(),
+			Settings:         *getSettings(cb.Name),
+			Enabled:          IsEnabled(cb.Name),
+		}
+		state, since := cb.StateSince()
+		status.State, status.StateSince = state.String(), since
+		status.ForceOpen, status.ForceClosed = cb.forced()
+		status.Metrics, status.Health, status.Latencies = cb.metrics.status(now)
+		status.Metrics.Concurrency = cb.executorPool.Metrics.concurrency(now)
+		status.Metrics.Queue = cb.executorPool.queue(now)
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// Walk calls fn with the name, state and health of every command which has a circuit, in order
+// of name. Every command is read before fn is first called, at the same instant, and no locks
+// are held while fn runs, so it may execute or configure commands itself. It is a lighter
+// alternative to GetCircuitStatuses for listing commands without knowing their names.
+func Walk(fn func(name string, state CircuitState, health HealthCounts)) {
+	type summary struct {
+		name   string
+		state  CircuitState
+		health HealthCounts
+	}
+
+	circuits := sortedCircuits()
+	now := getClock().Now()
+	summaries := make([]summary, 0, len(circuits))
+	for _, cb := range circuits {
+		state, _ := cb.StateSince()
+		summaries = append(summaries, summary{cb.Name, state, cb.metrics.Health(now)})
+	}
+
+	for _, s := range summaries {
+		fn(s.name, s.state, s.health)
+	}
+}
+
+// sortedCircuits returns every circuit, sorted by name.
+func sortedCircuits() []*CircuitBreaker {
+	circuitBreakersMutex.RLock()
+	circuits := make([]*CircuitBreaker, 0, len(circuitBreakers))
+	for _, cb := range circuitBreakers {
+		circuits = append(circuits, cb)
+	}
+	circuitBreakersMutex.RUnlock()
+
+	sort.Slice(circuits, func(i, j int) bool {
+		return circuits[i].Name < circuits[j].Name
+	})
+	return circuits
+}
+
+// StatusHandler responds with a JSON array of every command's CircuitStatus. Unlike a
+// StreamHandler it answers once, which suits curl and scrapers.
+func StatusHandler(rw http.ResponseWriter, req *http.Request) {
+	body, err := json.Marshal(GetCircuitStatuses())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(body)
+}
@@ -0,0 +1,60 @@
+package hystrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatusHandler(t *testing.T) {
+	Convey("with two commands which have run", t, func() {
+		defer Flush()
+		ConfigureCommand("status_b", CommandConfig{ErrorPercentThreshold: 30, IsFailure: func(err error) bool { return true }})
+
+		Do("status_b", func() error { return fmt.Errorf("run_error") }, nil)
+		Do("status_a", func() error { return nil }, nil)
+		ForceOpen("status_a")
+		time.Sleep(10 * time.Millisecond)
+
+		Convey("the status handler serves a snapshot of every circuit", func() {
+			rec := httptest.NewRecorder()
+			StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(rec.Header().Get("Content-Type"), ShouldEqual, "application/json")
+
+			var statuses []CircuitStatus
+			So(json.Unmarshal(rec.Body.Bytes(), &statuses), ShouldBeNil)
+			So(len(statuses), ShouldEqual, 2)
+
+			So(statuses[0].Name, ShouldEqual, "status_a")
+			So(statuses[0].ForceOpen, ShouldBeTrue)
+			So(statuses[0].Metrics.Successes, ShouldEqual, 1)
+			So(statuses[0].MaxConcurrency, ShouldEqual, DefaultMaxConcurrent)
+
+			So(statuses[1].Name, ShouldEqual, "status_b")
+			So(statuses[1].State, ShouldEqual, "closed")
+			So(statuses[1].Health, ShouldResemble, HealthCounts{Total: 1, Errors: 1, ErrorPercentage: 100})
+			So(statuses[1].Settings.ErrorPercentThreshold, ShouldEqual, 30)
+		})
+
+		Convey("Walk visits every circuit in order of name", func() {
+			var names []string
+			var health []HealthCounts
+			Walk(func(name string, state CircuitState, h HealthCounts) {
+				names = append(names, name)
+				health = append(health, h)
+				So(state, ShouldEqual, CircuitClosed)
+
+				// no locks are held, so the callback can use the commands itself
+				Do(name, func() error { return nil }, nil)
+			})
+			So(names, ShouldResemble, []string{"status_a", "status_b"})
+			So(health[1], ShouldResemble, HealthCounts{Total: 1, Errors: 1, ErrorPercentage: 100})
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package hystrix
+
+import (
+	"context"
+)
+
+type tagsContextKey struct{}
+
+// WithTags returns a context whose commands are labeled with tags, on top of the Tags
+// configured for each command. A tag set here replaces a configured tag with the same key.
+//
+// Tags are passed to event listeners and metric collectors, so that one command run against
+// several backends can be broken down by backend. They never change which circuit a command
+// uses. Commands which should trip separately for each backend need a name for each.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	if parent, ok := ctx.Value(tagsContextKey{}).(map[string]string); ok {
+		tags = mergeTags(parent, tags)
+	}
+	return context.WithValue(ctx, tagsContextKey{}, tags)
+}
+
+// tagsFor returns the tags of the named command executed with ctx. The result must not be
+// modified, since it may be the command's configured Tags. Unlike getSettings, it does not
+// configure a command which has not been, so RequireRegistration can still reject it.
+func tagsFor(ctx context.Context, name string) map[string]string {
+	var configured map[string]string
+	settingsMutex.RLock()
+	if s, ok := circuitSettings[canonicalName(name)]; ok {
+		configured = s.Tags
+	}
+	settingsMutex.RUnlock()
+
+	tags, ok := ctx.Value(tagsContextKey{}).(map[string]string)
+	if !ok {
+		return configured
+	}
+	return mergeTags(configured, tags)
+}
+
+// mergeTags returns a new map holding the tags of base, replaced and added to by those of top,
+// or nil if there are none.
+func mergeTags(base, top map[string]string) map[string]string {
+	if len(base) == 0 && len(top) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(top))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range top {
+		merged[k] = v
+	}
+	return merged
+}
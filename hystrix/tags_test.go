@@ -0,0 +1,72 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/afex/hystrix-go/hystrix/metric_collector"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recordingCollector struct {
+	results []metricCollector.MetricResult
+}
+
+func (c *recordingCollector) Update(r metricCollector.MetricResult) {
+	c.results = append(c.results, r)
+}
+
+func (c *recordingCollector) Reset() {}
+
+func TestTags(t *testing.T) {
+	Convey("with a command tagged with its service", t, func() {
+		defer Flush()
+		ConfigureCommand("payments", CommandConfig{Tags: map[string]string{"service": "payments", "region": "default"}})
+
+		events := make(chan Event, 10)
+		RegisterEventListener(EventListenerFunc(func(e Event) {
+			events <- e
+		}))
+		next := func() Event {
+			select {
+			case e := <-events:
+				return e
+			case <-time.After(time.Second):
+				return Event{}
+			}
+		}
+
+		Convey("its events carry the configured tags", func() {
+			So(Do("payments", func() error { return nil }, nil), ShouldBeNil)
+			So(next().Tags, ShouldResemble, map[string]string{"service": "payments", "region": "default"})
+			So(next().Tags, ShouldResemble, map[string]string{"service": "payments", "region": "default"})
+		})
+
+		Convey("tags added to the context are merged over them", func() {
+			ctx := WithTags(context.Background(), map[string]string{"region": "eu"})
+			So(DoC(ctx, "payments", func(ctx context.Context) error { return nil }, nil), ShouldBeNil)
+			So(next().Tags, ShouldResemble, map[string]string{"service": "payments", "region": "eu"})
+		})
+
+		Convey("tags do not change which circuit is used", func() {
+			ctx := WithTags(context.Background(), map[string]string{"region": "eu"})
+			So(DoC(ctx, "payments", func(ctx context.Context) error { return nil }, nil), ShouldBeNil)
+			So(RegisteredCommands(), ShouldResemble, []string{"payments"})
+		})
+	})
+
+	Convey("metric collectors receive the tags of each execution", t, func() {
+		defer Flush()
+		m := newMetricExchange("tagged")
+		collector := &recordingCollector{}
+
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		m.IncrementMetrics(wg, collector, &commandExecution{Types: []string{"success"}, Tags: map[string]string{"region": "eu"}}, 0)
+
+		So(collector.results, ShouldHaveLength, 1)
+		So(collector.results[0].Tags, ShouldResemble, map[string]string{"region": "eu"})
+	})
+}
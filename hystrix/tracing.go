@@ -0,0 +1,56 @@
+package hystrix
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracer starts a span for each command execution, so traces show where hystrix intervened.
+// It lets any tracing library, such as OpenTelemetry, be plugged in with a small adapter.
+type Tracer interface {
+	// StartSpan starts a span named after the command, as a child of any span in ctx. The
+	// returned context is passed to run and fallback functions.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced command execution.
+type Span interface {
+	// End is called once the command has finished.
+	End(SpanResult)
+}
+
+// SpanResult describes how a traced command execution finished.
+type SpanResult struct {
+	// Outcome is the command's first event, such as "success", "timeout", "rejected" or
+	// "short-circuit".
+	Outcome string
+	// Fallback is the fallback's event, such as "fallback-success", or empty if no fallback ran.
+	Fallback string
+	// Duration is how long the command took, including any fallback.
+	Duration time.Duration
+	// Err is the error returned to the caller, or nil.
+	Err error
+}
+
+var tracer Tracer
+var tracerMutex *sync.RWMutex
+
+func init() {
+	tracerMutex = &sync.RWMutex{}
+}
+
+// SetTracer sets the tracer used for every command execution. Passing nil disables tracing,
+// which is the default.
+func SetTracer(t Tracer) {
+	tracerMutex.Lock()
+	tracer = t
+	tracerMutex.Unlock()
+}
+
+func getTracer() Tracer {
+	tracerMutex.RLock()
+	defer tracerMutex.RUnlock()
+
+	return tracer
+}
@@ -0,0 +1,98 @@
+package hystrix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type spanKey struct{}
+
+// recordingTracer is called from command goroutines, so it guards what it records.
+type recordingTracer struct {
+	mu      sync.Mutex
+	names   []string
+	results []SpanResult
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	t.names = append(t.names, name)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, name), recordingSpan{t}
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+}
+
+func (s recordingSpan) End(result SpanResult) {
+	s.tracer.mu.Lock()
+	s.tracer.results = append(s.tracer.results, result)
+	s.tracer.mu.Unlock()
+}
+
+func (t *recordingTracer) recorded() ([]string, []SpanResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string(nil), t.names...), append([]SpanResult(nil), t.results...)
+}
+
+func TestTracer(t *testing.T) {
+	Convey("with a tracer set", t, func() {
+		defer Flush()
+		tracer := &recordingTracer{}
+		SetTracer(tracer)
+		defer SetTracer(nil)
+
+		Convey("a successful command starts and ends a span named after it", func() {
+			var spanName interface{}
+			errChan := GoC(context.Background(), "traced", func(ctx context.Context) error {
+				spanName = ctx.Value(spanKey{})
+				return nil
+			}, nil)
+			for range errChan {
+			}
+
+			names, results := tracer.recorded()
+			So(spanName, ShouldEqual, "traced")
+			So(names, ShouldResemble, []string{"traced"})
+			So(len(results), ShouldEqual, 1)
+			So(results[0].Outcome, ShouldEqual, "success")
+			So(results[0].Fallback, ShouldEqual, "")
+			So(results[0].Err, ShouldBeNil)
+		})
+
+		Convey("a failed command records its fallback and the error returned", func() {
+			errChan := Go("traced", func() error {
+				return fmt.Errorf("run_error")
+			}, func(err error) error {
+				return fmt.Errorf("fallback_error")
+			})
+			err := <-errChan
+			for range errChan {
+			}
+
+			_, results := tracer.recorded()
+			So(len(results), ShouldEqual, 1)
+			So(results[0].Outcome, ShouldEqual, "failure")
+			So(results[0].Fallback, ShouldEqual, "fallback-failure")
+			So(results[0].Err, ShouldEqual, err)
+		})
+
+		Convey("a short circuited command is traced", func() {
+			ForceOpen("traced")
+			for range Go("traced", func() error { return nil }, nil) {
+			}
+
+			_, results := tracer.recorded()
+			So(len(results), ShouldEqual, 1)
+			So(results[0].Outcome, ShouldEqual, "short-circuit")
+			So(results[0].Err, ShouldResemble, ErrCircuitOpen)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+//go:build go1.18
+// +build go1.18
+
+package hystrix
+
+import (
+	"context"
+)
+
+// DoTyped runs your function in a synchronous manner like Do, returning the value produced by
+// run, or by fallback if it was used. If the command fails, the zero value is returned with the error.
+func DoTyped[T any](name string, run func() (T, error), fallback func(error) (T, error)) (T, error) {
+	runC := func(ctx context.Context) (T, error) {
+		return run()
+	}
+	var fallbackC func(context.Context, error) (T, error)
+	if fallback != nil {
+		fallbackC = func(ctx context.Context, err error) (T, error) {
+			return fallback(err)
+		}
+	}
+	return DoTypedC(context.Background(), name, runC, fallbackC)
+}
+
+// RegisterResultCheck sets a check for the values run of the named command returns with a nil
+// error, for dependencies which signal failure in the value itself, such as a body reporting an
+// error. Executions of the command with DoTyped or DoTypedC whose value check rejects fail with
+// ErrUnsuccessfulResult, counting against the circuit and going to the fallback. The check only
+// applies to commands executed with the same T. Registering nil removes it.
+//
+// The check is called on the goroutine which ran run, and must not block.
+func RegisterResultCheck[T any](name string, check func(T) bool) {
+	if check == nil {
+		setResultCheck(name, nil)
+		return
+	}
+	setResultCheck(name, check)
+}
+
+// DoTypedC runs your function in a synchronous manner like DoC, returning the value produced by
+// run, or by fallback if it was used. If the command fails, the zero value is returned with the error.
+func DoTypedC[T any](ctx context.Context, name string, run func(context.Context) (T, error), fallback func(context.Context, error) (T, error)) (T, error) {
+	name = canonicalName(name)
+	// The value is cached here rather than by GoC, which would only know that run succeeded.
+	cache, cacheKey, ctx := requestCacheFor(ctx, name)
+	if cache != nil {
+		if v, ok := cache.get(cacheKey); ok {
+			if value, ok := v.(T); ok {
+				emitEvent(Event{Name: name, Type: "response-from-cache", Tags: tagsFor(ctx, name)})
+				return value, nil
+			}
+		}
+	}
+
+	check, _ := resultCheck(name).(func(T) bool)
+	var captured capturedValue
+	r := captured.wrapRun(func(ctx context.Context) (interface{}, error) {
+		v, err := run(ctx)
+		if err == nil && check != nil && !check(v) {
+			return nil, ErrUnsuccessfulResult
+		}
+		return v, err
+	})
+	var fallbackV func(context.Context, error) (interface{}, error)
+	if fallback != nil {
+		fallbackV = func(ctx context.Context, err error) (interface{}, error) {
+			return fallback(ctx, err)
+		}
+	}
+	f := captured.wrapFallback(name, fallbackV)
+
+	// Unlike DoC, wait for errChan rather than for run to return. A run which succeeds after
+	// the command timed out would otherwise return before the fallback had produced its value.
+	// errChan is only closed once the function which completed the command has returned.
+	err := <-goC(ctx, name, r, f, true)
+	repanic(err)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	result, fromRun := captured.result()
+	if cache != nil && fromRun {
+		cache.set(cacheKey, result)
+	}
+	value, _ := result.(T)
+	return value, nil
+}
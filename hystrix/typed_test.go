@@ -0,0 +1,216 @@
+//go:build go1.18
+// +build go1.18
+
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDoTyped(t *testing.T) {
+	Convey("with a typed command", t, func() {
+		defer Flush()
+
+		Convey("a successful run returns its value", func() {
+			v, err := DoTyped("", func() (int, error) {
+				return 1, nil
+			}, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 1)
+		})
+
+		Convey("a failed run returns the fallback's value", func() {
+			v, err := DoTyped("", func() (string, error) {
+				return "run", fmt.Errorf("run_error")
+			}, func(err error) (string, error) {
+				return "fallback", nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "fallback")
+		})
+
+		Convey("a failed run served by a registered fallback returns the zero value", func() {
+			RegisterFallback("", func(err error) error { return nil })
+			v, err := DoTyped("", func() (string, error) {
+				return "partial", fmt.Errorf("run_error")
+			}, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "")
+		})
+
+		Convey("a failed fallback returns the zero value and the error", func() {
+			v, err := DoTyped("", func() (int, error) {
+				return 1, fmt.Errorf("run_error")
+			}, func(err error) (int, error) {
+				return 2, fmt.Errorf("fallback_error")
+			})
+			So(err, ShouldNotBeNil)
+			So(v, ShouldEqual, 0)
+		})
+
+		Convey("a timed out run cannot overwrite the fallback's value", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 10})
+			finished := make(chan struct{})
+
+			v, err := DoTyped("", func() (string, error) {
+				defer close(finished)
+				time.Sleep(50 * time.Millisecond)
+				return "run", nil
+			}, func(err error) (string, error) {
+				return "fallback", nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "fallback")
+			<-finished
+		})
+
+		Convey("a run which succeeds late while a slow fallback runs still returns the fallback's value", func() {
+			ConfigureCommand("", CommandConfig{Timeout: 10})
+			finished := make(chan struct{})
+
+			v, err := DoTyped("", func() (string, error) {
+				defer close(finished)
+				time.Sleep(20 * time.Millisecond)
+				return "run", nil
+			}, func(err error) (string, error) {
+				time.Sleep(60 * time.Millisecond)
+				return "fallback", nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "fallback")
+			<-finished
+		})
+
+		Convey("a short-circuited command returns the fallback's value", func() {
+			ForceOpen("")
+			var fallbackErr error
+			v, err := DoTyped("", func() (int, error) {
+				return 1, nil
+			}, func(err error) (int, error) {
+				fallbackErr = err
+				return 2, nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 2)
+			So(fallbackErr, ShouldResemble, &CommandError{Kind: KindShortCircuit, Err: ErrCircuitOpen})
+		})
+
+		Convey("a panic propagated under Propagate is re-panicked on the caller's goroutine", func() {
+			SetPanicHandling(Propagate)
+			defer SetPanicHandling(RecoverAsError)
+
+			var value interface{}
+			func() {
+				defer func() {
+					value = recover()
+				}()
+				DoTyped("", func() (int, error) {
+					panic("boom")
+				}, nil)
+			}()
+			So(value, ShouldEqual, "boom")
+		})
+	})
+}
+
+func TestDoTypedRequestCache(t *testing.T) {
+	Convey("with a typed command executed with a request cache and key", t, func() {
+		defer Flush()
+		ctx := WithCacheKey(WithRequestCache(context.Background(), 0), "42")
+
+		runs := 0
+		run := func(ctx context.Context) (string, error) {
+			runs++
+			return fmt.Sprintf("user %d", runs), nil
+		}
+
+		Convey("a second execution returns the cached value without running", func() {
+			v, err := DoTypedC(ctx, "get_user", run, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "user 1")
+
+			v, err = DoTypedC(ctx, "get_user", run, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "user 1")
+			So(runs, ShouldEqual, 1)
+		})
+
+		Convey("a value from the fallback is not cached", func() {
+			v, err := DoTypedC(ctx, "get_user", func(ctx context.Context) (string, error) {
+				return "", fmt.Errorf("run_error")
+			}, func(ctx context.Context, err error) (string, error) {
+				return "guest", nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "guest")
+
+			v, err = DoTypedC(ctx, "get_user", run, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "user 1")
+		})
+	})
+}
+
+func TestRegisterResultCheck(t *testing.T) {
+	Convey("with a typed command whose values are checked", t, func() {
+		defer Flush()
+		RegisterResultCheck("checked", func(status int) bool {
+			return status < 500
+		})
+
+		Convey("a value the check accepts is returned", func() {
+			v, err := DoTyped("checked", func() (int, error) {
+				return 200, nil
+			}, nil)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 200)
+		})
+
+		Convey("a value the check rejects fails the command", func() {
+			v, err := DoTyped("checked", func() (int, error) {
+				return 503, nil
+			}, nil)
+			So(err, ShouldEqual, ErrUnsuccessfulResult)
+			So(v, ShouldEqual, 0)
+
+			Convey("and counts against the circuit", func() {
+				time.Sleep(10 * time.Millisecond)
+				So(GetMetrics("checked").Failures, ShouldEqual, 1)
+			})
+		})
+
+		Convey("a value the check rejects goes to the fallback", func() {
+			var reason error
+			v, err := DoTyped("checked", func() (int, error) {
+				return 503, nil
+			}, func(err error) (int, error) {
+				reason = err
+				return 204, nil
+			})
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 204)
+			So(errors.Is(reason, ErrUnsuccessfulResult), ShouldBeTrue)
+		})
+
+		Convey("commands executed with another type are not checked", func() {
+			_, err := DoTyped("checked", func() (string, error) {
+				return "503", nil
+			}, nil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("registering nil removes the check", func() {
+			RegisterResultCheck[int]("checked", nil)
+			_, err := DoTyped("checked", func() (int, error) {
+				return 503, nil
+			}, nil)
+			So(err, ShouldBeNil)
+		})
+	})
+}
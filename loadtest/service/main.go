@@ -87,8 +87,13 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	})
 
 	select {
-	case err := <-errChan:
-		http.Error(w, err.Error(), 500)
+	case err, ok := <-errChan:
+		// the channel is closed without an error once the command succeeds
+		if ok && err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Write([]byte("OK"))
 	case <-done:
 		w.Write([]byte("OK"))
 	}
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"sort"
 
 	// Developed on https://github.com/DataDog/datadog-go/tree/a27810dd518c69be741a7fd5d0e39f674f615be8
 	"github.com/DataDog/datadog-go/statsd"
@@ -58,9 +59,14 @@ type (
 	//   }
 	//
 	// As new circuits come online you get graphing and monitoring "for free".
+	//
+	// Every metric is tagged with hystrixcircuit:<name> and with
+	// hystrixcircuitstate:<state>, the circuit's state once the execution was
+	// recorded, followed by the execution's own tags.
 	DatadogCollector struct {
-		client DatadogClient
-		tags   []string
+		client     DatadogClient
+		tags       []string
+		sampleRate float64
 	}
 )
 
@@ -104,52 +110,74 @@ func NewDatadogCollector(addr, prefix string) (func(string) metricCollector.Metr
 // "github.com/DataDog/datadog-go/statsd".(*Client), provide additional tags per
 // circuit-metric tuple, and add logging if you need it.
 func NewDatadogCollectorWithClient(client DatadogClient) func(string) metricCollector.MetricCollector {
+	return NewDatadogCollectorWithSampleRate(client, 1.0)
+}
+
+// NewDatadogCollectorWithSampleRate is like NewDatadogCollectorWithClient, but
+// sends only a sampleRate fraction of metrics so that high traffic circuits
+// don't flood the agent. A sampleRate of 0 sends every metric.
+func NewDatadogCollectorWithSampleRate(client DatadogClient, sampleRate float64) func(string) metricCollector.MetricCollector {
+	if sampleRate == 0 {
+		sampleRate = 1.0
+	}
 
 	return func(name string) metricCollector.MetricCollector {
 
 		return &DatadogCollector{
-			client: client,
-			tags:   []string{"hystrixcircuit:" + name},
+			client:     client,
+			tags:       []string{"hystrixcircuit:" + name},
+			sampleRate: sampleRate,
 		}
 	}
 }
 
 func (dc *DatadogCollector) Update(r metricCollector.MetricResult) {
+	tags := make([]string, 0, len(dc.tags)+len(r.Tags)+1)
+	tags = append(tags, dc.tags...)
+	if r.CircuitState != "" {
+		tags = append(tags, "hystrixcircuitstate:"+r.CircuitState)
+	}
+	execution := len(tags)
+	for k, v := range r.Tags {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags[execution:])
+
 	if r.Attempts > 0 {
-		dc.client.Count(DM_Attempts, int64(r.Attempts), dc.tags, 1.0)
+		dc.client.Count(DM_Attempts, int64(r.Attempts), tags, dc.sampleRate)
 	}
 	if r.Errors > 0 {
-		dc.client.Count(DM_Errors, int64(r.Errors), dc.tags, 1.0)
+		dc.client.Count(DM_Errors, int64(r.Errors), tags, dc.sampleRate)
 	}
 	if r.Successes > 0 {
-		dc.client.Gauge(DM_CircuitOpen, 0, dc.tags, 1.0)
-		dc.client.Count(DM_Successes, int64(r.Successes), dc.tags, 1.0)
+		dc.client.Gauge(DM_CircuitOpen, 0, tags, dc.sampleRate)
+		dc.client.Count(DM_Successes, int64(r.Successes), tags, dc.sampleRate)
 	}
 	if r.Failures > 0 {
-		dc.client.Count(DM_Failures, int64(r.Failures), dc.tags, 1.0)
+		dc.client.Count(DM_Failures, int64(r.Failures), tags, dc.sampleRate)
 	}
 	if r.Rejects > 0 {
-		dc.client.Count(DM_Rejects, int64(r.Rejects), dc.tags, 1.0)
+		dc.client.Count(DM_Rejects, int64(r.Rejects), tags, dc.sampleRate)
 	}
 	if r.ShortCircuits > 0 {
-		dc.client.Gauge(DM_CircuitOpen, 1, dc.tags, 1.0)
-		dc.client.Count(DM_ShortCircuits, int64(r.ShortCircuits), dc.tags, 1.0)
+		dc.client.Gauge(DM_CircuitOpen, 1, tags, dc.sampleRate)
+		dc.client.Count(DM_ShortCircuits, int64(r.ShortCircuits), tags, dc.sampleRate)
 	}
 	if r.Timeouts > 0 {
-		dc.client.Count(DM_Timeouts, int64(r.Timeouts), dc.tags, 1.0)
+		dc.client.Count(DM_Timeouts, int64(r.Timeouts), tags, dc.sampleRate)
 	}
 	if r.FallbackSuccesses > 0 {
-		dc.client.Count(DM_FallbackSuccesses, int64(r.FallbackSuccesses), dc.tags, 1.0)
+		dc.client.Count(DM_FallbackSuccesses, int64(r.FallbackSuccesses), tags, dc.sampleRate)
 	}
 	if r.FallbackFailures > 0 {
-		dc.client.Count(DM_FallbackFailures, int64(r.FallbackFailures), dc.tags, 1.0)
+		dc.client.Count(DM_FallbackFailures, int64(r.FallbackFailures), tags, dc.sampleRate)
 	}
 
 	ms := float64(r.TotalDuration.Nanoseconds() / 1000000)
-	dc.client.TimeInMilliseconds(DM_TotalDuration, ms, dc.tags, 1.0)
+	dc.client.TimeInMilliseconds(DM_TotalDuration, ms, tags, dc.sampleRate)
 
 	ms = float64(r.RunDuration.Nanoseconds() / 1000000)
-	dc.client.TimeInMilliseconds(DM_RunDuration, ms, dc.tags, 1.0)
+	dc.client.TimeInMilliseconds(DM_RunDuration, ms, tags, dc.sampleRate)
 }
 
 // Reset is a noop operation in this collector.
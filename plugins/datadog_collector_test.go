@@ -0,0 +1,135 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/afex/hystrix-go/hystrix/metric_collector"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recordingDatadogClient struct {
+	rates []float64
+	tags  []string
+}
+
+func (c *recordingDatadogClient) Count(name string, value int64, tags []string, rate float64) error {
+	c.rates = append(c.rates, rate)
+	c.tags = tags
+	return nil
+}
+
+func (c *recordingDatadogClient) Gauge(name string, value float64, tags []string, rate float64) error {
+	c.rates = append(c.rates, rate)
+	return nil
+}
+
+func (c *recordingDatadogClient) TimeInMilliseconds(name string, value float64, tags []string, rate float64) error {
+	c.rates = append(c.rates, rate)
+	return nil
+}
+
+func TestDatadogSampleRate(t *testing.T) {
+	Convey("when updating a datadog collector", t, func() {
+		client := &recordingDatadogClient{}
+
+		Convey("with no sample rate, every metric is sent", func() {
+			NewDatadogCollectorWithClient(client)("foo").Update(metricCollector.MetricResult{Attempts: 1, Successes: 1})
+			So(client.rates, ShouldNotBeEmpty)
+			for _, rate := range client.rates {
+				So(rate, ShouldEqual, 1.0)
+			}
+		})
+
+		Convey("with a sample rate, it is passed with every metric", func() {
+			NewDatadogCollectorWithSampleRate(client, 0.25)("foo").Update(metricCollector.MetricResult{Attempts: 1, Successes: 1})
+			So(client.rates, ShouldNotBeEmpty)
+			for _, rate := range client.rates {
+				So(rate, ShouldEqual, 0.25)
+			}
+		})
+	})
+}
+
+func TestDatadogTags(t *testing.T) {
+	Convey("when updating a datadog collector with a tagged execution", t, func() {
+		client := &recordingDatadogClient{}
+		NewDatadogCollectorWithClient(client)("foo").Update(metricCollector.MetricResult{
+			Attempts: 1,
+			Tags:     map[string]string{"region": "eu", "shard": "2"},
+		})
+
+		Convey("the execution's tags are sent after the circuit's", func() {
+			So(client.tags, ShouldResemble, []string{"hystrixcircuit:foo", "region:eu", "shard:2"})
+		})
+	})
+
+	Convey("when updating a datadog collector with the circuit's state", t, func() {
+		client := &recordingDatadogClient{}
+		NewDatadogCollectorWithClient(client)("foo").Update(metricCollector.MetricResult{
+			Attempts:     1,
+			CircuitState: "half-open",
+		})
+
+		Convey("the state is sent as a tag", func() {
+			So(client.tags, ShouldResemble, []string{"hystrixcircuit:foo", "hystrixcircuitstate:half-open"})
+		})
+	})
+}
+
+type recordingDatadogSpan struct {
+	mu       sync.Mutex
+	tags     map[string]interface{}
+	finished bool
+}
+
+func (s *recordingDatadogSpan) SetTag(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tags[key] = value
+}
+
+func (s *recordingDatadogSpan) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.finished = true
+}
+
+func TestDatadogTracer(t *testing.T) {
+	Convey("with a datadog tracer set", t, func() {
+		defer hystrix.Flush()
+		spans := make(chan *recordingDatadogSpan, 1)
+		hystrix.SetTracer(NewDatadogTracer(func(ctx context.Context, operationName string) (DatadogSpan, context.Context) {
+			span := &recordingDatadogSpan{tags: map[string]interface{}{"operation": operationName}}
+			spans <- span
+			return span, ctx
+		}))
+		defer hystrix.SetTracer(nil)
+
+		Convey("a command which falls back is traced with its outcome", func() {
+			errChan := hystrix.Go("traced", func() error {
+				return fmt.Errorf("boom")
+			}, func(err error) error {
+				return nil
+			})
+			// errChan is closed once the span has ended
+			for range errChan {
+			}
+
+			span := <-spans
+			span.mu.Lock()
+			defer span.mu.Unlock()
+			So(span.tags["operation"], ShouldEqual, "hystrix.command")
+			So(span.tags["resource.name"], ShouldEqual, "traced")
+			So(span.tags["hystrix.outcome"], ShouldEqual, "failure")
+			So(span.tags["hystrix.fallback"], ShouldEqual, "fallback-success")
+			So(span.tags, ShouldNotContainKey, "error")
+			So(span.finished, ShouldBeTrue)
+		})
+	})
+}
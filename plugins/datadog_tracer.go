@@ -0,0 +1,64 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/afex/hystrix-go/hystrix"
+)
+
+// DatadogSpan is the part of a Datadog APM span used by DatadogTracer. A
+// "gopkg.in/DataDog/dd-trace-go.v1/ddtrace".Span needs only a small wrapper,
+// since its Finish takes options:
+//
+//  type ddSpan struct{ ddtrace.Span }
+//
+//  func (s ddSpan) Finish() { s.Span.Finish() }
+type DatadogSpan interface {
+	SetTag(key string, value interface{})
+	Finish()
+}
+
+// DatadogTracer fulfills the hystrix.Tracer interface, creating a Datadog APM
+// span around every command execution. Spans are named "hystrix.command",
+// with the command's name as their resource, and are tagged with the
+// command's outcome and fallback. A command which returns an error to its
+// caller also sets the span's error.
+type DatadogTracer struct {
+	startSpan func(ctx context.Context, operationName string) (DatadogSpan, context.Context)
+}
+
+// NewDatadogTracer creates a tracer which starts spans with startSpan, which
+// usually wraps "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer".StartSpanFromContext.
+//
+// Example use
+//  hystrix.SetTracer(plugins.NewDatadogTracer(func(ctx context.Context, operationName string) (plugins.DatadogSpan, context.Context) {
+//  	span, ctx := tracer.StartSpanFromContext(ctx, operationName)
+//  	return ddSpan{span}, ctx
+//  }))
+func NewDatadogTracer(startSpan func(ctx context.Context, operationName string) (DatadogSpan, context.Context)) *DatadogTracer {
+	return &DatadogTracer{startSpan: startSpan}
+}
+
+// StartSpan implements hystrix.Tracer.
+func (t *DatadogTracer) StartSpan(ctx context.Context, name string) (context.Context, hystrix.Span) {
+	span, ctx := t.startSpan(ctx, "hystrix.command")
+	span.SetTag("resource.name", name)
+	span.SetTag("hystrix.command", name)
+
+	return ctx, datadogCommandSpan{span: span}
+}
+
+type datadogCommandSpan struct {
+	span DatadogSpan
+}
+
+func (s datadogCommandSpan) End(r hystrix.SpanResult) {
+	s.span.SetTag("hystrix.outcome", r.Outcome)
+	if r.Fallback != "" {
+		s.span.SetTag("hystrix.fallback", r.Fallback)
+	}
+	if r.Err != nil {
+		s.span.SetTag("error", r.Err)
+	}
+	s.span.Finish()
+}
@@ -0,0 +1,239 @@
+package plugins
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/afex/hystrix-go/hystrix/metric_collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusCollector fulfills the metricCollector interface allowing users to expose circuit
+// stats to a Prometheus server. It is also a prometheus.Collector, so a single value registered
+// with a prometheus.Registerer exports series for every circuit, including circuits created
+// after it was registered. Each series is labeled with the circuit name. The circuit_open gauge
+// is read from each circuit's state whenever metrics are collected.
+//
+// NewPrometheusCollectorWithLabels adds a label for each of the given command tags, so that one
+// command can be broken down by the tags of its executions.
+//
+//  c := plugins.NewPrometheusCollector("myapp")
+//  prometheus.MustRegister(c)
+//  metricCollector.Registry.Register(c.NewPrometheusCircuitCollector)
+//
+// This Collector uses https://github.com/prometheus/client_golang for transport.
+type PrometheusCollector struct {
+	attempts          *prometheus.CounterVec
+	errors            *prometheus.CounterVec
+	successes         *prometheus.CounterVec
+	failures          *prometheus.CounterVec
+	rejects           *prometheus.CounterVec
+	shortCircuits     *prometheus.CounterVec
+	timeouts          *prometheus.CounterVec
+	fallbackSuccesses *prometheus.CounterVec
+	fallbackFailures  *prometheus.CounterVec
+	fallbackRejects   *prometheus.CounterVec
+	staleFallbacks    *prometheus.CounterVec
+	slowCalls         *prometheus.CounterVec
+	contextCanceled   *prometheus.CounterVec
+	contextDeadline   *prometheus.CounterVec
+	rateLimited       *prometheus.CounterVec
+	totalDuration     *prometheus.HistogramVec
+	runDuration       *prometheus.HistogramVec
+	circuitOpen       *prometheus.GaugeVec
+	concurrencyInUse  *prometheus.GaugeVec
+	queueDepth        *prometheus.GaugeVec
+
+	labels []string
+
+	mu sync.Mutex
+	// circuits holds the label values each circuit has been seen with, keyed by their join.
+	circuits map[string]map[string][]string
+}
+
+// PrometheusCircuitCollector updates the series of a single circuit. It is created by
+// PrometheusCollector.NewPrometheusCircuitCollector.
+type PrometheusCircuitCollector struct {
+	parent *PrometheusCollector
+	name   string
+}
+
+// NewPrometheusCollector creates the metric vectors shared by all circuits. Every metric
+// name is prefixed with namespace, which may be empty.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return NewPrometheusCollectorWithLabels(namespace)
+}
+
+// NewPrometheusCollectorWithLabels creates a collector like NewPrometheusCollector, whose
+// series are also labeled with the value of each of the named command tags. Executions without
+// one of the tags leave its label empty.
+func NewPrometheusCollectorWithLabels(namespace string, labels ...string) *PrometheusCollector {
+	labelNames := append([]string{"circuit"}, labels...)
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "hystrix",
+			Name:      name,
+			Help:      help,
+		}, labelNames)
+	}
+	histogram := func(name, help string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "hystrix",
+			Name:      name,
+			Help:      help,
+		}, labelNames)
+	}
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "hystrix",
+			Name:      name,
+			Help:      help,
+		}, labelNames)
+	}
+
+	return &PrometheusCollector{
+		attempts:          counter("attempts_total", "Number of command executions attempted."),
+		errors:            counter("errors_total", "Number of command executions which counted against circuit health."),
+		successes:         counter("successes_total", "Number of command executions which succeeded."),
+		failures:          counter("failures_total", "Number of command executions whose run function returned an error."),
+		rejects:           counter("rejects_total", "Number of command executions rejected due to the concurrency limit."),
+		shortCircuits:     counter("short_circuits_total", "Number of command executions rejected due to an open circuit."),
+		timeouts:          counter("timeouts_total", "Number of command executions which timed out."),
+		fallbackSuccesses: counter("fallback_successes_total", "Number of fallbacks which succeeded."),
+		fallbackFailures:  counter("fallback_failures_total", "Number of fallbacks which returned an error."),
+		fallbackRejects:   counter("fallback_rejections_total", "Number of fallbacks skipped due to the fallback concurrency limit."),
+		staleFallbacks:    counter("fallback_stale_total", "Number of fallbacks which served stale data."),
+		slowCalls:         counter("slow_calls_total", "Number of command executions slower than the slow call duration."),
+		contextCanceled:   counter("context_canceled_total", "Number of command executions canceled by the caller."),
+		contextDeadline:   counter("context_deadline_exceeded_total", "Number of command executions whose caller deadline passed."),
+		rateLimited:       counter("rate_limited_total", "Number of command executions rejected due to the rate limit."),
+		totalDuration:     histogram("total_duration_seconds", "Time from the start of a command until its outcome was known."),
+		runDuration:       histogram("run_duration_seconds", "Time spent in the run function."),
+		circuitOpen:       gauge("circuit_open", "Whether the circuit is open (1) or closed (0)."),
+		concurrencyInUse:  gauge("concurrency_in_use_ratio", "Share of the concurrency limit in use at the last execution."),
+		queueDepth:        gauge("queue_depth", "Number of executions waiting for an executor at the last execution."),
+		labels:            labels,
+		circuits:          make(map[string]map[string][]string),
+	}
+}
+
+func (p *PrometheusCollector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		p.attempts,
+		p.errors,
+		p.successes,
+		p.failures,
+		p.rejects,
+		p.shortCircuits,
+		p.timeouts,
+		p.fallbackSuccesses,
+		p.fallbackFailures,
+		p.fallbackRejects,
+		p.staleFallbacks,
+		p.slowCalls,
+		p.contextCanceled,
+		p.contextDeadline,
+		p.rateLimited,
+		p.totalDuration,
+		p.runDuration,
+		p.circuitOpen,
+		p.concurrencyInUse,
+		p.queueDepth,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range p.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (p *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	for name, seen := range p.circuits {
+		open := 0.0
+		if hystrix.IsOpen(name) {
+			open = 1
+		}
+		for _, values := range seen {
+			p.circuitOpen.WithLabelValues(values...).Set(open)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range p.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// NewPrometheusCircuitCollector creates a collector for a specific circuit. Register it with
+// metricCollector.Registry.Register before circuits are created.
+func (p *PrometheusCollector) NewPrometheusCircuitCollector(name string) metricCollector.MetricCollector {
+	var tags map[string]string
+	if settings, ok := hystrix.GetCircuitSettings()[name]; ok {
+		tags = settings.Tags
+	}
+
+	p.labelValues(name, tags)
+
+	return &PrometheusCircuitCollector{
+		parent: p,
+		name:   name,
+	}
+}
+
+// labelValues returns the values of every label for an execution of the named circuit with
+// tags, remembering them so the circuit's circuit_open gauge is kept for them too.
+func (p *PrometheusCollector) labelValues(name string, tags map[string]string) []string {
+	values := make([]string, 0, len(p.labels)+1)
+	values = append(values, name)
+	for _, label := range p.labels {
+		values = append(values, tags[label])
+	}
+
+	key := strings.Join(values, "\x00")
+	p.mu.Lock()
+	if _, ok := p.circuits[name][key]; !ok {
+		if p.circuits[name] == nil {
+			p.circuits[name] = make(map[string][]string)
+		}
+		p.circuits[name][key] = values
+	}
+	p.mu.Unlock()
+
+	return values
+}
+
+func (c *PrometheusCircuitCollector) Update(r metricCollector.MetricResult) {
+	p := c.parent
+	values := p.labelValues(c.name, r.Tags)
+
+	p.attempts.WithLabelValues(values...).Add(r.Attempts)
+	p.errors.WithLabelValues(values...).Add(r.Errors)
+	p.successes.WithLabelValues(values...).Add(r.Successes)
+	p.failures.WithLabelValues(values...).Add(r.Failures)
+	p.rejects.WithLabelValues(values...).Add(r.Rejects)
+	p.shortCircuits.WithLabelValues(values...).Add(r.ShortCircuits)
+	p.timeouts.WithLabelValues(values...).Add(r.Timeouts)
+	p.fallbackSuccesses.WithLabelValues(values...).Add(r.FallbackSuccesses)
+	p.fallbackFailures.WithLabelValues(values...).Add(r.FallbackFailures)
+	p.fallbackRejects.WithLabelValues(values...).Add(r.FallbackRejections)
+	p.staleFallbacks.WithLabelValues(values...).Add(r.StaleFallbacks)
+	p.slowCalls.WithLabelValues(values...).Add(r.SlowCalls)
+	p.contextCanceled.WithLabelValues(values...).Add(r.ContextCanceled)
+	p.contextDeadline.WithLabelValues(values...).Add(r.ContextDeadlineExceeded)
+	p.rateLimited.WithLabelValues(values...).Add(r.RateLimited)
+	p.totalDuration.WithLabelValues(values...).Observe(r.TotalDuration.Seconds())
+	p.runDuration.WithLabelValues(values...).Observe(r.RunDuration.Seconds())
+	p.concurrencyInUse.WithLabelValues(values...).Set(r.ConcurrencyInUse)
+	p.queueDepth.WithLabelValues(values...).Set(r.QueueDepth)
+}
+
+// Reset is a noop operation in this collector.
+func (c *PrometheusCircuitCollector) Reset() {}
@@ -0,0 +1,75 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/afex/hystrix-go/hystrix/metric_collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	Convey("when a registered prometheus collector receives updates for two circuits", t, func() {
+		defer hystrix.Flush()
+		c := NewPrometheusCollector("test")
+		registry := prometheus.NewRegistry()
+		So(registry.Register(c), ShouldBeNil)
+
+		c.NewPrometheusCircuitCollector("foo").Update(metricCollector.MetricResult{Attempts: 1, Successes: 1})
+		c.NewPrometheusCircuitCollector("bar").Update(metricCollector.MetricResult{Attempts: 1, Errors: 1, ShortCircuits: 1, FallbackRejections: 1, SlowCalls: 1})
+
+		Convey("each circuit is exported under its own label", func() {
+			So(testutil.ToFloat64(c.attempts.WithLabelValues("foo")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.successes.WithLabelValues("foo")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.shortCircuits.WithLabelValues("bar")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.fallbackRejects.WithLabelValues("bar")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.slowCalls.WithLabelValues("bar")), ShouldEqual, 1)
+		})
+
+		Convey("the circuit state gauge is read from each circuit when collected", func() {
+			hystrix.ForceOpen("bar")
+			_, err := registry.Gather()
+			So(err, ShouldBeNil)
+
+			So(testutil.ToFloat64(c.circuitOpen.WithLabelValues("foo")), ShouldEqual, 0)
+			So(testutil.ToFloat64(c.circuitOpen.WithLabelValues("bar")), ShouldEqual, 1)
+		})
+
+		Convey("the registry gathers the series", func() {
+			families, err := registry.Gather()
+			So(err, ShouldBeNil)
+			So(len(families), ShouldBeGreaterThan, 0)
+		})
+	})
+}
+
+func TestPrometheusCollectorLabels(t *testing.T) {
+	Convey("when a prometheus collector labeled by region receives updates tagged with a region", t, func() {
+		defer hystrix.Flush()
+		c := NewPrometheusCollectorWithLabels("test", "region")
+		registry := prometheus.NewRegistry()
+		So(registry.Register(c), ShouldBeNil)
+
+		circuit := c.NewPrometheusCircuitCollector("payments")
+		circuit.Update(metricCollector.MetricResult{Attempts: 1, Successes: 1, Tags: map[string]string{"region": "eu"}})
+		circuit.Update(metricCollector.MetricResult{Attempts: 1, Failures: 1, Tags: map[string]string{"region": "us"}})
+		circuit.Update(metricCollector.MetricResult{Attempts: 1, Failures: 1})
+
+		Convey("each region is exported under its own label", func() {
+			So(testutil.ToFloat64(c.successes.WithLabelValues("payments", "eu")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.failures.WithLabelValues("payments", "us")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.failures.WithLabelValues("payments", "")), ShouldEqual, 1)
+		})
+
+		Convey("the circuit state gauge is kept for every region", func() {
+			hystrix.ForceOpen("payments")
+			_, err := registry.Gather()
+			So(err, ShouldBeNil)
+
+			So(testutil.ToFloat64(c.circuitOpen.WithLabelValues("payments", "eu")), ShouldEqual, 1)
+			So(testutil.ToFloat64(c.circuitOpen.WithLabelValues("payments", "us")), ShouldEqual, 1)
+		})
+	})
+}
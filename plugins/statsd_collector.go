@@ -26,11 +26,14 @@ type StatsdCollector struct {
 	timeoutsPrefix          string
 	fallbackSuccessesPrefix string
 	fallbackFailuresPrefix  string
+	staleFallbacksPrefix    string
 	canceledPrefix          string
 	deadlinePrefix          string
+	rateLimitedPrefix       string
 	totalDurationPrefix     string
 	runDurationPrefix       string
 	concurrencyInUsePrefix  string
+	queueDepthPrefix        string
 	sampleRate              float32
 }
 
@@ -106,11 +109,14 @@ func (s *StatsdCollectorClient) NewStatsdCollector(name string) metricCollector.
 		timeoutsPrefix:          name + ".timeouts",
 		fallbackSuccessesPrefix: name + ".fallbackSuccesses",
 		fallbackFailuresPrefix:  name + ".fallbackFailures",
+		staleFallbacksPrefix:    name + ".fallbackStale",
 		canceledPrefix:          name + ".contextCanceled",
 		deadlinePrefix:          name + ".contextDeadlineExceeded",
+		rateLimitedPrefix:       name + ".rateLimited",
 		totalDurationPrefix:     name + ".totalDuration",
 		runDurationPrefix:       name + ".runDuration",
 		concurrencyInUsePrefix:  name + ".concurrencyInUse",
+		queueDepthPrefix:        name + ".queueDepth",
 		sampleRate:              s.sampleRate,
 	}
 }
@@ -162,11 +168,14 @@ func (g *StatsdCollector) Update(r metricCollector.MetricResult) {
 	g.incrementCounterMetric(g.timeoutsPrefix, r.Timeouts)
 	g.incrementCounterMetric(g.fallbackSuccessesPrefix, r.FallbackSuccesses)
 	g.incrementCounterMetric(g.fallbackFailuresPrefix, r.FallbackFailures)
+	g.incrementCounterMetric(g.staleFallbacksPrefix, r.StaleFallbacks)
 	g.incrementCounterMetric(g.canceledPrefix, r.ContextCanceled)
 	g.incrementCounterMetric(g.deadlinePrefix, r.ContextDeadlineExceeded)
+	g.incrementCounterMetric(g.rateLimitedPrefix, r.RateLimited)
 	g.updateTimerMetric(g.totalDurationPrefix, r.TotalDuration)
 	g.updateTimerMetric(g.runDurationPrefix, r.RunDuration)
 	g.updateTimingMetric(g.concurrencyInUsePrefix, int64(100*r.ConcurrencyInUse))
+	g.setGauge(g.queueDepthPrefix, int64(r.QueueDepth))
 }
 
 // Reset is a noop operation in this collector.